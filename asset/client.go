@@ -36,25 +36,50 @@ import (
 
 // Client is used to invoke /users APIs.
 type Client struct {
-	B    trimmer.Backend
-	Key  trimmer.ApiKey
-	Sess *trimmer.Session
+	B        trimmer.Backend
+	CDN      trimmer.Backend
+	Key      trimmer.ApiKey
+	Sess     *trimmer.Session
+	PartSize int64 // minimum upload part size, 0 uses trimmer.UploadPartSize
+
+	// parts sent in parallel, 0 uses trimmer.UploadPartConcurrency
+	PartConcurrency int
+
+	// journal of multipart uploads, nil disables resuming after restarts
+	Journal trimmer.UploadJournal
+
+	// retry policy for multipart upload parts, nil uses trimmer.DefaultRetryPolicy
+	Retry *trimmer.RetryPolicy
+
+	// what happens to multipart uploads that fail, aborts them by default
+	FailurePolicy trimmer.UploadFailurePolicy
 }
 
 func getC() Client {
-	return Client{trimmer.GetBackend(trimmer.APIBackend), trimmer.Key, &trimmer.LoginSession}
+	return Client{
+		B:       trimmer.GetBackend(trimmer.APIBackend),
+		CDN:     trimmer.GetBackend(trimmer.CDNBackend),
+		Key:     trimmer.Key,
+		Sess:    &trimmer.LoginSession,
+		Journal: trimmer.GetUploadJournal(),
+	}
+}
+
+// media returns a media client that shares this client's backends and session.
+func (c Client) media() *media.Client {
+	return &media.Client{B: c.B, CDN: c.CDN, Key: c.Key, Sess: c.Sess, PartSize: c.PartSize, PartConcurrency: c.PartConcurrency, Journal: c.Journal, Retry: c.Retry, FailurePolicy: c.FailurePolicy}
 }
 
 // Iter is an iterator for lists of Assets.
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.Asset]
 }
 
 // Asset returns the most recent Asset visited by a call to Next.
 func (i *Iter) Asset() *trimmer.Asset {
-	return i.Current().(*trimmer.Asset)
+	return i.Item()
 }
 
 func Get(ctx context.Context, assetId string, params *trimmer.AssetParams) (*trimmer.Asset, error) {
@@ -137,6 +162,10 @@ func UploadMedia(ctx context.Context, assetId string, params *trimmer.MediaParam
 	return getC().UploadMedia(ctx, assetId, params, src)
 }
 
+func UploadMediaStream(ctx context.Context, assetId string, params *trimmer.MediaParams, src io.Reader) (*trimmer.Media, error) {
+	return getC().UploadMediaStream(ctx, assetId, params, src)
+}
+
 func DeleteMedia(ctx context.Context, assetId, mediaId string) error {
 	return getC().DeleteMedia(ctx, assetId, mediaId)
 }
@@ -193,7 +222,7 @@ func (c Client) Update(ctx context.Context, assetId string, params *trimmer.Asse
 	}
 	v := &trimmer.Asset{}
 	u := fmt.Sprintf("/assets/%v", assetId)
-	err := c.B.Call(ctx, http.MethodPatch, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPatch, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -201,7 +230,7 @@ func (c Client) Delete(ctx context.Context, assetId string) error {
 	if assetId == "" {
 		return trimmer.EIDMissing
 	}
-	err := c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/assets/%v", assetId), c.Key, c.Sess, nil, nil, nil)
+	err := c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/assets/%v", assetId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, nil)
 	return err
 }
 
@@ -210,7 +239,7 @@ func (c Client) Trash(ctx context.Context, assetId string) (*trimmer.Asset, erro
 		return nil, trimmer.EIDMissing
 	}
 	v := &trimmer.Asset{}
-	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/trash", assetId), c.Key, c.Sess, nil, nil, v)
+	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/trash", assetId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, v)
 	return v, err
 }
 
@@ -219,7 +248,7 @@ func (c Client) Undelete(ctx context.Context, assetId string) (*trimmer.Asset, e
 		return nil, trimmer.EIDMissing
 	}
 	v := &trimmer.Asset{}
-	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/undelete", assetId), c.Key, c.Sess, nil, nil, v)
+	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/undelete", assetId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, v)
 	return v, err
 }
 
@@ -231,7 +260,7 @@ func (c Client) ForkCopy(ctx context.Context, assetId string, params *trimmer.As
 		return nil, trimmer.ENilPointer
 	}
 	v := &trimmer.Asset{}
-	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/fork", assetId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/fork", assetId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -243,13 +272,13 @@ func (c Client) ForkVersion(ctx context.Context, assetId string, params *trimmer
 		return nil, trimmer.ENilPointer
 	}
 	v := &trimmer.Asset{}
-	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/versions", assetId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/versions", assetId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
 func (c Client) ListVersions(ctx context.Context, assetId string, params *trimmer.AssetListParams) *Iter {
 	if assetId == "" {
-		return &Iter{trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &Iter{trimmer.GetIterErr[*trimmer.Asset](trimmer.EIDMissing)}
 	}
 	type assetList struct {
 		trimmer.ListMeta
@@ -267,17 +296,10 @@ func (c Client) ListVersions(ctx context.Context, assetId string, params *trimme
 		lp = &params.ListParams
 	}
 
-	return &Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Asset, trimmer.ListMeta, error) {
 		list := &assetList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/assets/%v/versions?%v", assetId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -285,11 +307,11 @@ func (c Client) DeleteVersions(ctx context.Context, assetId string) error {
 	if assetId == "" {
 		return trimmer.EIDMissing
 	}
-	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/assets/%v/versions", assetId), c.Key, c.Sess, nil, nil, nil)
+	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/assets/%v/versions", assetId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, nil)
 }
 func (c Client) ListLinks(ctx context.Context, assetId string, params *trimmer.LinkListParams) *link.Iter {
 	if assetId == "" {
-		return &link.Iter{trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &link.Iter{trimmer.GetIterErr[*trimmer.Link](trimmer.EIDMissing)}
 	}
 
 	type linkList struct {
@@ -316,23 +338,16 @@ func (c Client) ListLinks(ctx context.Context, assetId string, params *trimmer.L
 		lp = &params.ListParams
 	}
 
-	return &link.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &link.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Link, trimmer.ListMeta, error) {
 		list := &linkList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/assets/%v/links?%v", assetId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
 func (c Client) ListTags(ctx context.Context, assetId string, params *trimmer.TagListParams) *tag.Iter {
 	if assetId == "" {
-		return &tag.Iter{trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &tag.Iter{trimmer.GetIterErr[*trimmer.Tag](trimmer.EIDMissing)}
 	}
 
 	type tagList struct {
@@ -371,17 +386,10 @@ func (c Client) ListTags(ctx context.Context, assetId string, params *trimmer.Ta
 		lp = &params.ListParams
 	}
 
-	return &tag.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &tag.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Tag, trimmer.ListMeta, error) {
 		list := &tagList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/assets/%v/tags?%v", assetId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -393,13 +401,13 @@ func (c Client) NewTag(ctx context.Context, assetId string, params *trimmer.TagP
 		return nil, trimmer.ENilPointer
 	}
 	v := &trimmer.Tag{}
-	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/tags", assetId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/tags", assetId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
 func (c Client) ListMedia(ctx context.Context, assetId string, params *trimmer.MediaListParams) *media.Iter {
 	if assetId == "" {
-		return &media.Iter{Iter: trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &media.Iter{Iter: trimmer.GetIterErr[*trimmer.Media](trimmer.EIDMissing)}
 	}
 
 	type mediaList struct {
@@ -444,17 +452,10 @@ func (c Client) ListMedia(ctx context.Context, assetId string, params *trimmer.M
 		lp = &params.ListParams
 	}
 
-	return &media.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &media.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Media, trimmer.ListMeta, error) {
 		list := &mediaList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/assets/%v/media?%v", assetId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -467,7 +468,7 @@ func (c Client) NewMedia(ctx context.Context, assetId string, params *trimmer.Me
 	}
 	v := &trimmer.Media{}
 	media.StripMetadataUrls(params.Attr)
-	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/media", assetId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/media", assetId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -503,7 +504,7 @@ func (c Client) GetRevision(ctx context.Context, assetId string, params *trimmer
 
 func (c Client) ListRevisions(ctx context.Context, assetId string, params *trimmer.MetaListParams) *meta.Iter {
 	if assetId == "" {
-		return &meta.Iter{trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &meta.Iter{trimmer.GetIterErr[*trimmer.MetaRevision](trimmer.EIDMissing)}
 	}
 
 	type metaList struct {
@@ -527,17 +528,10 @@ func (c Client) ListRevisions(ctx context.Context, assetId string, params *trimm
 		lp = &params.ListParams
 	}
 
-	return &meta.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &meta.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.MetaRevision, trimmer.ListMeta, error) {
 		list := &metaList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/assets/%v/meta?%v", assetId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -571,7 +565,7 @@ func (c Client) CommitRevision(ctx context.Context, assetId string, params *trim
 		return nil, trimmer.ENilPointer
 	}
 	v := &trimmer.MetaRevision{}
-	err := c.B.Call(ctx, http.MethodPatch, fmt.Sprintf("/assets/%v/meta", assetId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPatch, fmt.Sprintf("/assets/%v/meta", assetId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -584,11 +578,28 @@ func (c Client) NewUpload(ctx context.Context, assetId string, params *trimmer.M
 	}
 	v := &trimmer.Media{}
 	media.StripMetadataUrls(params.Attr)
-	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/upload", assetId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/upload", assetId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_UPLOAD}, params, v)
 	return v, err
 }
 
+// UploadMedia creates asset media and uploads its data from src. When an
+// upload kept by the KeepFailedUploads policy fails, the media is returned
+// along with the error. Pass it to media.Upload with the same data to resume.
 func (c Client) UploadMedia(ctx context.Context, assetId string, params *trimmer.MediaParams, src io.ReadSeeker) (*trimmer.Media, error) {
+	return c.uploadMedia(ctx, assetId, params, func(mc *media.Client, fi *trimmer.FileInfo, m *trimmer.Media) *media.UploadRequest {
+		return mc.NewUploadRequest(fi, m, src)
+	})
+}
+
+// UploadMediaStream creates asset media and uploads its data from a reader
+// that cannot seek. Size and hashes in params may be left empty.
+func (c Client) UploadMediaStream(ctx context.Context, assetId string, params *trimmer.MediaParams, src io.Reader) (*trimmer.Media, error) {
+	return c.uploadMedia(ctx, assetId, params, func(mc *media.Client, fi *trimmer.FileInfo, m *trimmer.Media) *media.UploadRequest {
+		return mc.NewStreamUploadRequest(fi, m, src)
+	})
+}
+
+func (c Client) uploadMedia(ctx context.Context, assetId string, params *trimmer.MediaParams, newRequest func(*media.Client, *trimmer.FileInfo, *trimmer.Media) *media.UploadRequest) (*trimmer.Media, error) {
 	if assetId == "" {
 		return nil, trimmer.EIDMissing
 	}
@@ -613,9 +624,14 @@ func (c Client) UploadMedia(ctx context.Context, assetId string, params *trimmer
 	}
 
 	// 2 upload file data
-	r := media.NewUploadRequest(fi, m, src)
+	mc := c.media()
+	r := newRequest(mc, fi, m)
 	fi, err = r.Do(ctx)
 	if err != nil {
+		// keep media of uploads that can be resumed
+		if r.Resumable() {
+			return m, err
+		}
 		c.DeleteMedia(ctx, assetId, m.ID)
 		return nil, err
 	}
@@ -627,7 +643,7 @@ func (c Client) UploadMedia(ctx context.Context, assetId string, params *trimmer
 			Files: trimmer.FileInfoList{fi},
 			Embed: trimmer.API_EMBED_META | trimmer.API_EMBED_DETAILS,
 		}
-		if m, err = media.CompleteUpload(ctx, m.ID, up); err != nil {
+		if m, err = mc.CompleteUpload(ctx, m.ID, up); err != nil {
 			c.DeleteMedia(ctx, assetId, i)
 			return nil, err
 		}
@@ -640,7 +656,7 @@ func (c Client) DeleteMedia(ctx context.Context, assetId, mediaId string) error
 	if assetId == "" || mediaId == "" {
 		return trimmer.EIDMissing
 	}
-	err := c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/assets/%v/media/%v", assetId, mediaId), c.Key, c.Sess, nil, nil, nil)
+	err := c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/assets/%v/media/%v", assetId, mediaId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, nil)
 	return err
 }
 
@@ -658,7 +674,7 @@ func (c Client) Analyze(ctx context.Context, assetId string, params *trimmer.Ass
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
 	v := &trimmer.Job{}
-	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -676,7 +692,7 @@ func (c Client) Snapshot(ctx context.Context, assetId string, params *trimmer.As
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
 	v := &trimmer.Job{}
-	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -688,7 +704,7 @@ func (c Client) Transcode(ctx context.Context, assetId string, params *trimmer.A
 		return nil, trimmer.EIDMissing
 	}
 	v := &trimmer.Job{}
-	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/transcode", assetId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/assets/%v/transcode", assetId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -706,7 +722,7 @@ func (c Client) Trim(ctx context.Context, assetId string, params *trimmer.AssetT
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
 	v := &trimmer.Media{}
-	err := c.B.Call(ctx, http.MethodPatch, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPatch, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -724,7 +740,7 @@ func (c Client) Count(ctx context.Context, assetId string, params *trimmer.Asset
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
 	v := &trimmer.Asset{}
-	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -739,7 +755,7 @@ func (c Client) Lock(ctx context.Context, assetId string, params *trimmer.EmbedP
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
 	v := &trimmer.Asset{}
-	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, nil, nil, v)
+	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, v)
 	return v, err
 }
 
@@ -754,6 +770,6 @@ func (c Client) Unlock(ctx context.Context, assetId string, params *trimmer.Embe
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
 	v := &trimmer.Asset{}
-	err := c.B.Call(ctx, http.MethodDelete, u, c.Key, c.Sess, nil, nil, v)
+	err := c.B.Call(ctx, http.MethodDelete, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, v)
 	return v, err
 }
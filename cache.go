@@ -0,0 +1,305 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStatusHeader is set on responses served by a ResponseCache to "hit"
+// for fresh entries and "revalidated" for entries confirmed by a 304 response.
+const CacheStatusHeader = "X-Trimmer-Cache"
+
+// DefaultCacheEntries is the default size limit of a ResponseCache.
+const DefaultCacheEntries = 1000
+
+// ResponseCache caches JSON responses to GET requests in memory. Install it
+// as middleware on the API backend with WithResponseCache or
+//
+//	cache := trimmer.NewResponseCache(time.Minute)
+//	trimmer.Use(trimmer.APIBackend, cache.Middleware())
+//
+// Entries younger than TTL are served without contacting the server. Older
+// entries are revalidated with If-None-Match and If-Modified-Since and served
+// from memory when the server responds with 304 Not Modified. Concurrent
+// identical GETs are coalesced into a single request.
+//
+// Successful POST, PUT, PATCH and DELETE requests sent through the cache evict
+// entries for the same path and all paths below it. Call Invalidate to evict
+// entries after changes made elsewhere, e.g. in response to events.
+type ResponseCache struct {
+	TTL        time.Duration // serve entries without revalidation for this long
+	MaxEntries int           // evict the oldest entries above this size, 0 uses DefaultCacheEntries
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	calls   map[string]*cacheCall
+	gen     uint64 // incremented by invalidations
+}
+
+// cacheEntry is a buffered response.
+type cacheEntry struct {
+	path   string
+	status int
+	header http.Header
+	body   []byte
+	stored time.Time
+}
+
+// cacheCall is a GET in flight that concurrent identical requests wait for.
+type cacheCall struct {
+	done     chan struct{}
+	entry    *cacheEntry
+	err      error
+	canceled bool // the request failed because its own context ended
+}
+
+// NewResponseCache creates an empty cache.
+func NewResponseCache(ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		TTL:        ttl,
+		MaxEntries: DefaultCacheEntries,
+	}
+}
+
+// Middleware returns the middleware that serves requests from the cache.
+func (c *ResponseCache) Middleware() Middleware {
+	return func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			if !isCacheable(req) {
+				resp, err := next(req)
+				if err == nil && req.Method != http.MethodHead && resp.StatusCode < 400 {
+					c.Invalidate(req.URL.Path)
+				}
+				return resp, err
+			}
+			return c.get(next, req)
+		}
+	}
+}
+
+// Invalidate evicts all entries for path and the paths below it.
+func (c *ResponseCache) Invalidate(path string) {
+	path = strings.TrimSuffix(path, "/")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for key, e := range c.entries {
+		if e.path == path || strings.HasPrefix(e.path, path+"/") {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Purge evicts all entries.
+func (c *ResponseCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.entries = nil
+}
+
+// Len returns the number of cached entries.
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// isCacheable returns true for JSON GET requests.
+func isCacheable(req *http.Request) bool {
+	return req.Method == http.MethodGet &&
+		strings.Contains(req.Header.Get("Accept"), "application/json") &&
+		req.Header.Get("Range") == "" &&
+		req.Header.Get("If-None-Match") == "" &&
+		req.Header.Get("If-Modified-Since") == ""
+}
+
+// cacheKey separates entries by URL, credentials and accepted content.
+func cacheKey(req *http.Request) string {
+	return strings.Join([]string{
+		req.URL.String(),
+		req.Header.Get("Authorization"),
+		req.Header.Get("X-Api-Key"),
+		req.Header.Get("Accept"),
+	}, "\n")
+}
+
+func (c *ResponseCache) get(next RoundTrip, req *http.Request) (*http.Response, error) {
+	key := cacheKey(req)
+
+	c.mu.Lock()
+	stale := c.entries[key]
+	if stale != nil && time.Since(stale.stored) < c.TTL {
+		c.mu.Unlock()
+		return stale.response(req, "hit"), nil
+	}
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		// the first caller gave up, send the request on our own
+		if call.canceled {
+			return c.get(next, req)
+		}
+		if call.err != nil {
+			return nil, call.err
+		}
+		return call.entry.response(req, ""), nil
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	if c.calls == nil {
+		c.calls = make(map[string]*cacheCall)
+	}
+	c.calls[key] = call
+	gen := c.gen
+	c.mu.Unlock()
+
+	status := ""
+	call.entry, call.err = c.fetch(next, req, stale)
+	call.canceled = call.err != nil && req.Context().Err() != nil
+	if call.err == nil && stale != nil && call.entry.status == http.StatusNotModified {
+		call.entry = stale.revalidate(call.entry)
+		status = "revalidated"
+	}
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	switch {
+	case call.err != nil:
+	case gen != c.gen:
+		// evicted while in flight, the response may predate the change
+	case call.entry.status == http.StatusOK && isCacheableResponse(call.entry.header):
+		c.store(key, call.entry)
+	case call.entry.status == http.StatusNotFound || call.entry.status == http.StatusGone:
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	return call.entry.response(req, status), nil
+}
+
+// fetch sends req, made conditional when a stale entry exists, and buffers
+// the response.
+func (c *ResponseCache) fetch(next RoundTrip, req *http.Request, stale *cacheEntry) (*cacheEntry, error) {
+	if stale != nil {
+		// do not leak conditional headers into retries of the caller's request
+		req = req.Clone(req.Context())
+		if etag := stale.header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lm := stale.header.Get("Last-Modified"); lm != "" {
+			req.Header.Set("If-Modified-Since", lm)
+		}
+	}
+	resp, err := next(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &cacheEntry{
+		path:   strings.TrimSuffix(req.URL.Path, "/"),
+		status: resp.StatusCode,
+		header: resp.Header,
+		body:   body,
+		stored: time.Now(),
+	}, nil
+}
+
+// isCacheableResponse returns true for JSON responses the server allows to
+// store.
+func isCacheableResponse(h http.Header) bool {
+	return strings.Contains(h.Get("Content-Type"), "application/json") &&
+		!strings.Contains(h.Get("Cache-Control"), "no-store")
+}
+
+// store adds an entry and evicts the oldest entries above the size limit.
+// Callers must hold c.mu.
+func (c *ResponseCache) store(key string, e *cacheEntry) {
+	if c.entries == nil {
+		c.entries = make(map[string]*cacheEntry)
+	}
+	c.entries[key] = e
+	max := c.MaxEntries
+	if max <= 0 {
+		max = DefaultCacheEntries
+	}
+	for len(c.entries) > max {
+		var oldest string
+		for k, v := range c.entries {
+			if oldest == "" || v.stored.Before(c.entries[oldest].stored) {
+				oldest = k
+			}
+		}
+		delete(c.entries, oldest)
+	}
+}
+
+// revalidate returns a copy of the entry updated with the headers of a 304
+// response, e.g. its request id.
+func (e *cacheEntry) revalidate(notModified *cacheEntry) *cacheEntry {
+	header := e.header.Clone()
+	for k, v := range notModified.header {
+		switch k {
+		case "Content-Length", "Content-Type":
+		default:
+			header[k] = v
+		}
+	}
+	return &cacheEntry{
+		path:   e.path,
+		status: e.status,
+		header: header,
+		body:   e.body,
+		stored: notModified.stored,
+	}
+}
+
+// response creates a new response from the entry.
+func (e *cacheEntry) response(req *http.Request, status string) *http.Response {
+	header := e.header.Clone()
+	if status != "" {
+		header.Set(CacheStatusHeader, status)
+	}
+	return &http.Response{
+		Status:        http.StatusText(e.status),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,143 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheCoalesce(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"x"}`))
+	}))
+	defer srv.Close()
+
+	cache := NewResponseCache(time.Minute)
+	b := newTestBackend(srv.URL)
+	b.Use(cache.Middleware())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var v struct{ Name string }
+			if err := b.Call(context.Background(), http.MethodGet, "/x", "key", nil, nil, nil, &v); err != nil || v.Name != "x" {
+				t.Errorf("unexpected result %v %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("got %d calls, want 1", n)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("got %d entries, want 1", cache.Len())
+	}
+	cache.Invalidate("/x")
+	if cache.Len() != 0 {
+		t.Errorf("got %d entries after invalidation, want 0", cache.Len())
+	}
+}
+
+func TestResponseCacheSeparatesKeys(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"` + r.Header.Get("X-Api-Key") + `"}`))
+	}))
+	defer srv.Close()
+
+	// one cache shared by two accounts that use API keys without a session
+	cache := NewResponseCache(time.Minute)
+	b := newTestBackend(srv.URL)
+	b.Use(cache.Middleware())
+
+	for _, key := range []ApiKey{"a", "b", "a"} {
+		var v struct{ Name string }
+		if err := b.Call(context.Background(), http.MethodGet, "/x", key, nil, nil, nil, &v); err != nil || v.Name != string(key) {
+			t.Errorf("got %v %v, want %s", v, err, key)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("got %d calls, want 2", n)
+	}
+}
+
+func TestResponseCacheCanceledLeader(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"x"}`))
+	}))
+	defer srv.Close()
+
+	cache := NewResponseCache(time.Minute)
+	b := newTestBackend(srv.URL)
+	b.Retry = &RetryPolicy{} // backend retries would hide the leader's error
+	b.Use(cache.Middleware())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		leader <- b.Call(ctx, http.MethodGet, "/x", "key", nil, nil, nil, nil)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// a waiter merged onto the leader's request sends its own request when
+	// the leader's context is cancelled
+	waiter := make(chan error, 1)
+	var v struct{ Name string }
+	go func() {
+		waiter <- b.Call(context.Background(), http.MethodGet, "/x", "key", nil, nil, nil, &v)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-leader; err == nil {
+		t.Error("expected cancelled leader to fail")
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	if err := <-waiter; err != nil || v.Name != "x" {
+		t.Errorf("got %v %v, want x", v, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("got %d calls, want 2", n)
+	}
+}
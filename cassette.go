@@ -0,0 +1,425 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sync"
+
+	"trimmer.io/go-trimmer/hash"
+)
+
+// Interaction is a single recorded backend call. Credentials in queries and
+// JSON bodies are scrubbed before an interaction is stored.
+type Interaction struct {
+	Method   string          `json:"method"`
+	Path     string          `json:"path"`
+	Query    string          `json:"query,omitempty"`
+	Body     json.RawMessage `json:"body,omitempty"`     // JSON request body
+	BodyHash string          `json:"bodyHash,omitempty"` // sha256 of a binary request body
+	Response json.RawMessage `json:"response,omitempty"` // JSON response body
+	Data     []byte          `json:"data,omitempty"`     // binary response body
+	Size     int64           `json:"size,omitempty"`
+	Hashes   hash.HashBlock  `json:"hashes,omitempty"` // server checksums
+	Headers  *CallHeaders    `json:"headers,omitempty"`
+	Error    json.RawMessage `json:"error,omitempty"`   // API error response
+	Failure  string          `json:"failure,omitempty"` // non-API error message
+}
+
+// Cassette is a sequence of recorded interactions that can be saved to a file
+// and served back by a replaying backend. It makes tests written against a
+// real account repeatable without network access.
+//
+//	c := trimmer.NewCassette()
+//	cfg.Backends = c.Record(cfg.Backends)
+//	... run test against the real API ...
+//	c.Save("testdata/upload.json")
+//
+//	c, _ := trimmer.LoadCassette("testdata/upload.json")
+//	cfg.Backends = c.Replay()
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+	mu           sync.Mutex
+}
+
+// NewCassette creates an empty cassette.
+func NewCassette() *Cassette {
+	return &Cassette{Interactions: make([]*Interaction, 0)}
+}
+
+// LoadCassette reads a cassette from a file.
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := NewCassette()
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("cassette %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Save writes the cassette to a file.
+func (c *Cassette) Save(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// Record wraps both backends with recorders that add to this cassette.
+func (c *Cassette) Record(b Backends) Backends {
+	return Backends{
+		API: NewRecordingBackend(b.API, c),
+		CDN: NewRecordingBackend(b.CDN, c),
+	}
+}
+
+// Replay returns backends that serve interactions from this cassette. Both
+// backends share one replayer, so each interaction is played back once.
+func (c *Cassette) Replay() Backends {
+	r := NewReplayBackend(c)
+	return Backends{API: r, CDN: r}
+}
+
+func (c *Cassette) add(i *Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, i)
+}
+
+// ---------------------------------------------------------------------------
+// Recording
+//
+
+// RecordingBackend passes calls to another backend and records them.
+type RecordingBackend struct {
+	Backend  Backend
+	Cassette *Cassette
+}
+
+// NewRecordingBackend creates a backend that records all calls to b into c.
+func NewRecordingBackend(b Backend, c *Cassette) *RecordingBackend {
+	return &RecordingBackend{Backend: b, Cassette: c}
+}
+
+func (r *RecordingBackend) GetUrl() string {
+	return r.Backend.GetUrl()
+}
+
+func (r *RecordingBackend) Call(ctx context.Context, method, path string, key ApiKey, sess *Session, headers *CallHeaders, data, v interface{}) error {
+	i := newInteraction(method, path)
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		i.Body = redactJSON(b)
+	}
+	if headers == nil {
+		headers = &CallHeaders{}
+	}
+	err := r.Backend.Call(ctx, method, path, key, sess, headers, data, v)
+	i.record(headers, v, err)
+	r.Cassette.add(i)
+	return err
+}
+
+func (r *RecordingBackend) CallMultipart(ctx context.Context, method, path string, key ApiKey, sess *Session, headers *CallHeaders, body io.Reader, v interface{}) error {
+	// multipart boundaries are random, so bodies are not recorded
+	i := newInteraction(method, path)
+	if headers == nil {
+		headers = &CallHeaders{}
+	}
+	err := r.Backend.CallMultipart(ctx, method, path, key, sess, headers, body, v)
+	i.record(headers, v, err)
+	r.Cassette.add(i)
+	return err
+}
+
+func (r *RecordingBackend) CallChecksum(ctx context.Context, method, path string, key ApiKey, sess *Session, headers *CallHeaders, flags hash.HashFlags, body io.Reader, resp io.Writer, v interface{}) (int64, hash.HashBlock, hash.HashBlock, error) {
+	i := newInteraction(method, path)
+
+	// hash seekable sources up front so the backend can still rewind them
+	// for retries, other sources are hashed while they are sent
+	bodyHash := sha256.New()
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			_, err = io.Copy(bodyHash, seeker)
+		}
+		if err == nil {
+			_, err = seeker.Seek(offset, io.SeekStart)
+		}
+		if err != nil {
+			return 0, hash.HashBlock{}, hash.HashBlock{}, NewUsageError("reading request body failed", err)
+		}
+	} else if body != nil {
+		body = io.TeeReader(body, bodyHash)
+	}
+
+	var data bytes.Buffer
+	if resp != nil {
+		resp = io.MultiWriter(resp, &data)
+	}
+	if headers == nil {
+		headers = &CallHeaders{}
+	}
+
+	size, clientHash, serverHash, err := r.Backend.CallChecksum(ctx, method, path, key, sess, headers, flags, body, resp, v)
+	i.record(headers, v, err)
+	if body != nil {
+		i.BodyHash = hex.EncodeToString(bodyHash.Sum(nil))
+	}
+	i.Size = size
+	i.Hashes = serverHash
+	if data.Len() > 0 {
+		i.Data = data.Bytes()
+	}
+	r.Cassette.add(i)
+	return size, clientHash, serverHash, err
+}
+
+func newInteraction(method, path string) *Interaction {
+	p, q := splitPath(path)
+	return &Interaction{Method: method, Path: p, Query: q}
+}
+
+// record stores the outcome of a call.
+func (i *Interaction) record(headers *CallHeaders, v interface{}, err error) {
+	h := *headers
+	h.IdempotencyKey = "" // random per run
+	i.Headers = &h
+	if err != nil {
+		if e, ok := err.(TrimmerError); ok && e.IsApi() {
+			i.Error = e.Marshal()
+		} else {
+			i.Failure = err.Error()
+		}
+		return
+	}
+	if v == nil {
+		return
+	}
+	if _, ok := v.(io.Writer); ok {
+		return
+	}
+	if b, err := json.Marshal(v); err == nil {
+		i.Response = redactJSON(b)
+	}
+}
+
+// splitPath strips scheme and host from absolute URLs and returns the path
+// and the scrubbed, sorted query string.
+func splitPath(path string) (string, string) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return path, ""
+	}
+	q := u.Query()
+	for k := range q {
+		if isSensitiveField(k) {
+			q.Set(k, redacted)
+		}
+	}
+	return u.Path, q.Encode()
+}
+
+// ---------------------------------------------------------------------------
+// Replay
+//
+
+// ReplayBackend serves calls from a cassette without network access. A call
+// is answered by the first unused interaction with the same method, path,
+// query and body. Calls that match an interaction's method and path but
+// differ in query or body are reported as drift.
+type ReplayBackend struct {
+	Cassette *Cassette
+	URL      string
+	used     []bool
+	mu       sync.Mutex
+}
+
+// NewReplayBackend creates a backend that serves calls from c.
+func NewReplayBackend(c *Cassette) *ReplayBackend {
+	return &ReplayBackend{
+		Cassette: c,
+		used:     make([]bool, len(c.Interactions)),
+	}
+}
+
+func (r *ReplayBackend) GetUrl() string {
+	return r.URL
+}
+
+// Unused returns all interactions that have not been played back.
+func (r *ReplayBackend) Unused() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var l []*Interaction
+	for n, i := range r.Cassette.Interactions {
+		if !r.used[n] {
+			l = append(l, i)
+		}
+	}
+	return l
+}
+
+func (r *ReplayBackend) Call(ctx context.Context, method, path string, key ApiKey, sess *Session, headers *CallHeaders, data, v interface{}) error {
+	req := newInteraction(method, path)
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		req.Body = redactJSON(b)
+	}
+	i, err := r.find(req)
+	if err != nil {
+		return err
+	}
+	return i.replay(sess, headers, v)
+}
+
+func (r *ReplayBackend) CallMultipart(ctx context.Context, method, path string, key ApiKey, sess *Session, headers *CallHeaders, body io.Reader, v interface{}) error {
+	i, err := r.find(newInteraction(method, path))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		io.Copy(ioutil.Discard, body)
+	}
+	return i.replay(sess, headers, v)
+}
+
+func (r *ReplayBackend) CallChecksum(ctx context.Context, method, path string, key ApiKey, sess *Session, headers *CallHeaders, flags hash.HashFlags, body io.Reader, resp io.Writer, v interface{}) (int64, hash.HashBlock, hash.HashBlock, error) {
+	var clientHash hash.HashBlock
+	req := newInteraction(method, path)
+
+	if body != nil {
+		if flags > 0 {
+			body = clientHash.NewReader(body, flags)
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, body); err != nil {
+			return 0, hash.HashBlock{}, hash.HashBlock{}, NewUsageError("reading request body failed", err)
+		}
+		req.BodyHash = hex.EncodeToString(h.Sum(nil))
+	}
+
+	i, err := r.find(req)
+	if err != nil {
+		return 0, hash.HashBlock{}, hash.HashBlock{}, err
+	}
+	if err := i.replay(sess, headers, v); err != nil {
+		return 0, hash.HashBlock{}, hash.HashBlock{}, err
+	}
+
+	if resp != nil && len(i.Data) > 0 {
+		w := resp
+		if flags > 0 {
+			w = clientHash.NewWriter(w, flags)
+		}
+		if _, err := w.Write(i.Data); err != nil {
+			return 0, hash.HashBlock{}, hash.HashBlock{}, NewInternalError("copying response failed", err)
+		}
+	}
+	clientHash.Sum()
+	return i.Size, clientHash, i.Hashes, nil
+}
+
+// find marks and returns the first unused interaction matching req.
+func (r *ReplayBackend) find(req *Interaction) (*Interaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var drift *Interaction
+	for n, i := range r.Cassette.Interactions {
+		if r.used[n] || i.Method != req.Method || i.Path != req.Path {
+			continue
+		}
+		if i.Query == req.Query && sameJSON(i.Body, req.Body) && i.BodyHash == req.BodyHash {
+			r.used[n] = true
+			return i, nil
+		}
+		if drift == nil {
+			drift = i
+		}
+	}
+	if drift != nil {
+		return nil, NewUsageError(
+			fmt.Sprintf("cassette: request drift for %s %s", req.Method, req.Path),
+			fmt.Errorf("recorded query %q body %s, sent query %q body %s", drift.Query, drift.Body, req.Query, req.Body),
+		)
+	}
+	return nil, NewUsageError(fmt.Sprintf("cassette: no recorded interaction for %s %s", req.Method, req.Path), nil)
+}
+
+// sameJSON compares two JSON documents ignoring whitespace, cassette files
+// store bodies indented.
+func sameJSON(a, b []byte) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// replay returns the recorded outcome of a call.
+func (i *Interaction) replay(sess *Session, headers *CallHeaders, v interface{}) error {
+	if headers != nil && i.Headers != nil {
+		headers.ContentType = i.Headers.ContentType
+		headers.ContentDisposition = i.Headers.ContentDisposition
+		headers.Size = i.Headers.Size
+		headers.Hashes = i.Headers.Hashes
+		headers.OAuthScopes = i.Headers.OAuthScopes
+		headers.SessionId = i.Headers.SessionId
+		headers.RequestId = i.Headers.RequestId
+		headers.Runtime = i.Headers.Runtime
+	}
+	if len(i.Error) > 0 {
+		e := ParseApiErrorFromByte(i.Error)
+		if e.StatusCode == 401 && sess != nil {
+			sess.Reset()
+		}
+		return e
+	}
+	if i.Failure != "" {
+		return NewInternalError(i.Failure, nil)
+	}
+	if v == nil || len(i.Response) == 0 {
+		return nil
+	}
+	if _, ok := v.(io.Writer); ok {
+		return nil
+	}
+	if err := json.Unmarshal(i.Response, v); err != nil {
+		return NewInternalError("parsing response failed", err)
+	}
+	return nil
+}
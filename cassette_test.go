@@ -0,0 +1,142 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"trimmer.io/go-trimmer/hash"
+)
+
+func newCassetteServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/auth/login":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"accessToken":"secret-token","tokenType":"Bearer"}`))
+		case "/file":
+			w.Header().Set("X-Trimmer-Hash", "sha256:a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447")
+			w.Write([]byte("hello world\n"))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(NewApiError(http.StatusNotFound).Marshal())
+		}
+	}))
+}
+
+func runCassetteCalls(b Backend) (*Session, string, error) {
+	ctx := context.Background()
+	sess := &Session{}
+	login := &LoginParams{Username: "test", Password: "secret-password"}
+	if err := b.Call(ctx, http.MethodPost, "/auth/login", "key", nil, nil, login, sess); err != nil {
+		return nil, "", err
+	}
+	var buf bytes.Buffer
+	h := hash.HashBlock{Sha256: "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"}
+	_, client, server, err := b.CallChecksum(ctx, http.MethodGet, "/file?a=1", "key", nil, nil, h.AnyFlag(), nil, &buf, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := client.Check(server, false); err != nil {
+		return nil, "", err
+	}
+	err = b.Call(ctx, http.MethodGet, "/missing", "key", nil, nil, nil, nil)
+	return sess, buf.String(), err
+}
+
+func TestCassetteRecordReplay(t *testing.T) {
+	LogLevel = 0
+	srv := newCassetteServer()
+	defer srv.Close()
+
+	c := NewCassette()
+	_, data, err := runCassetteCalls(NewRecordingBackend(newTestBackend(srv.URL), c))
+	if e, ok := err.(TrimmerError); !ok || e.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 error, got %v", err)
+	}
+	if data != "hello world\n" {
+		t.Fatalf("unexpected download %q", data)
+	}
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(path)
+	for _, secret := range []string{"secret-password", "secret-token", srv.URL} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("cassette contains %q", secret)
+		}
+	}
+
+	c, err = LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReplayBackend(c)
+	sess, data, err := runCassetteCalls(r)
+	if e, ok := err.(TrimmerError); !ok || !e.IsApi() || e.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected replayed 404 error, got %v", err)
+	}
+	if data != "hello world\n" {
+		t.Errorf("unexpected replayed download %q", data)
+	}
+	if sess.TokenType != "Bearer" || sess.AccessToken != redacted {
+		t.Errorf("unexpected replayed session %+v", sess)
+	}
+	if l := r.Unused(); len(l) != 0 {
+		t.Errorf("expected all interactions to be used, got %d unused", len(l))
+	}
+	if err := r.Call(context.Background(), http.MethodGet, "/missing", "key", nil, nil, nil, nil); err == nil {
+		t.Error("expected error when interactions are exhausted")
+	}
+}
+
+func TestCassetteDrift(t *testing.T) {
+	c := NewCassette()
+	c.add(&Interaction{Method: http.MethodPost, Path: "/assets", Body: []byte(`{"name":"a"}`)})
+
+	r := NewReplayBackend(c)
+	err := r.Call(context.Background(), http.MethodPost, "/assets", "key", nil, nil, map[string]string{"name": "b"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "drift") {
+		t.Fatalf("expected drift error, got %v", err)
+	}
+	if err := r.Call(context.Background(), http.MethodPost, "/assets", "key", nil, nil, map[string]string{"name": "a"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCassetteRedactsQuery(t *testing.T) {
+	i := newInteraction(http.MethodGet, "https://api.trimmer.io/files?access_token=AT123&api_key=K456&device_code=DC789&a=1")
+	for _, secret := range []string{"AT123", "K456", "DC789"} {
+		if strings.Contains(i.Query, secret) {
+			t.Errorf("query contains secret %q: %s", secret, i.Query)
+		}
+	}
+	if i.Path != "/files" || !strings.Contains(i.Query, "a=1") {
+		t.Errorf("unexpected path %q and query %q", i.Path, i.Query)
+	}
+}
@@ -0,0 +1,85 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package client provides a Trimmer API client that is bound to its own
+// configuration instead of the package-level globals. Use it when a process
+// talks to more than one Trimmer account:
+//
+//	prod := client.New(trimmer.WithKey(prodKey))
+//	staging := client.New(trimmer.WithKey(stagingKey), trimmer.WithAPIURL(stagingURL))
+//
+//	prod.Session.Login(ctx, params)
+//	a, err := prod.Assets.Get(ctx, assetId, nil)
+package client
+
+import (
+	trimmer "trimmer.io/go-trimmer"
+	"trimmer.io/go-trimmer/asset"
+	"trimmer.io/go-trimmer/job"
+	"trimmer.io/go-trimmer/media"
+	"trimmer.io/go-trimmer/org"
+	"trimmer.io/go-trimmer/session"
+	"trimmer.io/go-trimmer/stash"
+	"trimmer.io/go-trimmer/tag"
+	"trimmer.io/go-trimmer/user"
+	"trimmer.io/go-trimmer/volume"
+	"trimmer.io/go-trimmer/workspace"
+)
+
+// API is the Trimmer client. It contains all resource clients that share the
+// same configuration, backends and login session.
+type API struct {
+	Config     *trimmer.Config
+	Assets     *asset.Client
+	Jobs       *job.Client
+	Media      *media.Client
+	Orgs       *org.Client
+	Session    *session.Client
+	Stashes    *stash.Client
+	Tags       *tag.Client
+	Users      *user.Client
+	Volumes    *volume.Client
+	Workspaces *workspace.Client
+}
+
+// New creates a client from a new configuration with the given options.
+func New(opts ...trimmer.Option) *API {
+	return NewFromConfig(trimmer.NewConfig(opts...))
+}
+
+// NewFromConfig creates a client that uses an existing configuration.
+func NewFromConfig(c *trimmer.Config) *API {
+	api := &API{}
+	api.Init(c)
+	return api
+}
+
+// Init sets up all resource clients from c.
+func (a *API) Init(c *trimmer.Config) {
+	b, cdn, key, sess := c.Backends.API, c.Backends.CDN, c.Key, c.Session
+
+	a.Config = c
+	a.Assets = &asset.Client{B: b, CDN: cdn, Key: key, Sess: sess, PartSize: c.UploadPartSize, PartConcurrency: c.PartConcurrency, Journal: c.UploadJournal, Retry: c.Retry, FailurePolicy: c.UploadFailure}
+	a.Jobs = &job.Client{B: b, CDN: cdn, Key: key, Sess: sess}
+	a.Media = &media.Client{B: b, CDN: cdn, Key: key, Sess: sess, PartSize: c.UploadPartSize, PartConcurrency: c.PartConcurrency, Journal: c.UploadJournal, Retry: c.Retry, FailurePolicy: c.UploadFailure}
+	a.Orgs = &org.Client{B: b, Key: key, Sess: sess}
+	a.Session = &session.Client{B: b, Key: key, Sess: sess}
+	a.Stashes = &stash.Client{B: b, Key: key, Sess: sess}
+	a.Tags = &tag.Client{B: b, Key: key, Sess: sess}
+	a.Users = &user.Client{B: b, Key: key, Sess: sess}
+	a.Volumes = &volume.Client{B: b, Key: key, Sess: sess}
+	a.Workspaces = &workspace.Client{B: b, Key: key, Sess: sess}
+}
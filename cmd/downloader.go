@@ -35,7 +35,10 @@ import (
 )
 
 var (
-	Debug = flag.Bool("debug", false, "enable debugging")
+	Debug       = flag.Bool("debug", false, "enable debugging")
+	ProfileName = flag.String("profile", "", "config file profile to use")
+	Logout      = flag.Bool("logout", false, "log out and clear the stored session when done")
+	Device      = flag.Bool("device", false, "log in by approving a code on another device")
 )
 
 func Download(ctx context.Context, aid string, m *Media) error {
@@ -100,6 +103,11 @@ func main() {
 	if *Debug {
 		LogLevel = 3
 	}
+	if *ProfileName != "" {
+		if err := UseProfile(*ProfileName); err != nil {
+			log.Fatalln("Loading profile failed:", err)
+		}
+	}
 
 	if len(flag.Args()) == 0 {
 		log.Fatalln("Usage:", os.Args[0], "<assetId> [<role>]")
@@ -108,10 +116,18 @@ func main() {
 
 	// try fetching client token or user credentials from env
 	if _, err := NewClientSession(""); err != nil {
-		if err := session.Login(ctx, session.ParseEnv()); err != nil {
+		// share one session between runs and parallel invocations
+		LoginSession.UseStore(NewFileSessionStore(DefaultSessionPath()))
+		login := func() error { return session.Resume(ctx, session.ParseEnv()) }
+		if *Device {
+			login = func() error { return session.ResumeDevice(ctx, nil) }
+		}
+		if err := login(); err != nil {
 			log.Fatalln("Login failed.")
 		}
-		defer session.Logout(ctx)
+		if *Logout {
+			defer session.Logout(ctx)
+		}
 	}
 	if err := session.Check(ctx); err != nil {
 		log.Fatalln("Check failed.")
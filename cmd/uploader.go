@@ -37,12 +37,15 @@ import (
 )
 
 var (
-	Timecode = flag.String("tc", "00:00:00:00", "media start timecode")
-	Access   = flag.String("access", "", "access class (public, private, personal)")
-	Role     = flag.String("role", "", "media role (e.g. video)")
-	Reel     = flag.String("reel", "", "media reel name")
-	Debug    = flag.Bool("debug", false, "enable debugging")
-	Family   = flag.String("family", "capture", "default media family")
+	Timecode    = flag.String("tc", "00:00:00:00", "media start timecode")
+	Access      = flag.String("access", "", "access class (public, private, personal)")
+	Role        = flag.String("role", "", "media role (e.g. video)")
+	Reel        = flag.String("reel", "", "media reel name")
+	Debug       = flag.Bool("debug", false, "enable debugging")
+	ProfileName = flag.String("profile", "", "config file profile to use")
+	Logout      = flag.Bool("logout", false, "log out and clear the stored session when done")
+	Device      = flag.Bool("device", false, "log in by approving a code on another device")
+	Family      = flag.String("family", "capture", "default media family")
 )
 
 func fail(v interface{}) {
@@ -65,6 +68,11 @@ func main() {
 	if *Debug {
 		LogLevel = 3
 	}
+	if *ProfileName != "" {
+		if err := UseProfile(*ProfileName); err != nil {
+			log.Fatalln("Loading profile failed:", err)
+		}
+	}
 
 	if len(os.Args) < 3 {
 		usage()
@@ -101,10 +109,18 @@ func main() {
 
 	// try fetching client token or user credentials from env
 	if _, err := NewClientSession(""); err != nil {
-		if err := session.Login(ctx, session.ParseEnv()); err != nil {
+		// share one session between runs and parallel invocations
+		LoginSession.UseStore(NewFileSessionStore(DefaultSessionPath()))
+		login := func() error { return session.Resume(ctx, session.ParseEnv()) }
+		if *Device {
+			login = func() error { return session.ResumeDevice(ctx, nil) }
+		}
+		if err := login(); err != nil {
 			log.Fatalln("Login failed.")
 		}
-		defer session.Logout(ctx)
+		if *Logout {
+			defer session.Logout(ctx)
+		}
 	}
 	if err := session.Check(ctx); err != nil {
 		log.Fatalln("Check failed.")
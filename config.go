@@ -0,0 +1,303 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"net/http"
+)
+
+// Config holds all settings for talking to one Trimmer account. Unlike the
+// package-level globals, each Config has its own API key, endpoints, HTTP
+// clients, rate limiters and login session, so multiple accounts can be used
+// side by side in the same process.
+//
+// Use NewConfig to create a Config and package client to obtain resource
+// clients that are bound to it.
+type Config struct {
+	Key             ApiKey
+	APIURL          string
+	CDNURL          string
+	APIHTTPClient   *http.Client
+	CDNHTTPClient   *http.Client
+	UserAgent       string
+	UploadPartSize  int64
+	PartConcurrency int
+	UploadJournal   UploadJournal
+	UploadFailure   UploadFailurePolicy
+	Session         *Session
+	Retry           *RetryPolicy
+	APILimiter      *RateLimiter
+	CDNLimiter      *RateLimiter
+	Logger          LeveledLogger
+	Middleware      []Middleware
+	Instrumentation Instrumentation
+	Cache           *ResponseCache
+	APIStall        *StallPolicy
+	CDNStall        *StallPolicy
+	Profile         *ConfigProfile
+	Credentials     CredentialProvider
+
+	// Backends are created by NewConfig from the settings above. Replace
+	// them after NewConfig returns to mock calls for tests.
+	Backends Backends
+}
+
+// Option changes a setting on a Config.
+type Option func(*Config)
+
+// WithKey sets the API key.
+func WithKey(key ApiKey) Option {
+	return func(c *Config) { c.Key = key }
+}
+
+// WithAPIURL sets the API server URL.
+func WithAPIURL(url string) Option {
+	return func(c *Config) { c.APIURL = url }
+}
+
+// WithCDNURL sets the CDN server URL.
+func WithCDNURL(url string) Option {
+	return func(c *Config) { c.CDNURL = url }
+}
+
+// WithHTTPClient sets the HTTP client used for a backend.
+func WithHTTPClient(backend SupportedBackend, client *http.Client) Option {
+	return func(c *Config) {
+		switch backend {
+		case APIBackend:
+			c.APIHTTPClient = client
+		case CDNBackend:
+			c.CDNHTTPClient = client
+		}
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(ua string) Option {
+	return func(c *Config) { c.UserAgent = ua }
+}
+
+// WithUploadPartSize sets the minimum size of multipart upload parts.
+func WithUploadPartSize(size int64) Option {
+	return func(c *Config) { c.UploadPartSize = size }
+}
+
+// WithPartConcurrency sets the number of multipart upload parts sent in
+// parallel.
+func WithPartConcurrency(n int) Option {
+	return func(c *Config) { c.PartConcurrency = n }
+}
+
+// WithUploadJournal makes multipart uploads resumable after restarts with
+// journal j.
+func WithUploadJournal(j UploadJournal) Option {
+	return func(c *Config) { c.UploadJournal = j }
+}
+
+// WithUploadFailurePolicy sets whether failed multipart uploads are aborted
+// or kept on the volume for a later resume.
+func WithUploadFailurePolicy(p UploadFailurePolicy) Option {
+	return func(c *Config) { c.UploadFailure = p }
+}
+
+type partConcurrency struct{}
+
+// ContextWithPartConcurrency returns a context that makes uploads started
+// with it send n parts in parallel, regardless of the client's setting.
+func ContextWithPartConcurrency(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, partConcurrency{}, n)
+}
+
+// PartConcurrencyFromContext returns the part concurrency stored in ctx or 0.
+func PartConcurrencyFromContext(ctx context.Context) int {
+	if ctx != nil {
+		if n, ok := ctx.Value(partConcurrency{}).(int); ok {
+			return n
+		}
+	}
+	return 0
+}
+
+// WithSession uses an existing login session instead of a fresh one.
+func WithSession(sess *Session) Option {
+	return func(c *Config) { c.Session = sess }
+}
+
+// WithRetryPolicy sets how transient errors are retried.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Config) { c.Retry = &p }
+}
+
+// WithRateLimit limits the number of requests per second sent to a backend.
+func WithRateLimit(backend SupportedBackend, rate float64, burst int) Option {
+	return func(c *Config) {
+		switch backend {
+		case APIBackend:
+			c.APILimiter = NewRateLimiter(rate, burst)
+		case CDNBackend:
+			c.CDNLimiter = NewRateLimiter(rate, burst)
+		}
+	}
+}
+
+// WithLogger sets the logger for all calls made through this configuration.
+func WithLogger(l LeveledLogger) Option {
+	return func(c *Config) { c.Logger = l }
+}
+
+// WithMiddleware appends middlewares to the chain of both backends.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(c *Config) { c.Middleware = append(c.Middleware, mw...) }
+}
+
+// WithInstrumentation reports metrics and traces for all calls made through
+// this configuration to i.
+func WithInstrumentation(i Instrumentation) Option {
+	return func(c *Config) { c.Instrumentation = i }
+}
+
+// WithResponseCache serves API GET requests made through this configuration
+// from cache.
+func WithResponseCache(cache *ResponseCache) Option {
+	return func(c *Config) { c.Cache = cache }
+}
+
+// WithProfile applies the settings of a config file profile. Options after
+// it override the profile. Use LoadProfile to read a profile.
+func WithProfile(p *ConfigProfile) Option {
+	return func(c *Config) {
+		c.Profile = p
+		if p == nil {
+			return
+		}
+		if p.Key != "" {
+			c.Key = p.Key
+		}
+		if p.APIServer != "" {
+			c.APIURL = p.APIServer
+		}
+		if p.CDNServer != "" {
+			c.CDNURL = p.CDNServer
+		}
+		if p.PartSize > 0 {
+			c.UploadPartSize = p.PartSize
+		}
+		if cp := p.CredentialProvider(); cp != nil {
+			c.Credentials = cp
+		}
+		if t := p.Transport(); t != nil {
+			c.APIHTTPClient = &http.Client{Timeout: defaultHTTPTimeout, Transport: t}
+			c.CDNHTTPClient = &http.Client{Transport: t}
+		}
+	}
+}
+
+// WithCredentials resolves API keys and login credentials through p when
+// they are not set explicitly.
+func WithCredentials(p CredentialProvider) Option {
+	return func(c *Config) { c.Credentials = p }
+}
+
+// WithStallPolicy sets the stall policy of a backend, nil disables stall
+// detection. The CDN backend uses DefaultStallPolicy unless changed.
+func WithStallPolicy(backend SupportedBackend, p *StallPolicy) Option {
+	return func(c *Config) {
+		switch backend {
+		case APIBackend:
+			c.APIStall = p
+		case CDNBackend:
+			c.CDNStall = p
+		}
+	}
+}
+
+// NewConfig creates a new configuration. Settings not provided as options
+// default to the values read from the environment at startup, but the new
+// configuration never shares HTTP clients, rate limiters or the login session
+// with the package-level globals.
+func NewConfig(opts ...Option) *Config {
+	cdnStall := DefaultStallPolicy
+	c := &Config{
+		Key:             Key,
+		APIURL:          apiURL,
+		CDNURL:          cdnURL,
+		UserAgent:       UserAgent,
+		UploadPartSize:  UploadPartSize,
+		PartConcurrency: UploadPartConcurrency,
+		UploadJournal:   uploadJournal,
+		CDNStall:        &cdnStall,
+		Profile:         activeProfile,
+		Credentials:     credentialProvider,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.APIHTTPClient == nil {
+		c.APIHTTPClient = &http.Client{Timeout: defaultHTTPTimeout, Transport: c.Profile.Transport()}
+	}
+	if c.CDNHTTPClient == nil {
+		c.CDNHTTPClient = &http.Client{Transport: c.Profile.Transport()}
+	}
+	if c.Session == nil {
+		c.Session = &Session{}
+	}
+	if c.APILimiter == nil {
+		c.APILimiter = NewRateLimiter(0, 1)
+	}
+	if c.CDNLimiter == nil {
+		c.CDNLimiter = NewRateLimiter(0, 1)
+	}
+	if c.UploadPartSize <= 0 {
+		c.UploadPartSize = DefaultPartSize
+	}
+	if c.PartConcurrency <= 0 {
+		c.PartConcurrency = DefaultPartConcurrency
+	}
+	c.Backends = Backends{
+		API: c.NewBackend(APIBackend),
+		CDN: c.NewBackend(CDNBackend),
+	}
+	return c
+}
+
+// NewBackend creates a backend for the given endpoint from this configuration.
+func (c *Config) NewBackend(backend SupportedBackend) Backend {
+	b := BackendConfiguration{
+		Type:       backend,
+		Retry:      c.Retry,
+		Logger:     c.Logger,
+		UserAgent:  c.UserAgent,
+		Middleware: c.Middleware,
+
+		Instrumentation: c.Instrumentation,
+		Credentials:     c.Credentials,
+	}
+	switch backend {
+	case APIBackend:
+		b.URL, b.HTTPClient, b.Limiter, b.Stall = c.APIURL, c.APIHTTPClient, c.APILimiter, c.APIStall
+		if c.Cache != nil {
+			// innermost, so other middlewares see cached responses too
+			b.Use(c.Cache.Middleware())
+		}
+	case CDNBackend:
+		b.URL, b.HTTPClient, b.Limiter, b.Stall = c.CDNURL, c.CDNHTTPClient, c.CDNLimiter, c.CDNStall
+	default:
+		return nil
+	}
+	return b
+}
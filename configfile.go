@@ -0,0 +1,502 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Config File
+//
+// The config file holds named profiles, e.g. for staging, production and
+// customer accounts. It is read from $TRIMMER_CONFIG or, by default, from
+// trimmer/config in the user's config directory (~/.config/trimmer/config
+// on Linux):
+//
+//	# used when no profile is selected
+//	[default]
+//	api_key    = ...
+//	username   = jane
+//	password   = ...
+//
+//	[staging]
+//	api_server = https://api.staging.example.com
+//	cdn_server = https://cdn.staging.example.com
+//	api_key    = ...
+//	auth       = token
+//	token      = ...
+//	scopes     = public, private, upload
+//	part_size  = 64MiB
+//	proxy      = http://proxy.example.com:3128
+//	ca_bundle  = ~/certs/staging-ca.pem
+//	client_cert = ~/certs/client.pem
+//	client_key  = ~/certs/client.key
+//
+//	[ci]
+//	credential_helper = trimmer-credential-vault --path secret/trimmer
+//
+//	[laptop]
+//	credential_file = ~/.config/trimmer/credentials.json
+//
+// Select a profile with $TRIMMER_PROFILE, UseProfile or WithProfile. The
+// selected profile is applied at startup before environment variables, so
+// TRIMMER_API_KEY and friends still override it.
+const (
+	TRIMMER_PROFILE_KEY = "TRIMMER_PROFILE"
+	TRIMMER_CONFIG_KEY  = "TRIMMER_CONFIG"
+)
+
+// DefaultProfile is the profile used when none is selected.
+const DefaultProfile = "default"
+
+// Authentication methods of a profile.
+const (
+	AuthPassword = "password" // log in with username or email and password
+	AuthToken    = "token"    // use a client token
+)
+
+// ConfigProfile is a named profile from the config file.
+type ConfigProfile struct {
+	Name        string
+	APIServer   string      // api_server
+	CDNServer   string      // cdn_server
+	Key         ApiKey      // api_key
+	Auth        string      // auth: password or token, empty uses any credentials found
+	Username    string      // username
+	Email       string      // email
+	Password    string      // password
+	ClientToken ClientToken // token
+	Scopes      string      // scopes, comma separated
+	PartSize    int64       // part_size, in bytes or with KiB, MiB or GiB suffix
+	Proxy       string      // proxy URL
+	CABundle    string      // ca_bundle, PEM file with additional root certificates
+	ClientCert  string      // client_cert, PEM certificate for TLS client authentication
+	ClientKey   string      // client_key, PEM key, empty when contained in client_cert
+
+	CredentialFile   string // credential_file, JSON file with secrets, see FileCredentials
+	CredentialHelper string // credential_helper, command line, see HelperCredentials
+
+	transport *http.Transport
+}
+
+// ConfigFile maps profile names to profiles.
+type ConfigFile map[string]*ConfigProfile
+
+var activeProfile *ConfigProfile
+
+// profileErr is the error of loading the profile at startup. Package-level
+// calls and clients without an explicit profile fail with it until
+// UseProfile succeeds.
+var profileErr error
+
+// CurrentProfile returns the profile applied to the package-level settings
+// or nil when there is none. The error is set when the profile selected at
+// startup, e.g. with $TRIMMER_PROFILE, could not be loaded.
+func CurrentProfile() (*ConfigProfile, error) {
+	return activeProfile, profileErr
+}
+
+// DefaultConfigPath returns the path of the config file.
+func DefaultConfigPath() string {
+	if s := os.Getenv(TRIMMER_CONFIG_KEY); s != "" {
+		return s
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "trimmer", "config")
+}
+
+// LoadConfigFile reads a config file.
+func LoadConfigFile(path string) (ConfigFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := ParseConfigFile(f)
+	if err != nil {
+		return nil, NewUsageError(path, err)
+	}
+	// like credential files, files with secrets must be private
+	if fi, err := f.Stat(); err == nil && runtime.GOOS != "windows" && fi.Mode().Perm()&0077 != 0 && c.hasSecrets() {
+		return nil, NewUsageError("config file "+path+" contains credentials and is accessible by other users, use chmod 600", nil)
+	}
+	// resolve relative file names against the config file
+	dir := filepath.Dir(path)
+	for _, p := range c {
+		p.CABundle = resolvePath(dir, p.CABundle)
+		p.ClientCert = resolvePath(dir, p.ClientCert)
+		p.ClientKey = resolvePath(dir, p.ClientKey)
+	}
+	return c, nil
+}
+
+// hasSecrets returns true when a profile contains an API key, password or
+// client token.
+func (c ConfigFile) hasSecrets() bool {
+	for _, p := range c {
+		if p.Key != "" || p.Password != "" || p.ClientToken != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseConfigFile reads config file contents from r.
+func ParseConfigFile(r io.Reader) (ConfigFile, error) {
+	c := make(ConfigFile)
+	var p *ConfigProfile
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return nil, fmt.Errorf("line %d: invalid profile header", n)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty profile name", n)
+			}
+			if p = c[name]; p == nil {
+				p = &ConfigProfile{Name: name}
+				c[name] = p
+			}
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		if p == nil {
+			return nil, fmt.Errorf("line %d: setting outside of profile", n)
+		}
+		if err := p.set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+	}
+	return c, s.Err()
+}
+
+func (p *ConfigProfile) set(key, value string) error {
+	switch key {
+	case "api_server":
+		p.APIServer = value
+	case "cdn_server":
+		p.CDNServer = value
+	case "api_key":
+		p.Key = ApiKey(value)
+	case "auth":
+		switch value {
+		case AuthPassword, AuthToken, "":
+			p.Auth = value
+		default:
+			return fmt.Errorf("unknown auth method %q", value)
+		}
+	case "username":
+		p.Username = value
+	case "email":
+		p.Email = value
+	case "password":
+		p.Password = value
+	case "token":
+		p.ClientToken = ClientToken(value)
+	case "scopes":
+		p.Scopes = value
+	case "part_size":
+		size, err := parseSize(value)
+		if err != nil {
+			return err
+		}
+		p.PartSize = size
+	case "proxy":
+		if _, err := url.Parse(value); err != nil {
+			return fmt.Errorf("invalid proxy: %v", err)
+		}
+		p.Proxy = value
+	case "ca_bundle":
+		p.CABundle = value
+	case "client_cert":
+		p.ClientCert = value
+	case "client_key":
+		p.ClientKey = value
+	case "credential_file":
+		p.CredentialFile = value
+	case "credential_helper":
+		p.CredentialHelper = value
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	return nil
+}
+
+// parseSize parses a byte size with optional binary unit suffix.
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mult = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+func resolvePath(dir, path string) string {
+	switch {
+	case path == "":
+		return ""
+	case strings.HasPrefix(path, "~/"):
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+		return path
+	case filepath.IsAbs(path):
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// LoadProfile reads a profile from the default config file. An empty name
+// selects the profile named in $TRIMMER_PROFILE or DefaultProfile. It
+// returns nil without error when no profile was explicitly requested and
+// the config file or the default profile does not exist.
+func LoadProfile(name string) (*ConfigProfile, error) {
+	explicit := true
+	if name == "" {
+		name = os.Getenv(TRIMMER_PROFILE_KEY)
+	}
+	if name == "" {
+		name, explicit = DefaultProfile, false
+	}
+	c, err := LoadConfigFile(DefaultConfigPath())
+	if os.IsNotExist(err) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, NewUsageError("loading config file failed", err)
+	}
+	p, ok := c[name]
+	if !ok {
+		if !explicit {
+			return nil, nil
+		}
+		return nil, NewUsageError("profile "+quote(name)+" not found", nil)
+	}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// load prepares the HTTP transport of a profile.
+func (p *ConfigProfile) load() error {
+	if p.Proxy == "" && p.CABundle == "" && p.ClientCert == "" {
+		return nil
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if p.Proxy != "" {
+		u, err := url.Parse(p.Proxy)
+		if err != nil {
+			return NewUsageError("invalid proxy", err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	if p.CABundle != "" || p.ClientCert != "" {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	if p.CABundle != "" {
+		pem, err := ioutil.ReadFile(p.CABundle)
+		if err != nil {
+			return NewUsageError("reading CA bundle failed", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return NewUsageError("no certificates found in CA bundle "+p.CABundle, nil)
+		}
+		t.TLSClientConfig.RootCAs = pool
+	}
+	if p.ClientCert != "" {
+		key := p.ClientKey
+		if key == "" {
+			key = p.ClientCert
+		}
+		cert, err := tls.LoadX509KeyPair(p.ClientCert, key)
+		if err != nil {
+			return NewUsageError("loading client certificate failed", err)
+		}
+		t.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+	p.transport = t
+	return nil
+}
+
+// Transport returns the HTTP transport for the profile's proxy and TLS
+// settings or nil when the profile uses the defaults.
+func (p *ConfigProfile) Transport() http.RoundTripper {
+	if p == nil || p.transport == nil {
+		return nil
+	}
+	return p.transport
+}
+
+// LoginParams returns the profile's login credentials.
+func (p *ConfigProfile) LoginParams() *LoginParams {
+	return &LoginParams{
+		Username: p.Username,
+		Email:    p.Email,
+		Password: p.Password,
+		Scopes:   p.Scopes,
+	}
+}
+
+// CredentialProvider returns a provider for the profile's credential file
+// and helper, asking the file first, or nil when the profile has neither.
+func (p *ConfigProfile) CredentialProvider() CredentialProvider {
+	if p == nil {
+		return nil
+	}
+	var chain ChainCredentials
+	if p.CredentialFile != "" {
+		chain = append(chain, NewFileCredentials(p.CredentialFile))
+	}
+	if p.CredentialHelper != "" {
+		chain = append(chain, NewHelperCredentials(p.CredentialHelper))
+	}
+	switch len(chain) {
+	case 0:
+		return nil
+	case 1:
+		return chain[0]
+	}
+	return chain
+}
+
+// UseProfile loads a profile and applies it to the package-level settings
+// and backends. An empty name selects the profile from $TRIMMER_PROFILE.
+// Settings of the previous profile that the new one does not set return to
+// their defaults, environment variables override the profile.
+func UseProfile(name string) error {
+	p, err := LoadProfile(name)
+	if err != nil {
+		return err
+	}
+	profileErr = nil
+	resetProfile()
+	applyProfile(p)
+	return nil
+}
+
+// profileCredentials is true while the credential provider is the one set
+// by the active profile.
+var profileCredentials bool
+
+// resetProfile returns the settings of the active profile to their defaults
+// while they are still in effect, so the next profile does not inherit them.
+func resetProfile() {
+	p := activeProfile
+	if p == nil {
+		return
+	}
+	if p.Key != "" && Key == p.Key {
+		Key = ""
+	}
+	if p.APIServer != "" && apiURL == p.APIServer {
+		apiURL = defaultAPIURL
+	}
+	if p.CDNServer != "" && cdnURL == p.CDNServer {
+		cdnURL = defaultCDNURL
+	}
+	if p.PartSize > 0 && UploadPartSize == p.PartSize {
+		UploadPartSize = DefaultPartSize
+	}
+	if profileCredentials {
+		SetCredentialProvider(nil)
+	}
+	if t := p.Transport(); t != nil {
+		if apiHttpClient.Transport == t {
+			apiHttpClient = &http.Client{Timeout: defaultHTTPTimeout}
+		}
+		if cdnHttpClient.Transport == t {
+			cdnHttpClient = &http.Client{}
+		}
+	}
+}
+
+// applyProfile applies p, which may be nil, and the environment to the
+// package-level settings and backends.
+func applyProfile(p *ConfigProfile) {
+	activeProfile = p
+	if p != nil {
+		if p.Key != "" {
+			Key = p.Key
+		}
+		if p.APIServer != "" {
+			apiURL = p.APIServer
+		}
+		if p.CDNServer != "" {
+			cdnURL = p.CDNServer
+		}
+		if p.PartSize > 0 {
+			UploadPartSize = p.PartSize
+		}
+		if cp := p.CredentialProvider(); cp != nil {
+			SetCredentialProvider(cp)
+			profileCredentials = true
+		}
+		if t := p.Transport(); t != nil {
+			apiHttpClient = &http.Client{Timeout: defaultHTTPTimeout, Transport: t}
+			cdnHttpClient = &http.Client{Transport: t}
+		}
+	}
+	applyEnv()
+	if b, ok := backends.API.(BackendConfiguration); ok {
+		b.URL, b.HTTPClient, b.err = apiURL, apiHttpClient, profileErr
+		backends.API = b
+	}
+	if b, ok := backends.CDN.(BackendConfiguration); ok {
+		b.URL, b.HTTPClient, b.err = cdnURL, cdnHttpClient, profileErr
+		backends.CDN = b
+	}
+}
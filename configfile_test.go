@@ -0,0 +1,208 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const testConfigFile = `
+# test profiles
+[default]
+api_key  = default-key
+username = jane
+password = secret
+
+[staging]
+api_server = https://api.staging.example.com
+api_key    = staging-key
+auth       = token
+token      = staging-token
+part_size  = 64MiB
+ca_bundle  = certs/ca.pem
+`
+
+func TestParseConfigFile(t *testing.T) {
+	c, err := ParseConfigFile(strings.NewReader(testConfigFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c) != 2 {
+		t.Fatalf("got %d profiles, want 2", len(c))
+	}
+	p := c["staging"]
+	if p.APIServer != "https://api.staging.example.com" || p.Key != "staging-key" || p.Auth != AuthToken || p.ClientToken != "staging-token" {
+		t.Errorf("unexpected profile %+v", p)
+	}
+	if p.PartSize != 64<<20 {
+		t.Errorf("got part size %d, want %d", p.PartSize, 64<<20)
+	}
+
+	for _, s := range []string{
+		"api_key = x",
+		"[default]\nunknown = x",
+		"[default]\nauth = oauth",
+		"[default]\npart_size = lots",
+		"[default\n",
+	} {
+		if _, err := ParseConfigFile(strings.NewReader(s)); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte(testConfigFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(TRIMMER_CONFIG_KEY, path)
+
+	t.Setenv(TRIMMER_PROFILE_KEY, "")
+	p, err := LoadProfile("")
+	if err != nil || p == nil || p.Name != DefaultProfile {
+		t.Fatalf("expected default profile, got %v %v", p, err)
+	}
+	if l := p.LoginParams(); l.Username != "jane" || l.Password != "secret" {
+		t.Errorf("unexpected login params %+v", l)
+	}
+
+	t.Setenv(TRIMMER_PROFILE_KEY, "missing")
+	if _, err := LoadProfile(""); err == nil {
+		t.Error("expected error for missing profile")
+	}
+
+	// the CA bundle path is relative to the config file and does not exist
+	if _, err := LoadProfile("staging"); err == nil || !strings.Contains(err.Error(), filepath.Join(dir, "certs")) {
+		t.Errorf("expected CA bundle error, got %v", err)
+	}
+
+	t.Setenv(TRIMMER_CONFIG_KEY, filepath.Join(dir, "none"))
+	t.Setenv(TRIMMER_PROFILE_KEY, "")
+	if p, err := LoadProfile(""); p != nil || err != nil {
+		t.Errorf("expected no profile without config file, got %v %v", p, err)
+	}
+}
+
+func TestWithProfile(t *testing.T) {
+	c, _ := ParseConfigFile(strings.NewReader(testConfigFile))
+	p := c["staging"]
+	p.CABundle = ""
+	cfg := NewConfig(WithProfile(p), WithUploadPartSize(1<<20))
+	if cfg.Key != "staging-key" || cfg.APIURL != "https://api.staging.example.com" || cfg.Profile != p {
+		t.Errorf("profile not applied: %+v", cfg)
+	}
+	if cfg.UploadPartSize != 1<<20 {
+		t.Errorf("later option did not override profile part size")
+	}
+}
+
+func TestLoadConfigFilePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not checked on windows")
+	}
+	path := filepath.Join(t.TempDir(), "config")
+	if err := ioutil.WriteFile(path, []byte(testConfigFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfigFile(path); err == nil || !strings.Contains(err.Error(), "chmod 600") {
+		t.Errorf("expected permission error, got %v", err)
+	}
+
+	// files without secrets may be shared
+	if err := ioutil.WriteFile(path, []byte("[default]\napi_server = https://api.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfigFile(path); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestUseProfileResets(t *testing.T) {
+	key, api, cdn, b, prev := Key, apiHttpClient, cdnHttpClient, backends, activeProfile
+	apiu, cdnu, size := apiURL, cdnURL, UploadPartSize
+	cp, pcp, perr := credentialProvider, profileCredentials, profileErr
+	defer func() {
+		Key, apiHttpClient, cdnHttpClient, backends, activeProfile = key, api, cdn, b, prev
+		apiURL, cdnURL, UploadPartSize = apiu, cdnu, size
+		credentialProvider, profileCredentials, profileErr = cp, pcp, perr
+	}()
+	backends = Backends{}
+	GetBackend(APIBackend)
+
+	path := filepath.Join(t.TempDir(), "config")
+	config := "[full]\napi_key = full-key\napi_server = https://api.full.example.com\ncdn_server = https://cdn.full.example.com\n" +
+		"part_size = 64M\ncredential_helper = trimmer-credentials\nproxy = http://proxy.example.com:3128\n\n" +
+		"[plain]\ncdn_server = https://cdn.plain.example.com\n"
+	if err := ioutil.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(TRIMMER_CONFIG_KEY, path)
+	t.Setenv(TRIMMER_API_KEY_KEY, "env-key")
+	t.Setenv(TRIMMER_API_SERVER_KEY, "")
+	t.Setenv(TRIMMER_CDN_SERVER_KEY, "")
+
+	if err := UseProfile("full"); err != nil {
+		t.Fatal(err)
+	}
+	// the environment overrides the profile
+	if Key != "env-key" || apiURL != "https://api.full.example.com" || UploadPartSize != 64<<20 || credentialProvider == nil || apiHttpClient.Transport == nil {
+		t.Fatalf("profile not applied: key %q, api %q, part size %d, credentials %v, transport %v", Key, apiURL, UploadPartSize, credentialProvider, apiHttpClient.Transport)
+	}
+	t.Setenv(TRIMMER_API_KEY_KEY, "")
+	if err := UseProfile("full"); err != nil || Key != "full-key" {
+		t.Fatalf("profile key not applied: %q, %v", Key, err)
+	}
+
+	// settings the next profile lacks return to their defaults
+	if err := UseProfile("plain"); err != nil {
+		t.Fatal(err)
+	}
+	if Key != "" || apiURL != defaultAPIURL || cdnURL != "https://cdn.plain.example.com" || UploadPartSize != DefaultPartSize {
+		t.Errorf("previous profile kept: key %q, api %q, cdn %q, part size %d", Key, apiURL, cdnURL, UploadPartSize)
+	}
+	if credentialProvider != nil || apiHttpClient.Transport != nil || cdnHttpClient.Transport != nil {
+		t.Errorf("previous profile kept: credentials %v, transport %v", credentialProvider, apiHttpClient.Transport)
+	}
+	if b := GetBackend(APIBackend).(BackendConfiguration); b.URL != defaultAPIURL || b.Credentials != nil {
+		t.Errorf("backend kept previous profile: %q, %v", b.URL, b.Credentials)
+	}
+
+	// profiles that cannot be loaded at startup fail package-level calls
+	profileErr = errors.New("profile missing")
+	applyProfile(nil)
+	if _, err := CurrentProfile(); err != profileErr {
+		t.Errorf("got profile error %v", err)
+	}
+	if err := GetBackend(APIBackend).Call(context.Background(), http.MethodGet, "/users/me", "key", nil, nil, nil, nil); err != profileErr {
+		t.Errorf("got call error %v, want profile error", err)
+	}
+	if err := UseProfile("plain"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CurrentProfile(); err != nil {
+		t.Errorf("profile error kept after UseProfile: %v", err)
+	}
+}
@@ -0,0 +1,325 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials are the secrets used to talk to a Trimmer server. Empty fields
+// are unknown to the provider that returned them.
+type Credentials struct {
+	Key         ApiKey      `json:"apiKey,omitempty"`
+	Username    string      `json:"username,omitempty"`
+	Email       string      `json:"email,omitempty"`
+	Password    string      `json:"password,omitempty"`
+	ClientToken ClientToken `json:"clientToken,omitempty"`
+}
+
+// String hides secrets when credentials are logged.
+func (c Credentials) String() string {
+	s := make([]string, 0, 5)
+	if c.Key != "" {
+		s = append(s, "apiKey="+redacted)
+	}
+	if c.Username != "" {
+		s = append(s, "username="+quote(c.Username))
+	}
+	if c.Email != "" {
+		s = append(s, "email="+quote(c.Email))
+	}
+	if c.Password != "" {
+		s = append(s, "password="+redacted)
+	}
+	if c.ClientToken != "" {
+		s = append(s, "clientToken="+redacted)
+	}
+	return "{" + strings.Join(s, " ") + "}"
+}
+
+// LoginParams returns login parameters for the credentials.
+func (c Credentials) LoginParams(scopes string) *LoginParams {
+	return &LoginParams{
+		Username: c.Username,
+		Email:    c.Email,
+		Password: c.Password,
+		Scopes:   scopes,
+	}
+}
+
+// merge fills empty fields from o.
+func (c *Credentials) merge(o *Credentials) {
+	if c.Key == "" {
+		c.Key = o.Key
+	}
+	if c.Username == "" && c.Email == "" {
+		c.Username, c.Email = o.Username, o.Email
+	}
+	if c.Password == "" {
+		c.Password = o.Password
+	}
+	if c.ClientToken == "" {
+		c.ClientToken = o.ClientToken
+	}
+}
+
+// CredentialRequest describes the credentials a provider is asked for.
+type CredentialRequest struct {
+	Server  string `json:"server"`            // API server URL
+	Profile string `json:"profile,omitempty"` // config file profile, if any
+}
+
+// CredentialProvider resolves secrets when they are first needed instead of
+// reading them from environment variables at startup. Providers return nil
+// credentials without error when they have none.
+//
+// Requests without an API key, NewClientSession without a token and
+// session.Login without username and password ask the provider of the
+// backend, which defaults to the one set with SetCredentialProvider.
+type CredentialProvider interface {
+	Credentials(ctx context.Context, req CredentialRequest) (*Credentials, error)
+}
+
+var credentialProvider CredentialProvider
+
+// SetCredentialProvider sets the provider used by the package-level
+// backends and sessions. Use nil to disable lazy credential resolution.
+func SetCredentialProvider(p CredentialProvider) {
+	credentialProvider = p
+	profileCredentials = false
+	if b, ok := backends.API.(BackendConfiguration); ok {
+		b.Credentials = p
+		backends.API = b
+	}
+	if b, ok := backends.CDN.(BackendConfiguration); ok {
+		b.Credentials = p
+		backends.CDN = b
+	}
+}
+
+// ResolveCredentials asks the credential provider of backend b, or the
+// package-level provider when b has none, for credentials. It returns empty
+// credentials when no provider is configured.
+func ResolveCredentials(ctx context.Context, b Backend) (*Credentials, error) {
+	p, server := credentialProvider, apiURL
+	switch c := b.(type) {
+	case BackendConfiguration:
+		p, server = c.Credentials, c.URL
+	case *BackendConfiguration:
+		p, server = c.Credentials, c.URL
+	}
+	return resolveCredentials(ctx, p, server)
+}
+
+func resolveCredentials(ctx context.Context, p CredentialProvider, server string) (*Credentials, error) {
+	if p == nil {
+		return &Credentials{}, nil
+	}
+	req := CredentialRequest{Server: server}
+	if activeProfile != nil {
+		req.Profile = activeProfile.Name
+	}
+	c, err := p.Credentials(ctx, req)
+	if err != nil {
+		return nil, NewUsageError("resolving credentials failed", err)
+	}
+	if c == nil {
+		c = &Credentials{}
+	}
+	return c, nil
+}
+
+// ---------------------------------------------------------------------------
+// Providers
+//
+
+// StaticCredentials always returns the same credentials.
+type StaticCredentials Credentials
+
+func (s StaticCredentials) Credentials(_ context.Context, _ CredentialRequest) (*Credentials, error) {
+	c := Credentials(s)
+	return &c, nil
+}
+
+// EnvCredentials reads credentials from the TRIMMER_API_KEY,
+// TRIMMER_API_USERNAME, TRIMMER_API_PASSWORD and TRIMMER_CLIENT_TOKEN
+// environment variables when asked.
+type EnvCredentials struct{}
+
+func (EnvCredentials) Credentials(_ context.Context, _ CredentialRequest) (*Credentials, error) {
+	return &Credentials{
+		Key:         ApiKey(os.Getenv(TRIMMER_API_KEY_KEY)),
+		Username:    os.Getenv(TRIMMER_USERNAME_KEY),
+		Password:    os.Getenv(TRIMMER_PASSWORD_KEY),
+		ClientToken: ClientToken(os.Getenv(TRIMMER_CLIENT_TOKEN_KEY)),
+	}, nil
+}
+
+// ChainCredentials asks providers in order and merges their answers. Fields
+// set by earlier providers take precedence, so a chain of EnvCredentials and
+// a helper lets environment variables override the helper.
+type ChainCredentials []CredentialProvider
+
+func (ch ChainCredentials) Credentials(ctx context.Context, req CredentialRequest) (*Credentials, error) {
+	res := &Credentials{}
+	for _, p := range ch {
+		c, err := p.Credentials(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			res.merge(c)
+		}
+	}
+	return res, nil
+}
+
+// FileCredentials reads credentials from a JSON file like
+//
+//	{"apiKey": "...", "username": "jane", "password": "..."}
+//
+// The file must not be readable or writable by group or others. It is read
+// again when it changes.
+type FileCredentials struct {
+	Path string
+
+	mu    sync.Mutex
+	mtime time.Time
+	creds *Credentials
+}
+
+// NewFileCredentials creates a provider for the credentials file at path.
+func NewFileCredentials(path string) *FileCredentials {
+	return &FileCredentials{Path: path}
+}
+
+func (f *FileCredentials) Credentials(_ context.Context, _ CredentialRequest) (*Credentials, error) {
+	fi, err := os.Stat(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm()&0077 != 0 {
+		return nil, NewUsageError("credentials file "+f.Path+" is accessible by other users, use chmod 600", nil)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.creds != nil && fi.ModTime().Equal(f.mtime) {
+		c := *f.creds
+		return &c, nil
+	}
+	b, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Credentials{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, NewUsageError("parsing credentials file "+f.Path+" failed", err)
+	}
+	f.creds, f.mtime = c, fi.ModTime()
+	cc := *c
+	return &cc, nil
+}
+
+// HelperCredentials runs an external command to obtain credentials, similar
+// to git credential helpers. The command is called with the extra argument
+// "get", receives a CredentialRequest as JSON on stdin and must print
+// Credentials as JSON to stdout. A failing helper fails the request, its
+// stderr output becomes part of the error.
+//
+// Answers are cached per server for TTL, or for the lifetime of the provider
+// when TTL is zero. Call Forget to ask the helper again, e.g. after a login
+// was rejected.
+type HelperCredentials struct {
+	Command string
+	Args    []string
+	TTL     time.Duration
+
+	mu    sync.Mutex
+	cache map[CredentialRequest]helperAnswer
+}
+
+type helperAnswer struct {
+	creds   Credentials
+	expires time.Time
+}
+
+// NewHelperCredentials creates a provider for a helper command line. The
+// command line is split at white space.
+func NewHelperCredentials(cmdline string) *HelperCredentials {
+	f := strings.Fields(cmdline)
+	if len(f) == 0 {
+		return &HelperCredentials{}
+	}
+	return &HelperCredentials{Command: f[0], Args: f[1:]}
+}
+
+func (h *HelperCredentials) Credentials(ctx context.Context, req CredentialRequest) (*Credentials, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if a, ok := h.cache[req]; ok && (a.expires.IsZero() || time.Now().Before(a.expires)) {
+		c := a.creds
+		return &c, nil
+	}
+	if h.Command == "" {
+		return nil, NewUsageError("credential helper command missing", nil)
+	}
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.Command, append(h.Args, "get")...)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := "credential helper " + h.Command + " failed"
+		if s := strings.TrimSpace(stderr.String()); s != "" {
+			msg += ": " + s
+		}
+		return nil, NewUsageError(msg, err)
+	}
+	c := Credentials{}
+	if err := json.Unmarshal(stdout.Bytes(), &c); err != nil {
+		return nil, NewUsageError("credential helper "+h.Command+" returned invalid JSON", err)
+	}
+	a := helperAnswer{creds: c}
+	if h.TTL > 0 {
+		a.expires = time.Now().Add(h.TTL)
+	}
+	if h.cache == nil {
+		h.cache = make(map[CredentialRequest]helperAnswer)
+	}
+	h.cache[req] = a
+	return &c, nil
+}
+
+// Forget drops all cached answers.
+func (h *HelperCredentials) Forget() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cache = nil
+}
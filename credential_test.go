@@ -0,0 +1,140 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestHelperProcess is run as credential helper by TestHelperCredentials.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("TRIMMER_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+	if args := os.Args; args[len(args)-1] != "get" {
+		fmt.Fprintln(os.Stderr, "unexpected operation", args[len(args)-1])
+		os.Exit(1)
+	}
+	req := CredentialRequest{}
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if req.Server == "" {
+		fmt.Fprintln(os.Stderr, "no server")
+		os.Exit(2)
+	}
+	json.NewEncoder(os.Stdout).Encode(Credentials{Key: "helper-key", Password: "helper-" + req.Server})
+}
+
+func newTestHelper() *HelperCredentials {
+	os.Setenv("TRIMMER_WANT_HELPER_PROCESS", "1")
+	return &HelperCredentials{Command: os.Args[0], Args: []string{"-test.run=TestHelperProcess", "--"}}
+}
+
+func TestHelperCredentials(t *testing.T) {
+	LogLevel = 0
+	defer os.Unsetenv("TRIMMER_WANT_HELPER_PROCESS")
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("X-API-Key"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	h := newTestHelper()
+	b := newTestBackend(srv.URL)
+	b.Credentials = h
+	for i := 0; i < 2; i++ {
+		if err := b.Call(context.Background(), http.MethodGet, "/x", "", nil, nil, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Call(context.Background(), http.MethodGet, "/x", "explicit", nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(keys, ",") != "helper-key,helper-key,explicit" {
+		t.Errorf("got keys %q", keys)
+	}
+	if len(h.cache) != 1 {
+		t.Errorf("expected one cached answer, got %d", len(h.cache))
+	}
+
+	c, err := ResolveCredentials(context.Background(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Password != "helper-"+srv.URL {
+		t.Errorf("got password %q", c.Password)
+	}
+
+	h.Forget()
+	if _, err := h.Credentials(context.Background(), CredentialRequest{}); err == nil || !strings.Contains(err.Error(), "no server") {
+		t.Errorf("expected helper error with stderr output, got %v", err)
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := ioutil.WriteFile(path, []byte(`{"apiKey":"file-key","username":"jane","password":"secret"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f := NewFileCredentials(path)
+	if runtime.GOOS != "windows" {
+		if _, err := f.Credentials(context.Background(), CredentialRequest{}); err == nil {
+			t.Error("expected error for world readable credentials file")
+		}
+	}
+	os.Chmod(path, 0600)
+	c, err := f.Credentials(context.Background(), CredentialRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Key != "file-key" || c.Username != "jane" || c.Password != "secret" {
+		t.Errorf("unexpected credentials %v", c)
+	}
+	if s := c.String(); strings.Contains(s, "secret") || strings.Contains(s, "file-key") {
+		t.Errorf("secrets not hidden in %s", s)
+	}
+}
+
+func TestChainCredentials(t *testing.T) {
+	ch := ChainCredentials{
+		StaticCredentials{Key: "first"},
+		StaticCredentials{Key: "second", Email: "jane@example.com", Password: "secret"},
+		StaticCredentials{Username: "jane", Password: "other"},
+	}
+	c, err := ch.Credentials(context.Background(), CredentialRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Key != "first" || c.Email != "jane@example.com" || c.Username != "" || c.Password != "secret" {
+		t.Errorf("unexpected merged credentials %+v", *c)
+	}
+}
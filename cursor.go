@@ -0,0 +1,112 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+)
+
+// ListOrder is the direction in which iterators walk a list.
+type ListOrder string
+
+const (
+	LIST_NEWEST_FIRST ListOrder = ""    // newest to oldest, the default
+	LIST_OLDEST_FIRST ListOrder = "asc" // oldest to newest
+)
+
+// cursor is the position of an iterator in a list. MaxId and MinId are the
+// query parameters of a page: the anchor the page starts at and the bound
+// in the other direction. Skip is the number of items at the start of the
+// page that have already been returned.
+type cursor struct {
+	Order  ListOrder `json:"o,omitempty"`
+	MaxId  string    `json:"max,omitempty"`
+	MinId  string    `json:"min,omitempty"`
+	Skip   int       `json:"skip,omitempty"`
+	Before string    `json:"b,omitempty"`
+	After  string    `json:"a,omitempty"`
+	Count  string    `json:"c,omitempty"`
+}
+
+// startCursor returns the position of the first page for list parameters.
+// maxId is inclusive, so a MaxId set when listing newest first is skipped
+// and iteration continues after it. minId is exclusive.
+func startCursor(p ListParams) cursor {
+	c := cursor{Order: p.Order, MaxId: p.MaxId, MinId: p.MinId}
+	if p.Order != LIST_OLDEST_FIRST && p.MaxId != "" {
+		c.Skip = 1
+	}
+	return c
+}
+
+// follow returns the position of the page after the page described by
+// meta.
+func (c cursor) follow(meta ListMeta) cursor {
+	c.Skip = 0
+	if c.Order == LIST_OLDEST_FIRST {
+		c.MinId = meta.MaxId
+	} else {
+		c.MaxId = meta.MinId
+		c.Skip = 1
+	}
+	return c
+}
+
+// window returns the cursor with the time window and page size of the
+// list query q.
+func (c cursor) window(q url.Values) cursor {
+	c.Before, c.After, c.Count = q.Get(before), q.Get(after), q.Get("count")
+	return c
+}
+
+// apply sets the page query parameters.
+func (c cursor) apply(q url.Values) {
+	for k, v := range map[string]string{maxId: c.MaxId, minId: c.MinId} {
+		if v != "" {
+			q.Set(k, v)
+		} else {
+			q.Del(k)
+		}
+	}
+	if c.Order == LIST_OLDEST_FIRST {
+		q.Set("order", string(c.Order))
+	}
+}
+
+// encode returns the cursor as opaque token.
+func (c cursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// parseCursor decodes a token created by encode.
+func parseCursor(token string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, NewUsageError("invalid list cursor", err)
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, NewUsageError("invalid list cursor", err)
+	}
+	if c.Skip < 0 || (c.Order != LIST_NEWEST_FIRST && c.Order != LIST_OLDEST_FIRST) {
+		return c, NewUsageError("invalid list cursor", nil)
+	}
+	return c, nil
+}
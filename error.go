@@ -18,9 +18,14 @@ package trimmer
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
+	"net/http"
 	"strconv"
 	"strings"
+	"time"
+
+	"trimmer.io/go-trimmer/hash"
 )
 
 type Error interface {
@@ -49,17 +54,42 @@ type TrimmerError struct {
 	Message     string `json:"message,omitempty"`
 	Scope       string `json:"scope,omitempty"`
 	Detail      string `json:"detail,omitempty"`
-	Cause       error  `json:"cause,omitempty"`
+
+	// Err is the underlying error, available through Cause and Unwrap.
+	Err error `json:"-"`
+
+	// RetryAfter is the time the server asked to wait before sending another
+	// request (from Retry-After or X-RateLimit-Reset headers).
+	RetryAfter time.Duration `json:"-"`
+
+	kind errorKind     // class of sentinel errors
+	list *apiErrorList // all errors of an API response
 }
 
 func (e TrimmerError) IsUsage() bool    { return (e.typ & usageError) != 0 }
 func (e TrimmerError) IsInternal() bool { return (e.typ & internalError) != 0 }
 func (e TrimmerError) IsApi() bool      { return (e.typ & apiError) != 0 }
+func (e TrimmerError) Cause() error     { return e.Err }
+func (e TrimmerError) Unwrap() error    { return e.Err }
+
+// Errors returns all errors of an API response, starting with e. The server
+// may report more than one problem, e.g. one per invalid field.
+func (e TrimmerError) Errors() []TrimmerError {
+	if e.list == nil || len(*e.list) == 0 {
+		return []TrimmerError{e}
+	}
+	return *e.list
+}
 
 type apiErrorList []TrimmerError
 
 type apiErrorResponse struct {
 	Errors apiErrorList `json:"errors"`
+
+	// OAuth 2.0 error response (RFC 6749 section 5.2), used by the
+	// authorization endpoints
+	OAuthError       string `json:"error,omitempty"`
+	OAuthDescription string `json:"error_description,omitempty"`
 }
 
 func NewApiError(status int) TrimmerError {
@@ -73,7 +103,7 @@ func NewUsageError(msg string, err error) TrimmerError {
 	return TrimmerError{
 		typ:     usageError,
 		Message: msg,
-		Cause:   err,
+		Err:     err,
 	}
 }
 
@@ -81,7 +111,7 @@ func NewInternalError(msg string, err error) TrimmerError {
 	return TrimmerError{
 		typ:     internalError,
 		Message: msg,
-		Cause:   err,
+		Err:     err,
 	}
 }
 
@@ -113,8 +143,11 @@ func (e TrimmerError) Error() string {
 	if e.Detail != "" {
 		s = append(s, strings.Join([]string{"detail", quote(e.Detail)}, "="))
 	}
-	if e.Cause != nil {
-		s = append(s, strings.Join([]string{"cause", quote(e.Cause.Error())}, "="))
+	if e.list != nil && len(*e.list) > 1 {
+		s = append(s, strings.Join([]string{"more_errors", strconv.Itoa(len(*e.list) - 1)}, "="))
+	}
+	if e.Err != nil {
+		s = append(s, strings.Join([]string{"cause", quote(e.Err.Error())}, "="))
 	}
 	return strings.Join(s, " ")
 }
@@ -125,9 +158,7 @@ func ParseApiError(i io.Reader) TrimmerError {
 	if err := jsonDecoder.Decode(&response); err != nil {
 		return NewInternalError("parsing API error failed", err)
 	}
-	e := response.Errors[0]
-	e.typ = apiError
-	return e
+	return response.first()
 }
 
 func ParseApiErrorFromByte(b []byte) TrimmerError {
@@ -135,14 +166,39 @@ func ParseApiErrorFromByte(b []byte) TrimmerError {
 	if err := json.Unmarshal(b, &response); err != nil {
 		return NewInternalError("parsing API error failed", err)
 	}
-	e := response.Errors[0]
-	e.typ = apiError
+	return response.first()
+}
+
+// isOAuthPending returns true for OAuth errors that ask a client polling for
+// a device login to wait (RFC 8628 section 3.5). They are expected until the
+// user approves the login.
+func (e TrimmerError) isOAuthPending() bool {
+	return e.IsApi() && (e.Message == "authorization_pending" || e.Message == "slow_down")
+}
+
+// first returns the first error of a response with the full list attached.
+// OAuth errors are returned with the error code as Message and the
+// description as Detail.
+func (r apiErrorResponse) first() TrimmerError {
+	if len(r.Errors) == 0 && r.OAuthError != "" {
+		return TrimmerError{typ: apiError, Message: r.OAuthError, Detail: r.OAuthDescription}
+	}
+	if len(r.Errors) == 0 {
+		return TrimmerError{typ: apiError, Message: "no error details"}
+	}
+	list := make(apiErrorList, len(r.Errors))
+	for i, e := range r.Errors {
+		e.typ = apiError
+		list[i] = e
+	}
+	e := list[0]
+	e.list = &list
 	return e
 }
 
 func (e TrimmerError) MarshalIndent() []byte {
 	errResp := apiErrorResponse{
-		Errors: []TrimmerError{e},
+		Errors: e.Errors(),
 	}
 	b, _ := json.MarshalIndent(errResp, "", "  ")
 	return b
@@ -150,12 +206,126 @@ func (e TrimmerError) MarshalIndent() []byte {
 
 func (e TrimmerError) Marshal() []byte {
 	errResp := apiErrorResponse{
-		Errors: []TrimmerError{e},
+		Errors: e.Errors(),
 	}
 	b, _ := json.Marshal(errResp)
 	return b
 }
 
+// ---------------------------------------------------------------------------
+// Error Classes
+//
+// Errors returned by the SDK match the class sentinels below with errors.Is,
+// so callers can branch on failures without checking status codes:
+//
+//	if errors.Is(err, trimmer.ENotFound) {
+//		...
+//	}
+//
+// Use errors.As with a TrimmerError to access request ids, messages, the
+// full list of API errors and the time to wait after rate limiting.
+type errorKind int
+
+const (
+	kindNotFound errorKind = iota + 1
+	kindUnauthorized
+	kindForbidden
+	kindConflict
+	kindRateLimited
+	kindValidationFailed
+	kindChecksumMismatch
+	kindTransient
+)
+
+var (
+	ENotFound         = TrimmerError{typ: apiError, kind: kindNotFound, Message: "not found"}
+	EUnauthorized     = TrimmerError{typ: apiError, kind: kindUnauthorized, Message: "unauthorized"}
+	EForbidden        = TrimmerError{typ: apiError, kind: kindForbidden, Message: "forbidden"}
+	EConflict         = TrimmerError{typ: apiError, kind: kindConflict, Message: "conflict"}
+	ERateLimited      = TrimmerError{typ: apiError, kind: kindRateLimited, Message: "rate limited"}
+	EValidationFailed = TrimmerError{typ: apiError, kind: kindValidationFailed, Message: "validation failed"}
+	EChecksumMismatch = TrimmerError{typ: internalError, kind: kindChecksumMismatch, Message: "checksum mismatch"}
+	ETransient        = TrimmerError{typ: internalError, kind: kindTransient, Message: "transient failure"}
+)
+
+// Is reports whether e belongs to the class of a sentinel error like
+// ENotFound or equals a usage error like EIDMissing.
+func (e TrimmerError) Is(target error) bool {
+	t, ok := target.(TrimmerError)
+	if !ok {
+		return false
+	}
+	if t.kind == 0 {
+		return t.list == nil && e.typ == t.typ && e.Message == t.Message && e.StatusCode == t.StatusCode
+	}
+	for _, x := range e.Errors() {
+		if x.isKind(t.kind) {
+			return true
+		}
+	}
+	return e.isKind(t.kind)
+}
+
+func (e TrimmerError) isKind(k errorKind) bool {
+	if e.kind == k {
+		return true
+	}
+	if e.IsApi() {
+		switch k {
+		case kindNotFound:
+			return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+		case kindUnauthorized:
+			return e.StatusCode == http.StatusUnauthorized
+		case kindForbidden:
+			return e.StatusCode == http.StatusForbidden
+		case kindConflict:
+			return e.StatusCode == http.StatusConflict || e.StatusCode == http.StatusPreconditionFailed
+		case kindRateLimited:
+			return e.StatusCode == http.StatusTooManyRequests
+		case kindValidationFailed:
+			return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+		case kindTransient:
+			return isTransientStatus(e.StatusCode)
+		}
+		return false
+	}
+	switch k {
+	case kindChecksumMismatch:
+		return errors.Is(e.Err, hash.EInvalidHash)
+	case kindTransient:
+		switch ClassifyError(e) {
+		case ErrorClassNetwork, ErrorClassTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// NewChecksumError returns an EChecksumMismatch error for data whose actual
+// hashes differ from the expected hashes.
+func NewChecksumError(expected, actual hash.HashBlock) TrimmerError {
+	return TrimmerError{
+		typ:     internalError,
+		kind:    kindChecksumMismatch,
+		Message: "checksum mismatch",
+		Detail:  "expected " + expected.String() + ", got " + actual.String(),
+		Err:     hash.EInvalidHash,
+	}
+}
+
+// NewScopeError returns an EForbidden usage error for a call that requires
+// a scope the session has not been granted. Scope holds the missing scope
+// and OauthScopes the granted scopes.
+func NewScopeError(required ApiAccessScope, granted string) TrimmerError {
+	return TrimmerError{
+		typ:         usageError,
+		kind:        kindForbidden,
+		Message:     "session lacks required scope",
+		Scope:       string(required),
+		OauthScopes: granted,
+	}
+}
+
 var (
 	ENilPointer   = TrimmerError{typ: usageError, Message: "unexpected nil pointer"}
 	EIDMissing    = TrimmerError{typ: usageError, Message: "id value missing"}
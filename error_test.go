@@ -0,0 +1,110 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"trimmer.io/go-trimmer/hash"
+)
+
+func TestParseApiError(t *testing.T) {
+	e := ParseApiErrorFromByte([]byte(`{"errors":[]}`))
+	if !e.IsApi() || len(e.Errors()) != 1 {
+		t.Errorf("unexpected error for empty list: %v", e)
+	}
+
+	e = ParseApiErrorFromByte([]byte(`{"errors":[
+		{"status":400,"message":"name missing","scope":"name"},
+		{"status":409,"message":"already exists","scope":"email"}
+	]}`))
+	if e.Message != "name missing" || len(e.Errors()) != 2 || e.Errors()[1].Scope != "email" {
+		t.Fatalf("unexpected error list %v", e.Errors())
+	}
+	if !errors.Is(e, EValidationFailed) || !errors.Is(e, EConflict) || errors.Is(e, ENotFound) {
+		t.Errorf("unexpected error classes for %v", e)
+	}
+	if b := ParseApiErrorFromByte(e.Marshal()); len(b.Errors()) != 2 {
+		t.Errorf("marshal lost errors: %s", e.Marshal())
+	}
+
+	e = ParseApiErrorFromByte([]byte(`{"error":"authorization_pending","error_description":"waiting for user"}`))
+	if !e.IsApi() || e.Message != "authorization_pending" || e.Detail != "waiting for user" {
+		t.Errorf("unexpected OAuth error %v", e)
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	for _, c := range []struct {
+		err    error
+		target error
+		want   bool
+	}{
+		{NewApiError(http.StatusNotFound), ENotFound, true},
+		{NewApiError(http.StatusGone), ENotFound, true},
+		{NewApiError(http.StatusUnauthorized), EUnauthorized, true},
+		{NewApiError(http.StatusUnauthorized), EForbidden, false},
+		{NewApiError(http.StatusForbidden), EForbidden, true},
+		{NewScopeError(API_SCOPE_UPLOAD, "public"), EForbidden, true},
+		{NewApiError(http.StatusConflict), EConflict, true},
+		{NewApiError(http.StatusTooManyRequests), ERateLimited, true},
+		{NewApiError(http.StatusTooManyRequests), ETransient, true},
+		{NewApiError(http.StatusUnprocessableEntity), EValidationFailed, true},
+		{NewApiError(http.StatusBadGateway), ETransient, true},
+		{NewApiError(http.StatusNotFound), ETransient, false},
+		{NewInternalError("request failed", &StallError{Idle: 1}), ETransient, true},
+		{NewInternalError("request failed", context.Canceled), ETransient, false},
+		{NewChecksumError(hash.HashBlock{Md5: "a"}, hash.HashBlock{Md5: "b"}), EChecksumMismatch, true},
+		{NewChecksumError(hash.HashBlock{}, hash.HashBlock{}), hash.EInvalidHash, true},
+		{fmt.Errorf("get asset: %w", NewApiError(http.StatusNotFound)), ENotFound, true},
+		{fmt.Errorf("get asset: %w", EIDMissing), EIDMissing, true},
+		{EIDMissing, EParamMissing, false},
+	} {
+		if got := errors.Is(c.err, c.target); got != c.want {
+			t.Errorf("errors.Is(%v, %v) = %v, want %v", c.err, c.target, got, c.want)
+		}
+	}
+}
+
+func TestErrorAs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "req-1")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[{"message":"asset not found"}]}`))
+	}))
+	defer srv.Close()
+
+	b := newTestBackend(srv.URL)
+	err := b.Call(context.Background(), http.MethodGet, "/assets/1", "key", nil, nil, nil, nil)
+	var e TrimmerError
+	if !errors.As(fmt.Errorf("wrapped: %w", err), &e) {
+		t.Fatalf("expected TrimmerError, got %T", err)
+	}
+	if e.StatusCode != http.StatusNotFound || e.RequestId != "req-1" || e.Message != "asset not found" {
+		t.Errorf("unexpected error %v", e)
+	}
+	if !errors.Is(err, ENotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	var _ Error = e
+}
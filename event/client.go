@@ -25,10 +25,10 @@ import (
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.Event]
 }
 
 // Event returns the most recent Event visited by a call to Next.
 func (i *Iter) Event() *trimmer.Event {
-	return i.Current().(*trimmer.Event)
+	return i.Item()
 }
@@ -0,0 +1,274 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Instrumentation receives metrics and traces for backend calls. Every call
+// made through a BackendConfiguration, including checksummed CDN transfers,
+// is reported once after all of its retries have finished.
+//
+// See package telemetry for Prometheus and tracing adapters.
+type Instrumentation interface {
+	// StartSpan is called before a call is sent. The returned context is
+	// used for all attempts of the call and the span is ended when the call
+	// has finished.
+	StartSpan(ctx context.Context, call CallInfo) (context.Context, Span)
+
+	// ObserveCall is called once per call with its final statistics.
+	ObserveCall(stats CallStats)
+}
+
+// Span is a trace span covering a backend call.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End(err error)
+}
+
+// CallInfo identifies a backend call. Route is the path template with
+// resource ids replaced by placeholders, e.g. /assets/{id}/media, so it can
+// be used as low-cardinality metric label.
+type CallInfo struct {
+	Backend SupportedBackend
+	Method  string
+	Route   string
+}
+
+// CallStats contains the outcome of a backend call.
+type CallStats struct {
+	CallInfo
+	Status        int           // HTTP status of the last attempt, 0 when no response was received
+	Duration      time.Duration // total time including retries
+	BytesSent     int64         // request body bytes sent by all attempts
+	BytesReceived int64         // response body bytes read by all attempts
+	Retries       int
+	Error         ErrorClass
+	RequestId     string // X-Request-Id of the last response
+}
+
+// ErrorClass groups call errors for metrics.
+type ErrorClass string
+
+const (
+	ErrorClassNone      ErrorClass = ""
+	ErrorClassNetwork   ErrorClass = "network"
+	ErrorClassTimeout   ErrorClass = "timeout"
+	ErrorClassCanceled  ErrorClass = "canceled"
+	ErrorClassAuth      ErrorClass = "auth"
+	ErrorClassRateLimit ErrorClass = "ratelimit"
+	ErrorClassClient    ErrorClass = "client"
+	ErrorClassServer    ErrorClass = "server"
+	ErrorClassInternal  ErrorClass = "internal"
+)
+
+// Span attributes set by the SDK on every call.
+const (
+	AttrBackend       = "trimmer.backend"
+	AttrMethod        = "http.request.method"
+	AttrRoute         = "http.route"
+	AttrStatus        = "http.response.status_code"
+	AttrRequestId     = "trimmer.request_id"
+	AttrRetries       = "trimmer.retries"
+	AttrErrorClass    = "error.type"
+	AttrBytesSent     = "http.request.body.size"
+	AttrBytesReceived = "http.response.body.size"
+)
+
+var instrumentation Instrumentation
+
+// SetInstrumentation sets the instrumentation used by the package-level
+// backends. Use nil to disable it.
+func SetInstrumentation(i Instrumentation) {
+	instrumentation = i
+	if b, ok := backends.API.(BackendConfiguration); ok {
+		b.Instrumentation = i
+		backends.API = b
+	}
+	if b, ok := backends.CDN.(BackendConfiguration); ok {
+		b.Instrumentation = i
+		backends.CDN = b
+	}
+}
+
+// ClassifyError returns the class of an error returned by a backend call.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassNone
+	}
+	var e TrimmerError
+	if !errors.As(err, &e) {
+		return ErrorClassInternal
+	}
+	if e.IsApi() {
+		switch {
+		case e.StatusCode == 401 || e.StatusCode == 403:
+			return ErrorClassAuth
+		case e.StatusCode == 429:
+			return ErrorClassRateLimit
+		case e.StatusCode >= 500:
+			return ErrorClassServer
+		default:
+			return ErrorClassClient
+		}
+	}
+	var ne net.Error
+	var ue *url.Error
+	switch {
+	case e.Err == nil:
+		return ErrorClassInternal
+	case errors.Is(e.Err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.Is(e.Err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.As(e.Err, &ne) && ne.Timeout():
+		return ErrorClassTimeout
+	case errors.As(e.Err, &ue):
+		return ErrorClassNetwork
+	}
+	return ErrorClassInternal
+}
+
+// routeCollections are path segments followed by a resource id.
+var routeCollections = map[string]bool{
+	"assets":     true,
+	"events":     true,
+	"jobs":       true,
+	"links":      true,
+	"media":      true,
+	"members":    true,
+	"mounts":     true,
+	"orgs":       true,
+	"profiles":   true,
+	"replicas":   true,
+	"replies":    true,
+	"revisions":  true,
+	"stashes":    true,
+	"tags":       true,
+	"teams":      true,
+	"uploads":    true,
+	"users":      true,
+	"versions":   true,
+	"volumes":    true,
+	"workspaces": true,
+}
+
+// routeTemplate replaces resource ids in a request path with placeholders.
+// CDN volume prefixes become {volume}, downloads become /{file}.
+func routeTemplate(backend SupportedBackend, path string) string {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	if backend == CDNBackend {
+		found := false
+		for i, seg := range segs {
+			if seg == "uploads" || seg == "manifest.json" {
+				segs = append([]string{"{volume}"}, segs[i:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "/{file}"
+		}
+	}
+	for i := 1; i < len(segs); i++ {
+		if routeCollections[segs[i-1]] && segs[i] != "me" {
+			segs[i] = "{id}"
+		}
+	}
+	return "/" + strings.Join(segs, "/")
+}
+
+// callStats collects statistics while a call is running. Body sizes are
+// counted separately because the transport may still be writing the body
+// of a failed request when the call returns.
+type callStats struct {
+	CallStats
+	sent, received int64
+}
+
+// instrument reports a finished call.
+func instrument(i Instrumentation, span Span, c *callStats, err error) {
+	stats := &c.CallStats
+	stats.BytesSent = atomic.LoadInt64(&c.sent)
+	stats.BytesReceived = atomic.LoadInt64(&c.received)
+	stats.Error = ClassifyError(err)
+	span.SetAttribute(AttrStatus, stats.Status)
+	span.SetAttribute(AttrRequestId, stats.RequestId)
+	span.SetAttribute(AttrRetries, stats.Retries)
+	span.SetAttribute(AttrBytesSent, stats.BytesSent)
+	span.SetAttribute(AttrBytesReceived, stats.BytesReceived)
+	if stats.Error != ErrorClassNone {
+		span.SetAttribute(AttrErrorClass, string(stats.Error))
+	}
+	span.End(err)
+	i.ObserveCall(*stats)
+}
+
+// countingReader counts bytes read from a request or response body.
+type countingReader struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(r.n, int64(n))
+	return n, err
+}
+
+// MultiInstrumentation reports calls to all instrumentations in the list.
+func MultiInstrumentation(list ...Instrumentation) Instrumentation {
+	return multiInstrumentation(list)
+}
+
+type multiInstrumentation []Instrumentation
+
+func (m multiInstrumentation) StartSpan(ctx context.Context, call CallInfo) (context.Context, Span) {
+	spans := make(multiSpan, len(m))
+	for n, i := range m {
+		ctx, spans[n] = i.StartSpan(ctx, call)
+	}
+	return ctx, spans
+}
+
+func (m multiInstrumentation) ObserveCall(stats CallStats) {
+	for _, i := range m {
+		i.ObserveCall(stats)
+	}
+}
+
+type multiSpan []Span
+
+func (m multiSpan) SetAttribute(key string, value interface{}) {
+	for _, s := range m {
+		s.SetAttribute(key, value)
+	}
+}
+
+func (m multiSpan) End(err error) {
+	for i := len(m) - 1; i >= 0; i-- {
+		m[i].End(err)
+	}
+}
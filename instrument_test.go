@@ -0,0 +1,71 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestRouteTemplate(t *testing.T) {
+	for _, c := range []struct {
+		backend SupportedBackend
+		path    string
+		want    string
+	}{
+		{APIBackend, "/users/me", "/users/me"},
+		{APIBackend, "/users/me/workspaces", "/users/me/workspaces"},
+		{APIBackend, "/assets/abc/media/def", "/assets/{id}/media/{id}"},
+		{APIBackend, "/media/abc/complete", "/media/{id}/complete"},
+		{APIBackend, "/workspaces/abc/volumes/def/scan", "/workspaces/{id}/volumes/{id}/scan"},
+		{APIBackend, "/auth/login", "/auth/login"},
+		{CDNBackend, "/v123/uploads", "/{volume}/uploads"},
+		{CDNBackend, "/v123/uploads/part", "/{volume}/uploads/{id}"},
+		{CDNBackend, "/v123/manifest.json", "/{volume}/manifest.json"},
+		{CDNBackend, "/v123/files/abc/video.mp4", "/{file}"},
+	} {
+		if got := routeTemplate(c.backend, c.path); got != c.want {
+			t.Errorf("%s %s: got %s, want %s", c.backend, c.path, got, c.want)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want ErrorClass
+	}{
+		{nil, ErrorClassNone},
+		{NewApiError(http.StatusUnauthorized), ErrorClassAuth},
+		{NewApiError(http.StatusTooManyRequests), ErrorClassRateLimit},
+		{NewApiError(http.StatusBadGateway), ErrorClassServer},
+		{NewApiError(http.StatusConflict), ErrorClassClient},
+		{NewInternalError("request cancelled", context.Canceled), ErrorClassCanceled},
+		{NewInternalError("request failed", &url.Error{Op: "Get", URL: "/", Err: context.DeadlineExceeded}), ErrorClassTimeout},
+		{NewInternalError("request failed", &url.Error{Op: "Get", URL: "/", Err: http.ErrServerClosed}), ErrorClassNetwork},
+		{NewUsageError("invalid", nil), ErrorClassInternal},
+		{fmt.Errorf("listing assets: %w", NewApiError(http.StatusServiceUnavailable)), ErrorClassServer},
+		{fmt.Errorf("listing assets: %w", NewInternalError("request cancelled", context.Canceled)), ErrorClassCanceled},
+	} {
+		if got := ClassifyError(c.err); got != c.want {
+			t.Errorf("%v: got %q, want %q", c.err, got, c.want)
+		}
+	}
+}
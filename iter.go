@@ -17,13 +17,22 @@
 package trimmer
 
 import (
+	"context"
+	"iter"
 	"net/url"
-	"reflect"
+	"strconv"
 )
 
-// Query is the function used to get a page listing.
+// Query is the function used to get an untyped page listing.
 type Query func(url.Values) ([]interface{}, ListMeta, error)
 
+// PageQuery is the function used to get a page listing of T.
+type PageQuery[T any] func(url.Values) ([]T, ListMeta, error)
+
+// DefaultPrefetchDepth is the number of pages fetched ahead by Prefetch
+// when no depth is given.
+const DefaultPrefetchDepth = 2
+
 // Iter provides a convenient interface
 // for iterating over the elements
 // returned from paginated list API calls.
@@ -32,96 +41,237 @@ type Query func(url.Values) ([]interface{}, ListMeta, error)
 // fetching pages of items as needed.
 // Iterators are not thread-safe, so they should not be consumed
 // across multiple goroutines.
-type Iter struct {
-	query  Query
-	qs     url.Values
-	values []interface{}
+//
+// Iterators can also be used with range over All:
+//
+//	for a, err := range it.All() {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+type Iter[T any] struct {
+	query   PageQuery[T]
+	qs      url.Values
+	values  []T
+	meta    ListMeta
+	err     error
+	cur     T
+	started bool   // first page requested
+	pos     cursor // position of the next page to fetch
+	at      cursor // position of the current page
+	size    int    // items in the current page
+	read    int    // items of the current page returned by Next
+	total   int
+
+	// background fetching, see Prefetch
+	pages chan page[T]
+	ctx   context.Context
+	stop  context.CancelFunc
+}
+
+// page is a fetched page listing.
+type page[T any] struct {
+	values []T
 	meta   ListMeta
-	params ListParams
 	err    error
-	cur    interface{}
+	at     cursor
 }
 
-// GetIter returns a new Iter for a given query and its options.
-func GetIter(params *ListParams, qs *url.Values, query Query) *Iter {
-	iter := &Iter{}
-	iter.query = query
+// GetIter returns a new Iter for a given query and its options. The first
+// page is fetched by the first call to Next. A cursor in params resumes
+// iteration where the iterator that created it left off.
+func GetIter[T any](params *ListParams, qs *url.Values, query func(url.Values) ([]T, ListMeta, error)) *Iter[T] {
+	it := &Iter[T]{}
+	it.query = query
 
 	p := params
 	if p == nil {
 		p = &ListParams{}
 	}
-	iter.params = *p
 
 	q := qs
 	if q == nil {
 		q = &url.Values{}
 	}
-	iter.qs = *q
+	it.qs = *q
 
-	iter.getPage()
-	return iter
+	if p.Cursor == "" {
+		it.pos = startCursor(*p)
+	} else {
+		c, err := parseCursor(p.Cursor)
+		if err != nil {
+			it.err = err
+			return it
+		}
+		for k, v := range map[string]string{before: c.Before, after: c.After, "count": c.Count} {
+			if v != "" {
+				it.qs.Set(k, v)
+			}
+		}
+		it.pos = c
+	}
+	it.at = it.pos.window(it.qs)
+	return it
 }
 
-func GetIterErr(err error) *Iter {
-	iter := &Iter{}
-	iter.err = err
-	return iter
+// GetIterErr returns an Iter that stops immediately with err.
+func GetIterErr[T any](err error) *Iter[T] {
+	it := &Iter[T]{}
+	it.err = err
+	return it
 }
 
-func (it *Iter) getPage() {
-	it.values, it.meta, it.err = it.query(it.qs)
+// fetch loads the page at the current position and moves the position to
+// the page after it.
+func (it *Iter[T]) fetch() page[T] {
+	p, pos := fetchPage(it.query, it.qs, it.pos)
+	it.pos = pos
+	return p
+}
 
-	// when moving backwards strip off the first item because maxId is inclusive
-	if it.params.MaxId != "" && len(it.values) > 0 {
-		it.values = it.values[1:]
-	}
+// fetchPage loads the page at pos with query parameters qs. It returns the
+// page and the position of the page after it.
+func fetchPage[T any](query PageQuery[T], qs url.Values, pos cursor) (page[T], cursor) {
+	at := pos.window(qs)
+	at.apply(qs)
+	values, meta, err := query(qs)
 
-	if len(it.values) > 0 {
-		it.meta.More = true
+	// drop items returned before, e.g. the inclusive maxId when moving backwards
+	values = values[min(at.Skip, len(values)):]
+	meta.More = len(values) > 0 && err == nil
+	if meta.More {
+		pos = at.follow(meta)
 	}
+	return page[T]{values, meta, err, at}, pos
+}
 
-	// update total count
-	it.meta.Total += len(it.values)
-
-	// Reverse order when moving forward, because items arrive in backward order.
-	// 	reverse(it.values)
-	// }
+// nextPage replaces the consumed page with the next one. It returns false
+// at the end of the list or after an error.
+func (it *Iter[T]) nextPage() bool {
+	if it.err != nil || (it.started && !it.meta.More) {
+		return false
+	}
+	var p page[T]
+	if it.pages != nil {
+		var ok bool
+		select {
+		case p, ok = <-it.pages:
+		case <-it.ctx.Done():
+			// don't wait for a page request in flight
+		}
+		if !ok {
+			// the producer only stops early when ctx is done
+			it.err = it.ctx.Err()
+			return false
+		}
+	} else {
+		p = it.fetch()
+	}
+	it.started = true
+	it.values, it.err = p.values, p.err
+	it.at, it.size, it.read = p.at, len(p.values), 0
+	it.total += len(p.values)
+	it.meta = p.meta
+	it.meta.Total = it.total
+	return true
 }
 
 // Next advances the Iter to the next item in the list,
-// which will then be available when calling Current()
+// which will then be available when calling Item()
 // Next() returns false when the iterator stops
 // at the end of the list.
-func (it *Iter) Next() bool {
-	if len(it.values) == 0 && it.meta.More {
-		// determine if we're moving forward or backwards in paging
-		// if it.params.MinId != "" {
-		// 	it.params.MinId = listItemID(it.cur)
-		// 	it.qs.Set(maxId, it.params.MinId)
-		// } else {
-		// 	it.params.MaxId = listItemID(it.cur)
-		// 	it.qs.Set(minId, it.params.MaxId)
-		// }
-
-		// backward order: set the new maxId from the current page's last (minId) item
-		it.params.MaxId = it.meta.MinId
-		it.qs.Set(maxId, it.params.MaxId)
-		it.getPage()
-	}
-	if len(it.values) == 0 {
-		return false
+func (it *Iter[T]) Next() bool {
+	for len(it.values) == 0 {
+		if !it.nextPage() {
+			return false
+		}
 	}
 	it.cur = it.values[0]
-
-	// uhhh, this strips from the slice!
 	it.values = it.values[1:]
+	it.read++
 	return true
 }
 
+// Prefetch makes the iterator fetch pages in a background goroutine, so
+// the next page is already on its way while the current page is consumed.
+// Up to depth pages are kept ahead of the consumer, 0 uses
+// DefaultPrefetchDepth. Unless the list parameters set a count, pages hold
+// LIST_MAX_LIMIT items.
+//
+// Prefetch must be called before the first call to Next. Cancel ctx or call
+// Close to stop fetching when the list is not consumed to the end. Next
+// then returns false and Err returns ctx's error. Neither waits for a page
+// request in flight, which is bound to the context of the List call.
+func (it *Iter[T]) Prefetch(ctx context.Context, depth int) *Iter[T] {
+	if it.started || it.pages != nil || it.err != nil {
+		return it
+	}
+	if depth <= 0 {
+		depth = DefaultPrefetchDepth
+	}
+	if it.qs.Get("count") == "" {
+		it.qs.Set("count", strconv.Itoa(LIST_MAX_LIMIT))
+	}
+	it.at = it.pos.window(it.qs)
+	it.ctx, it.stop = context.WithCancel(ctx)
+	it.pages = make(chan page[T], depth)
+
+	// the producer works on its own copy of the position, so it can be
+	// abandoned with a page request in flight
+	qs := make(url.Values, len(it.qs))
+	for k, v := range it.qs {
+		qs[k] = append([]string(nil), v...)
+	}
+	go it.prefetch(it.ctx, it.pages, qs, it.pos)
+	return it
+}
+
+// prefetch fetches pages in order until the end of the list, an error or
+// cancellation.
+func (it *Iter[T]) prefetch(ctx context.Context, pages chan<- page[T], qs url.Values, pos cursor) {
+	defer close(pages)
+	for {
+		var p page[T]
+		p, pos = fetchPage(it.query, qs, pos)
+		if err := ctx.Err(); err != nil {
+			return
+		}
+		select {
+		case pages <- p:
+		case <-ctx.Done():
+			return
+		}
+		if !p.meta.More {
+			return
+		}
+	}
+}
+
+// Close stops background fetching started by Prefetch. It does not wait for
+// a page request in flight, which uses the context of the List call and
+// ends in the background. Next returns false afterwards.
+func (it *Iter[T]) Close() {
+	if it.pages == nil {
+		return
+	}
+	it.stop()
+	it.values = nil
+	it.started = true
+	it.meta.More = false
+}
+
+// Item returns the most recent item
+// visited by a call to Next.
+func (it *Iter[T]) Item() T {
+	return it.cur
+}
+
 // Current returns the most recent item
 // visited by a call to Next.
-func (it *Iter) Current() interface{} {
+//
+// Deprecated: use Item, which does not need a type assertion.
+func (it *Iter[T]) Current() interface{} {
 	return it.cur
 }
 
@@ -129,21 +279,151 @@ func (it *Iter) Current() interface{} {
 // that caused the Iter to stop.
 // It must be inspected
 // after Next returns false.
-func (it *Iter) Err() error {
+func (it *Iter[T]) Err() error {
 	return it.err
 }
 
 // Meta returns the list metadata.
-func (it *Iter) Meta() *ListMeta {
+func (it *Iter[T]) Meta() *ListMeta {
 	return &it.meta
 }
 
-func listItemID(x interface{}) string {
-	return reflect.ValueOf(x).Elem().FieldByName("ID").String()
+// Cursor returns an opaque token for the position after the most recent
+// item returned by Next. Set it as ListParams.Cursor to continue the list
+// later with the same order and time window, for example in the next run
+// of a sync job. At the end of a list sorted oldest first, the cursor
+// continues with items added afterwards.
+func (it *Iter[T]) Cursor() string {
+	c := it.at
+	switch {
+	case it.started && it.read == it.size && it.size > 0:
+		// start of the next page
+		c = it.at.follow(it.meta)
+	case it.started:
+		// anchor the newest page at its first item, so items added later
+		// don't shift the position
+		if c.Order != LIST_OLDEST_FIRST && c.MaxId == "" && it.meta.MaxId != "" {
+			c.MaxId = it.meta.MaxId
+		}
+		c.Skip += it.read
+	}
+	return c.encode()
 }
 
-func reverse(a []interface{}) {
-	for i := 0; i < len(a)/2; i++ {
-		a[i], a[len(a)-i-1] = a[len(a)-i-1], a[i]
+// All returns a sequence over the remaining items for use with range. When
+// listing fails the sequence ends with the zero item and the error. Like
+// Next, All consumes the iterator. Leaving the loop early closes the
+// iterator.
+func (it *Iter[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer it.Close()
+		for it.Next() {
+			if !yield(it.Item(), nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Sequence Helpers
+//
+// The helpers work on sequences returned by Iter.All and pass errors through
+// unchanged, so they can be chained:
+//
+//	names, err := trimmer.Collect(trimmer.Map(trimmer.Take(it.All(), 10),
+//		func(a *trimmer.Asset) string { return a.Title }))
+
+// Collect returns all items of seq, or the items before the first error
+// together with the error.
+func Collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var list []T
+	for v, err := range seq {
+		if err != nil {
+			return list, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// Take returns a sequence of the first n items of seq. Iteration stops
+// after n items, so no further pages are fetched.
+func Take[T any](seq iter.Seq2[T, error], n int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		if n <= 0 {
+			return
+		}
+		var i int
+		for v, err := range seq {
+			if !yield(v, err) || err != nil {
+				return
+			}
+			if i++; i >= n {
+				return
+			}
+		}
+	}
+}
+
+// Filter returns a sequence of the items of seq for which keep returns true.
+func Filter[T any](seq iter.Seq2[T, error], keep func(T) bool) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for v, err := range seq {
+			if err == nil && !keep(v) {
+				continue
+			}
+			if !yield(v, err) {
+				return
+			}
+		}
+	}
+}
+
+// Map returns a sequence of the results of fn for the items of seq.
+func Map[T, U any](seq iter.Seq2[T, error], fn func(T) U) iter.Seq2[U, error] {
+	return func(yield func(U, error) bool) {
+		for v, err := range seq {
+			if err != nil {
+				var zero U
+				yield(zero, err)
+				return
+			}
+			if !yield(fn(v), nil) {
+				return
+			}
+		}
+	}
+}
+
+// Stream sends the items of seq to a channel from a new goroutine. The
+// items channel is closed at the end of seq, after which the error channel
+// receives the listing error or nil. Cancel ctx to stop early, the error
+// channel then receives ctx's error.
+func Stream[T any](ctx context.Context, seq iter.Seq2[T, error], buffer int) (<-chan T, <-chan error) {
+	items := make(chan T, buffer)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		err := func() error {
+			defer close(items)
+			for v, err := range seq {
+				if err != nil {
+					return err
+				}
+				select {
+				case items <- v:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		}()
+		errc <- err
+	}()
+	return items, errc
+}
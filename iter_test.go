@@ -0,0 +1,295 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pagedQuery serves ids n-1..0 in pages of size, newest first like the API
+// or oldest first with order=asc. maxId is inclusive, minId exclusive. The
+// query fails with err once page fail is requested.
+func pagedQuery(n, size, fail int, err error, pages *int) PageQuery[string] {
+	return func(q url.Values) ([]string, ListMeta, error) {
+		*pages++
+		if fail > 0 && *pages >= fail {
+			return nil, ListMeta{}, err
+		}
+		lo, hi := 0, n-1
+		if v := q.Get(maxId); v != "" {
+			hi, _ = strconv.Atoi(v)
+		}
+		if v := q.Get(minId); v != "" {
+			lo, _ = strconv.Atoi(v)
+			lo++
+		}
+		var ids []string
+		for i := 0; i <= hi-lo && len(ids) < size; i++ {
+			id := hi - i
+			if q.Get("order") == string(LIST_OLDEST_FIRST) {
+				id = lo + i
+			}
+			ids = append(ids, strconv.Itoa(id))
+		}
+		var meta ListMeta
+		if len(ids) > 0 {
+			meta.MaxId, meta.MinId = ids[0], ids[len(ids)-1]
+			if q.Get("order") == string(LIST_OLDEST_FIRST) {
+				meta.MaxId, meta.MinId = meta.MinId, meta.MaxId
+			}
+		}
+		return ids, meta, nil
+	}
+}
+
+// blockingQuery returns one item on the first page and blocks on all later
+// pages until block is closed.
+func blockingQuery(block chan struct{}) PageQuery[string] {
+	var calls int32
+	return func(url.Values) ([]string, ListMeta, error) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			<-block
+		}
+		return []string{"x"}, ListMeta{}, nil
+	}
+}
+
+func TestIterAll(t *testing.T) {
+	var pages int
+	it := GetIter(nil, nil, pagedQuery(7, 3, 0, nil, &pages))
+	got, err := Collect(it.All())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"6", "5", "4", "3", "2", "1", "0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Take stops fetching pages
+	pages = 0
+	it = GetIter(nil, nil, pagedQuery(7, 3, 0, nil, &pages))
+	even := Filter(it.All(), func(s string) bool { n, _ := strconv.Atoi(s); return n%2 == 0 })
+	got, err = Collect(Map(Take(even, 2), func(s string) string { return "#" + s }))
+	if err != nil || !reflect.DeepEqual(got, []string{"#6", "#4"}) {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if pages != 1 {
+		t.Errorf("expected 1 page, fetched %d", pages)
+	}
+}
+
+func TestIterError(t *testing.T) {
+	var pages int
+	fail := errors.New("page failed")
+	it := GetIter(nil, nil, pagedQuery(7, 3, 2, fail, &pages))
+	var got []string
+	for v, err := range it.All() {
+		if err != nil {
+			if !errors.Is(err, fail) {
+				t.Errorf("unexpected error %v", err)
+			}
+			break
+		}
+		got = append(got, v)
+	}
+	if len(got) != 3 {
+		t.Errorf("expected first page before error, got %v", got)
+	}
+
+	got, err := Collect(GetIterErr[string](EIDMissing).All())
+	if !errors.Is(err, EIDMissing) || len(got) != 0 {
+		t.Errorf("expected EIDMissing, got %v, %v", got, err)
+	}
+}
+
+func TestStream(t *testing.T) {
+	var pages int
+	items, errc := Stream(context.Background(), GetIter(nil, nil, pagedQuery(5, 2, 0, nil, &pages)).All(), 1)
+	var n int
+	for range items {
+		n++
+	}
+	if err := <-errc; err != nil || n != 5 {
+		t.Errorf("got %d items, %v", n, err)
+	}
+
+	// cancelling stops the producer
+	ctx, cancel := context.WithCancel(context.Background())
+	items, errc = Stream(ctx, GetIter(nil, nil, pagedQuery(100, 10, 0, nil, &pages)).All(), 0)
+	<-items
+	cancel()
+	for range items {
+	}
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation, got %v", err)
+	}
+}
+
+func TestIterPrefetch(t *testing.T) {
+	var pages int
+	got, err := Collect(GetIter(nil, nil, pagedQuery(7, 3, 0, nil, &pages)).Prefetch(context.Background(), 0).All())
+	if err != nil || !reflect.DeepEqual(got, []string{"6", "5", "4", "3", "2", "1", "0"}) {
+		t.Errorf("got %v, %v", got, err)
+	}
+
+	// pages are fetched ahead of the consumer with the maximum page size
+	var fetched int32
+	var count atomic.Value
+	var ahead int
+	query := pagedQuery(100, 10, 0, nil, &ahead)
+	it := GetIter(nil, nil, func(q url.Values) ([]string, ListMeta, error) {
+		count.Store(q.Get("count"))
+		defer atomic.AddInt32(&fetched, 1)
+		return query(q)
+	}).Prefetch(context.Background(), 2)
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&fetched) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	it.Close()
+	if n := atomic.LoadInt32(&fetched); n < 3 || n > 4 {
+		t.Errorf("expected 3 or 4 pages fetched ahead, got %d", n)
+	}
+	if c := count.Load(); c != strconv.Itoa(LIST_MAX_LIMIT) {
+		t.Errorf("expected default page size %d, got %q", LIST_MAX_LIMIT, c)
+	}
+	if it.Next() || it.Err() != nil {
+		t.Errorf("expected closed iterator to stop without error, got %v", it.Err())
+	}
+
+	// errors end the list after the pages before them
+	fail := errors.New("page failed")
+	pages = 0
+	got, err = Collect(GetIter(nil, nil, pagedQuery(7, 3, 2, fail, &pages)).Prefetch(context.Background(), 1).All())
+	if !errors.Is(err, fail) || len(got) != 3 {
+		t.Errorf("expected first page and error, got %v, %v", got, err)
+	}
+
+	// cancellation stops the iterator
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it = GetIter(nil, nil, pagedQuery(1000, 10, 0, nil, &pages)).Prefetch(ctx, 1)
+	var n int
+	for it.Next() {
+		if n++; n == 5 {
+			cancel()
+		}
+	}
+	if !errors.Is(it.Err(), context.Canceled) || n >= 1000 {
+		t.Errorf("expected cancellation after %d items, got %v", n, it.Err())
+	}
+
+	// Close and cancellation don't wait for a page request in flight
+	block := make(chan struct{})
+	defer close(block)
+	it = GetIter(nil, nil, blockingQuery(block)).Prefetch(context.Background(), 1)
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	closed := make(chan struct{})
+	go func() {
+		it.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close waited for a blocked page request")
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	it = GetIter(nil, nil, blockingQuery(block)).Prefetch(ctx, 1)
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if it.Next() || !errors.Is(it.Err(), context.Canceled) {
+		t.Errorf("expected cancellation, got %v", it.Err())
+	}
+}
+
+func TestIterCursor(t *testing.T) {
+	var pages int
+	for _, order := range []ListOrder{LIST_NEWEST_FIRST, LIST_OLDEST_FIRST} {
+		all, err := Collect(GetIter(&ListParams{Order: order}, nil, pagedQuery(7, 3, 0, nil, &pages)).All())
+		if err != nil || len(all) != 7 {
+			t.Fatalf("%q: got %v, %v", order, all, err)
+		}
+		if order == LIST_OLDEST_FIRST && (all[0] != "0" || all[6] != "6") {
+			t.Errorf("expected oldest first, got %v", all)
+		}
+
+		// resuming after each item continues with the next one
+		for i := 0; i <= len(all); i++ {
+			it := GetIter(&ListParams{Order: order}, nil, pagedQuery(7, 3, 0, nil, &pages))
+			for j := 0; j < i; j++ {
+				it.Next()
+			}
+			rest, err := Collect(GetIter(&ListParams{Cursor: it.Cursor()}, nil, pagedQuery(7, 3, 0, nil, &pages)).All())
+			if err != nil || len(rest) != len(all)-i || i < len(all) && rest[0] != all[i] {
+				t.Errorf("%q: resume after %d got %v, %v, want %v", order, i, rest, err, all[i:])
+			}
+		}
+	}
+
+	// a cursor at the end of an oldest first list picks up new items
+	it := GetIter(&ListParams{Order: LIST_OLDEST_FIRST}, nil, pagedQuery(4, 3, 0, nil, &pages))
+	if _, err := Collect(it.All()); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Collect(GetIter(&ListParams{Cursor: it.Cursor()}, nil, pagedQuery(6, 3, 0, nil, &pages)).All())
+	if err != nil || !reflect.DeepEqual(got, []string{"4", "5"}) {
+		t.Errorf("expected new items, got %v, %v", got, err)
+	}
+
+	// the cursor keeps the page size and works with prefetching
+	params := &ListParams{Count: 2}
+	q := url.Values{}
+	params.AppendTo(&q)
+	var prefetched int // Close does not wait for the producer
+	it = GetIter(params, &q, pagedQuery(7, 3, 0, nil, &prefetched)).Prefetch(context.Background(), 1)
+	it.Next()
+	it.Next()
+	it.Next()
+	it.Close()
+	var count string
+	query := pagedQuery(7, 3, 0, nil, &pages)
+	got, err = Collect(GetIter(&ListParams{Cursor: it.Cursor()}, nil, func(q url.Values) ([]string, ListMeta, error) {
+		count = q.Get("count")
+		return query(q)
+	}).All())
+	if err != nil || !reflect.DeepEqual(got, []string{"3", "2", "1", "0"}) || count != "2" {
+		t.Errorf("got %v, %v, count %q", got, err, count)
+	}
+
+	_, err = Collect(GetIter(&ListParams{Cursor: "not a cursor"}, nil, pagedQuery(7, 3, 0, nil, &pages)).All())
+	var e TrimmerError
+	if !errors.As(err, &e) || !e.IsUsage() {
+		t.Errorf("expected usage error, got %v", err)
+	}
+}
@@ -30,12 +30,12 @@ import (
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.Job]
 }
 
 // JOb returns the most recent Job visited by a call to Next.
 func (i *Iter) Job() *trimmer.Job {
-	return i.Current().(*trimmer.Job)
+	return i.Item()
 }
 
 // Client is used to invoke /users APIs.
@@ -85,7 +85,7 @@ func (c Client) Update(ctx context.Context, jobId string, params *trimmer.JobPar
 		return nil, trimmer.ENilPointer
 	}
 	v := &trimmer.Job{}
-	err := c.B.Call(ctx, http.MethodPatch, fmt.Sprintf("/jobs/%v", jobId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPatch, fmt.Sprintf("/jobs/%v", jobId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
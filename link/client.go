@@ -25,10 +25,10 @@ import (
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.Link]
 }
 
 // Link returns the most recent User visited by a call to Next.
 func (i *Iter) Link() *trimmer.Link {
-	return i.Current().(*trimmer.Link)
+	return i.Item()
 }
@@ -0,0 +1,300 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+// Log levels for LogLevel.
+const (
+	LogLevelNone  = 0
+	LogLevelError = 1
+	LogLevelInfo  = 2
+	LogLevelDebug = 3
+)
+
+// LogField is a structured key/value pair attached to a log message.
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+// F is a shorthand for creating a LogField.
+func F(key string, value interface{}) LogField {
+	return LogField{Key: key, Value: value}
+}
+
+// LeveledLogger is the interface the SDK uses for all logging. Implementations
+// can forward messages to slog, zap or any other structured logger. With
+// returns a child logger that adds fields to every message, the SDK uses it
+// for request-scoped fields like method, path, request id and duration.
+type LeveledLogger interface {
+	Debug(msg string, fields ...LogField)
+	Info(msg string, fields ...LogField)
+	Warn(msg string, fields ...LogField)
+	Error(msg string, fields ...LogField)
+	With(fields ...LogField) LeveledLogger
+}
+
+// Log is the logger used by the SDK. The default implementation writes to
+// Logger and filters messages by LogLevel.
+var Log LeveledLogger = stdLogger{}
+
+type loggerKey struct{}
+
+// ContextWithLogger returns a context that makes the SDK log all calls made
+// with it to l, e.g. to add application fields like a job id.
+func ContextWithLogger(ctx context.Context, l LeveledLogger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// LoggerFromContext returns the logger stored in ctx or the package logger.
+func LoggerFromContext(ctx context.Context) LeveledLogger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerKey{}).(LeveledLogger); ok && l != nil {
+			return l
+		}
+	}
+	return Log
+}
+
+// stdLogger writes plain text lines to the package-level Logger.
+type stdLogger struct {
+	fields []LogField
+}
+
+func (l stdLogger) Debug(msg string, f ...LogField) { l.print(LogLevelDebug, "DEBUG", msg, f) }
+func (l stdLogger) Info(msg string, f ...LogField)  { l.print(LogLevelInfo, "INFO", msg, f) }
+func (l stdLogger) Warn(msg string, f ...LogField)  { l.print(LogLevelInfo, "WARN", msg, f) }
+func (l stdLogger) Error(msg string, f ...LogField) { l.print(LogLevelError, "ERROR", msg, f) }
+
+func (l stdLogger) With(fields ...LogField) LeveledLogger {
+	f := make([]LogField, 0, len(l.fields)+len(fields))
+	f = append(f, l.fields...)
+	return stdLogger{fields: append(f, fields...)}
+}
+
+func (l stdLogger) print(level int, prefix, msg string, fields []LogField) {
+	if LogLevel < level || Logger == nil {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(prefix)
+	b.WriteString(": ")
+	b.WriteString(msg)
+	for _, list := range [][]LogField{l.fields, fields} {
+		for _, f := range list {
+			s := fmt.Sprint(f.Value)
+			if strings.ContainsAny(s, " \t\n\"=") {
+				s = quote(strings.Replace(s, `"`, `\"`, -1))
+			}
+			b.WriteString(" ")
+			b.WriteString(f.Key)
+			b.WriteString("=")
+			b.WriteString(s)
+		}
+	}
+	Logger.Println(b.String())
+}
+
+// slogLogger forwards messages to a log/slog logger.
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger adapts a slog.Logger to the LeveledLogger interface. Level
+// filtering is left to the slog handler, LogLevel is ignored.
+func NewSlogLogger(l *slog.Logger) LeveledLogger {
+	return slogLogger{l}
+}
+
+func (l slogLogger) Debug(msg string, f ...LogField) { l.l.Debug(msg, slogArgs(f)...) }
+func (l slogLogger) Info(msg string, f ...LogField)  { l.l.Info(msg, slogArgs(f)...) }
+func (l slogLogger) Warn(msg string, f ...LogField)  { l.l.Warn(msg, slogArgs(f)...) }
+func (l slogLogger) Error(msg string, f ...LogField) { l.l.Error(msg, slogArgs(f)...) }
+
+func (l slogLogger) With(fields ...LogField) LeveledLogger {
+	return slogLogger{l.l.With(slogArgs(fields)...)}
+}
+
+func slogArgs(fields []LogField) []interface{} {
+	args := make([]interface{}, len(fields))
+	for i, f := range fields {
+		args[i] = slog.Any(f.Key, f.Value)
+	}
+	return args
+}
+
+// ---------------------------------------------------------------------------
+// Redaction
+//
+
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders are never written to logs.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"X-Api-Key",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// sensitiveFields are JSON object keys whose values are never written to logs.
+// Keys are matched in lower case without '_' and '-', so "refresh_token"
+// matches "refreshtoken".
+var sensitiveFields = map[string]bool{
+	"password":     true,
+	"accesstoken":  true,
+	"refreshtoken": true,
+	"clientsecret": true,
+	"secret":       true,
+	"apikey":       true,
+	"token":        true,
+	"devicecode":   true,
+}
+
+// fieldKeyReplacer normalizes JSON keys for the sensitiveFields lookup.
+var fieldKeyReplacer = strings.NewReplacer("_", "", "-", "")
+
+// isSensitiveField returns true when the values of JSON fields, form fields
+// or query parameters named k are never written to logs.
+func isSensitiveField(k string) bool {
+	return sensitiveFields[fieldKeyReplacer.Replace(strings.ToLower(k))]
+}
+
+// dumpRequest returns a wire representation of req with credentials removed.
+func dumpRequest(req *http.Request, body bool) string {
+	b, err := httputil.DumpRequestOut(req, false)
+	if err != nil {
+		return ""
+	}
+	head := redactHeaders(b)
+	if !body || req.Body == nil || req.Body == http.NoBody {
+		return head
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return head
+	}
+	return head + redactBody(data, req.Header.Get("Content-Type"))
+}
+
+// dumpResponse returns a wire representation of resp with credentials removed.
+// The body is read decoded, so chunked transfer encoding does not hide JSON
+// fields from redaction.
+func dumpResponse(resp *http.Response) string {
+	b, err := httputil.DumpResponse(resp, false)
+	if err != nil {
+		return ""
+	}
+	head := redactHeaders(b)
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return head
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return head
+	}
+	return head + redactBody(data, resp.Header.Get("Content-Type"))
+}
+
+// redactHeaders strips credentials from the header section of a dump.
+func redactHeaders(b []byte) string {
+	lines := strings.Split(strings.TrimRight(string(b), "\r\n"), "\r\n")
+	for i, line := range lines {
+		name := strings.SplitN(line, ":", 2)[0]
+		for _, h := range sensitiveHeaders {
+			if strings.EqualFold(name, h) {
+				lines[i] = name + ": " + redacted
+			}
+		}
+	}
+	return strings.Join(lines, "\r\n") + "\r\n\r\n"
+}
+
+// redactBody strips credentials from a JSON or form encoded body. Other
+// bodies and bodies that cannot be parsed are replaced by a placeholder, so
+// secrets they may contain are never logged.
+func redactBody(b []byte, contentType string) string {
+	if len(b) == 0 {
+		return ""
+	}
+	if mt, _, _ := mime.ParseMediaType(contentType); mt == "application/x-www-form-urlencoded" {
+		if q, err := url.ParseQuery(string(b)); err == nil {
+			for k := range q {
+				if isSensitiveField(k) {
+					q.Set(k, redacted)
+				}
+			}
+			return q.Encode()
+		}
+	} else {
+		var v interface{}
+		if err := json.Unmarshal(b, &v); err == nil {
+			if out, err := json.Marshal(redactValue(v)); err == nil {
+				return string(out)
+			}
+		}
+	}
+	return fmt.Sprintf("[%d bytes not shown]", len(b))
+}
+
+// redactJSON replaces the values of sensitive fields in a JSON document. Bodies
+// that cannot be parsed are returned unchanged.
+func redactJSON(b []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return b
+	}
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return b
+	}
+	return out
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, x := range val {
+			if isSensitiveField(k) {
+				val[k] = redacted
+			} else {
+				val[k] = redactValue(x)
+			}
+		}
+	case []interface{}:
+		for i, x := range val {
+			val[i] = redactValue(x)
+		}
+	}
+	return v
+}
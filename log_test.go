@@ -0,0 +1,114 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDumpRequestRedactsCredentials(t *testing.T) {
+	body := `{"name":"joe","password":"s3cret","nested":{"refreshToken":"r3fresh"}}`
+	req, _ := http.NewRequest(http.MethodPost, "https://api.trimmer.io/auth/login", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "k3y")
+	req.Header.Set("Authorization", "Bearer t0ken")
+
+	dump := dumpRequest(req, true)
+	for _, secret := range []string{"s3cret", "r3fresh", "k3y", "t0ken"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("dump contains secret %q:\n%s", secret, dump)
+		}
+	}
+	if !strings.Contains(dump, `"name":"joe"`) {
+		t.Errorf("dump lost non-sensitive fields:\n%s", dump)
+	}
+
+	// the request body must still be readable after dumping
+	buf := &bytes.Buffer{}
+	buf.ReadFrom(req.Body)
+	if buf.String() != body {
+		t.Errorf("request body changed by dump: %q", buf.String())
+	}
+}
+
+func TestDumpResponseRedactsSnakeCaseTokens(t *testing.T) {
+	body := `{"access_token":"AT123","refresh_token":"RT456","device_code":"DC789","token_type":"bearer","expires_in":3600}`
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+
+	dump := dumpResponse(resp)
+	for _, secret := range []string{"AT123", "RT456", "DC789"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("dump contains secret %q:\n%s", secret, dump)
+		}
+	}
+	if !strings.Contains(dump, `"token_type":"bearer"`) {
+		t.Errorf("dump lost non-sensitive fields:\n%s", dump)
+	}
+}
+
+func TestDumpResponseRedactsChunkedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"accessToken":"AT123",`)
+		w.(http.Flusher).Flush()
+		io.WriteString(w, `"refreshToken":"RT456","expiresIn":3600}`)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if len(resp.TransferEncoding) == 0 || resp.TransferEncoding[0] != "chunked" {
+		t.Fatalf("expected chunked response, got %v", resp.TransferEncoding)
+	}
+
+	dump := dumpResponse(resp)
+	for _, secret := range []string{"AT123", "RT456"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("dump contains secret %q:\n%s", secret, dump)
+		}
+	}
+	if !strings.Contains(dump, `"expiresIn":3600`) {
+		t.Errorf("dump lost non-sensitive fields:\n%s", dump)
+	}
+
+	// the response body must still be readable after dumping
+	b, _ := ioutil.ReadAll(resp.Body)
+	if !strings.Contains(string(b), "AT123") {
+		t.Errorf("response body changed by dump: %q", b)
+	}
+
+	// bodies that cannot be parsed are not shown
+	resp.Body = ioutil.NopCloser(strings.NewReader(`{"accessToken":"AT123"`))
+	if dump := dumpResponse(resp); strings.Contains(dump, "AT123") {
+		t.Errorf("dump contains secret of invalid body:\n%s", dump)
+	}
+}
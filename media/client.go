@@ -37,23 +37,42 @@ type Client struct {
 	CDN          trimmer.Backend
 	Key          trimmer.ApiKey
 	Sess         *trimmer.Session
+	PartSize     int64 // minimum upload part size, 0 uses trimmer.UploadPartSize
 	lastProgress time.Time
+
+	// parts sent in parallel, 0 uses trimmer.UploadPartConcurrency
+	PartConcurrency int
+
+	// journal of multipart uploads, nil disables resuming after restarts
+	Journal trimmer.UploadJournal
+
+	// retry policy for multipart upload parts, nil uses trimmer.DefaultRetryPolicy
+	Retry *trimmer.RetryPolicy
+
+	// what happens to multipart uploads that fail, aborts them by default
+	FailurePolicy trimmer.UploadFailurePolicy
 }
 
 func getC() Client {
-	return Client{trimmer.GetBackend(trimmer.APIBackend), trimmer.GetBackend(trimmer.CDNBackend), trimmer.Key, &trimmer.LoginSession, time.Time{}}
+	return Client{
+		B:       trimmer.GetBackend(trimmer.APIBackend),
+		CDN:     trimmer.GetBackend(trimmer.CDNBackend),
+		Key:     trimmer.Key,
+		Sess:    &trimmer.LoginSession,
+		Journal: trimmer.GetUploadJournal(),
+	}
 }
 
 // Iter is an iterator for lists of Media.
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.Media]
 }
 
 // Media returns the most recent Media visited by a call to Next.
 func (i *Iter) Media() *trimmer.Media {
-	return i.Current().(*trimmer.Media)
+	return i.Item()
 }
 
 func Get(ctx context.Context, mediaId string, params *trimmer.MediaParams) (*trimmer.Media, error) {
@@ -116,7 +135,7 @@ func (c Client) Update(ctx context.Context, mediaId string, params *trimmer.Medi
 	}
 	StripMetadataUrls(params.Attr)
 	v := &trimmer.Media{}
-	err := c.B.Call(ctx, http.MethodPatch, fmt.Sprintf("/media/%v", mediaId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPatch, fmt.Sprintf("/media/%v", mediaId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -124,7 +143,7 @@ func (c Client) Delete(ctx context.Context, mediaId string) error {
 	if mediaId == "" {
 		return trimmer.EIDMissing
 	}
-	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/media/%v", mediaId), c.Key, c.Sess, nil, nil, nil)
+	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/media/%v", mediaId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, nil)
 }
 
 func (c Client) CompleteUpload(ctx context.Context, mediaId string, params *trimmer.MediaUploadCompletionParams) (*trimmer.Media, error) {
@@ -132,7 +151,7 @@ func (c Client) CompleteUpload(ctx context.Context, mediaId string, params *trim
 		return nil, trimmer.EIDMissing
 	}
 	v := &trimmer.Media{}
-	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/media/%v/complete", mediaId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/media/%v/complete", mediaId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_UPLOAD}, params, v)
 	if err != nil {
 		return nil, err
 	}
@@ -167,23 +186,16 @@ func (c Client) ListProfiles(ctx context.Context, mediaId string, params *trimme
 		lp = &params.ListParams
 	}
 
-	return &profile.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &profile.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Profile, trimmer.ListMeta, error) {
 		list := &profileList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/media/%v/profiles?%s", mediaId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
 func (c Client) ListReplicas(ctx context.Context, mediaId string, params *trimmer.ReplicaListParams) *replica.Iter {
 	if mediaId == "" {
-		return &replica.Iter{Iter: trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &replica.Iter{Iter: trimmer.GetIterErr[*trimmer.Replica](trimmer.EIDMissing)}
 	}
 
 	type replicaList struct {
@@ -219,17 +231,10 @@ func (c Client) ListReplicas(ctx context.Context, mediaId string, params *trimme
 		lp = &params.ListParams
 	}
 
-	return &replica.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &replica.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Replica, trimmer.ListMeta, error) {
 		list := &replicaList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/media/%v/replicas?%s", mediaId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -244,7 +249,7 @@ func (c Client) NewReplica(ctx context.Context, mediaId, volumeId string, params
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
 	v := &trimmer.Job{}
-	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, params, v)
 	if err != nil {
 		return nil, err
 	}
@@ -262,7 +267,7 @@ func (c Client) RegisterReplica(ctx context.Context, mediaId, volumeId string, p
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
 	v := &trimmer.Replica{}
-	err := c.B.Call(ctx, http.MethodPut, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPut, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, params, v)
 	if err != nil {
 		return nil, err
 	}
@@ -273,5 +278,5 @@ func (c Client) DeleteReplica(ctx context.Context, mediaId, volumeId string, par
 	if mediaId == "" || volumeId == "" {
 		return trimmer.EIDMissing
 	}
-	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/media/%v/replicas/%v", mediaId, volumeId), c.Key, c.Sess, nil, params, nil)
+	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/media/%v/replicas/%v", mediaId, volumeId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, params, nil)
 }
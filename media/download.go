@@ -63,10 +63,8 @@ func (c Client) DownloadUrl(ctx context.Context, uri string, h hash.HashBlock, d
 	}
 
 	if err = clientHashes.Check(h, true); err != nil {
-		if trimmer.LogLevel > 0 {
-			trimmer.Logger.Println("ERROR: checksum mismatch", err.Error())
-		}
-		return nil, err
+		trimmer.LoggerFromContext(ctx).Error("checksum mismatch", trimmer.F("url", uri), trimmer.F("error", err))
+		return nil, trimmer.NewChecksumError(h, clientHashes)
 	}
 
 	cd := rfc.ParseContentDisposition(ch.ContentDisposition)
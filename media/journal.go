@@ -0,0 +1,240 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package media
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	trimmer "trimmer.io/go-trimmer"
+	"trimmer.io/go-trimmer/hash"
+)
+
+// errStaleUpload signals that an unfinished upload on the volume does not
+// fit the file, e.g. because it was started with another part size.
+var errStaleUpload = errors.New("unfinished upload does not match file")
+
+// JournalKey identifies the upload in the client's UploadJournal. It is
+// derived from the upload URL and the file's name, UUID, size and hashes,
+// so a changed file starts a new upload.
+func (r *UploadRequest) JournalKey() string {
+	q := make(url.Values, len(r.Query))
+	for k, v := range r.Query {
+		q[k] = v
+	}
+	q.Del("part")
+	q.Del("overwrite")
+	h := sha256.New()
+	fmt.Fprintf(h, "%s?%s\n%s\n%s\n%d\n%s", r.Url, q.Encode(), r.Filename, r.UUID, r.Size, r.Hashes.String())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// startMulti continues the journaled upload of the file, or an unfinished
+// upload the volume still has for it, or starts a new upload. It returns
+// the parts that are missing on the volume.
+func (r *UploadRequest) startMulti(ctx context.Context) ([]partJob, error) {
+	log := trimmer.LoggerFromContext(ctx)
+	key := r.JournalKey()
+
+	if j := r.C.Journal; j != nil {
+		if err := j.Prune(); err != nil {
+			log.Warn("pruning upload journal failed", trimmer.F("error", err))
+		}
+		rec, err := j.Load(key)
+		switch {
+		case err != nil:
+			log.Warn("reading upload journal failed", trimmer.F("filename", r.Filename), trimmer.F("error", err))
+		case rec == nil:
+		case rec.Expired() || rec.UploadId == "" || rec.Size != r.Size || rec.PartSize <= 0:
+			log.Info("journaled upload expired, starting over", trimmer.F("filename", r.Filename), trimmer.F("uploadId", rec.UploadId))
+			r.forget(ctx)
+		default:
+			r.UploadId, r.PartSize, r.journal = rec.UploadId, rec.PartSize, rec
+			todo, err := r.reconcile(ctx)
+			switch {
+			case err == nil:
+				log.Info("resuming upload", trimmer.F("filename", r.Filename), trimmer.F("uploadId", r.UploadId), trimmer.F("missingParts", len(todo)))
+				return todo, nil
+			case errors.Is(err, trimmer.ENotFound):
+				log.Info("journaled upload is gone, starting over", trimmer.F("filename", r.Filename), trimmer.F("uploadId", rec.UploadId))
+				r.forget(ctx)
+			case errors.Is(err, errStaleUpload):
+				r.abortMulti(ctx)
+			default:
+				return nil, err
+			}
+			r.UploadId, r.PartSize = "", r.CalculatePartSize()
+		}
+	}
+
+	for {
+		upload, err := r.initMulti(ctx)
+		if err != nil {
+			return nil, err
+		}
+		r.journal = &trimmer.UploadRecord{
+			Key:      key,
+			UploadId: r.UploadId,
+			Url:      r.SingleUrl(),
+			Size:     r.Size,
+			PartSize: r.PartSize,
+			Expires:  upload.Expires,
+		}
+
+		// the volume continues unfinished uploads of the same file, which
+		// may have been sent in any order
+		if upload.State != UploadStateProgress {
+			r.saveJournal(ctx)
+			return r.missingParts(nil, nil), nil
+		}
+		todo, err := r.reconcile(ctx)
+		if !errors.Is(err, errStaleUpload) {
+			return todo, err
+		}
+		if err := r.abortMulti(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// reconcile compares the parts stored on the volume with the journal. Parts
+// that are not journaled are checked against the file. It returns missing
+// and corrupt parts and journals the others.
+func (r *UploadRequest) reconcile(ctx context.Context) ([]partJob, error) {
+	parts, err := r.listParts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	journaled := make(map[int64]trimmer.UploadPartRecord)
+	for _, p := range r.journal.Parts {
+		journaled[p.PartId] = p
+	}
+	last := r.lastPart()
+	stored := make(map[int64]bool)
+	verified := make(map[int64]bool)
+	r.journal.Parts = r.journal.Parts[:0]
+	r.UploadedSize = 0
+	for _, p := range parts {
+		num := int64(p.PartId)
+		if num < 1 || num > last {
+			return nil, errStaleUpload
+		}
+		stored[num] = true
+		want, ok := journaled[num]
+		if !ok {
+			if want, err = r.hashPart(num); err != nil {
+				return nil, err
+			}
+		}
+		if p.State != PartStateComplete || p.Size != want.Size || want.Hashes.Sha256 == "" || p.Hashes.Sha256 != want.Hashes.Sha256 {
+			trimmer.LoggerFromContext(ctx).Info("part is incomplete or corrupt, sending again", trimmer.F("part", num))
+			continue
+		}
+		verified[num] = true
+		r.UploadedSize += want.Size
+		r.journal.Parts = append(r.journal.Parts, want)
+	}
+	r.saveJournal(ctx)
+	return r.missingParts(stored, verified), nil
+}
+
+// missingParts returns the parts that are not verified. Stored parts are
+// overwritten.
+func (r *UploadRequest) missingParts(stored, verified map[int64]bool) []partJob {
+	var todo []partJob
+	for num := int64(1); num <= r.lastPart(); num++ {
+		if !verified[num] {
+			todo = append(todo, partJob{num: num, overwrite: stored[num]})
+		}
+	}
+	return todo
+}
+
+// lastPart returns the number of the last part.
+func (r *UploadRequest) lastPart() int64 {
+	return max((r.Size+r.PartSize-1)/r.PartSize, 1)
+}
+
+// listParts requests the parts the volume has stored for the upload.
+func (r *UploadRequest) listParts(ctx context.Context) (PartInfoList, error) {
+	h := &trimmer.CallHeaders{
+		Scope: trimmer.API_SCOPE_UPLOAD,
+	}
+	res := &PartListResponse{}
+	if err := r.C.CDN.Call(ctx, http.MethodGet, r.EndMultiUrl(), r.C.Key, r.C.Sess, h, nil, res); err != nil {
+		return nil, err
+	}
+	return res.Parts, nil
+}
+
+// hashPart reads part num from the file and returns its size and SHA256.
+func (r *UploadRequest) hashPart(num int64) (trimmer.UploadPartRecord, error) {
+	p := trimmer.UploadPartRecord{PartId: num}
+	src, err := r.partReader(num)
+	if err != nil {
+		return p, err
+	}
+	if p.Size, err = io.Copy(ioutil.Discard, p.Hashes.NewReader(src, hash.HASH_TYPE_SHA256)); err != nil {
+		return p, err
+	}
+	p.Hashes.Sum()
+	p.Hashes.Reset()
+	return p, nil
+}
+
+// recordPart journals a part the volume has confirmed.
+func (r *UploadRequest) recordPart(ctx context.Context, num, size int64, hashes hash.HashBlock) {
+	if r.journal == nil {
+		return
+	}
+	r.journal.Parts = append(r.journal.Parts, trimmer.UploadPartRecord{
+		PartId: num,
+		Size:   size,
+		Hashes: hashes.Clone(hash.HASH_TYPE_SHA256),
+	})
+	r.saveJournal(ctx)
+}
+
+// saveJournal stores the upload's record. Failures only cost the ability to
+// resume, so they are logged.
+func (r *UploadRequest) saveJournal(ctx context.Context) {
+	if r.C.Journal == nil || r.journal == nil {
+		return
+	}
+	if err := r.C.Journal.Save(r.journal); err != nil {
+		trimmer.LoggerFromContext(ctx).Warn("saving upload journal failed", trimmer.F("filename", r.Filename), trimmer.F("error", err))
+	}
+}
+
+// forget removes the upload from the journal after commit or abort.
+func (r *UploadRequest) forget(ctx context.Context) {
+	r.journal = nil
+	if r.C.Journal == nil {
+		return
+	}
+	if err := r.C.Journal.Delete(r.JournalKey()); err != nil {
+		trimmer.LoggerFromContext(ctx).Warn("removing upload journal failed", trimmer.F("filename", r.Filename), trimmer.F("error", err))
+	}
+}
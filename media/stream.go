@@ -0,0 +1,178 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package media
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+
+	trimmer "trimmer.io/go-trimmer"
+	"trimmer.io/go-trimmer/hash"
+)
+
+// UploadStream uploads media data from a reader that cannot seek, like a
+// pipe. See Client.UploadStream.
+func UploadStream(ctx context.Context, m *trimmer.Media, src io.Reader) (*trimmer.FileInfo, error) {
+	return getC().UploadStream(ctx, m, src)
+}
+
+func NewStreamUploadRequest(fi *trimmer.FileInfo, dst *trimmer.Media, src io.Reader) *UploadRequest {
+	return getC().NewStreamUploadRequest(fi, dst, src)
+}
+
+// UploadStream uploads media data from a reader that cannot seek, like a
+// pipe, a tar stream or an encoder's output. The stream is sent with the
+// multipart protocol one buffered part at a time and hashed while reading,
+// so neither the size nor the hashes of dst need to be known in advance.
+// A known size of dst must match the stream and helps to choose the part
+// size. Streams of unknown size start with small parts that double every
+// 1/32 of the volume's PartsMax parts up to PartSizeMax. Starting at 16 MiB
+// with a 5 GiB limit, a stream may reach about 23/32 of PartsMax times
+// PartSizeMax bytes.
+func (c Client) UploadStream(ctx context.Context, dst *trimmer.Media, src io.Reader) (*trimmer.FileInfo, error) {
+	fi, err := uploadFileInfo(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	// overwrites media on completion
+	return c.finishUpload(ctx, dst, c.NewStreamUploadRequest(fi, dst, src))
+}
+
+// NewStreamUploadRequest creates an upload request that reads from stream
+// src. A zero fi.Size means the size is unknown until src ends.
+func (c Client) NewStreamUploadRequest(fi *trimmer.FileInfo, dst *trimmer.Media, src io.Reader) *UploadRequest {
+	r := c.NewUploadRequest(fi, dst, nil)
+	r.Stream = src
+	if r.Size <= 0 && r.Query != nil {
+		r.Query.Del("size")
+	}
+	return r
+}
+
+// uploadStream sends Stream part by part. Each part is buffered in memory,
+// so failed parts can be sent again. The final size and hashes are sent on
+// commit. Streams cannot be resumed, so failed uploads are always aborted.
+func (r *UploadRequest) uploadStream(ctx context.Context) (size int64, hashes hash.HashBlock, err error) {
+
+	// a size hint helps to choose a part size that fits the volume limits
+	r.PartSize = r.CalculatePartSize()
+
+	if err = r.startStream(ctx); err != nil {
+		return
+	}
+
+	// on subsequent failure abort upload
+	defer r.abortMulti(ctx)
+
+	// hash with all types the volume requires and the caller expects
+	flags := r.Manifest.HashTypes.Flags() | r.Hashes.Flags() | hash.DefaultHash.Flag()
+	var total hash.HashBlock
+	src := total.NewReader(r.Stream, flags)
+
+	var buf []byte
+	for num, last := int64(1), false; !last; num++ {
+		partSize := r.PartSize
+		if r.Size <= 0 {
+			partSize = r.streamPartSize(num)
+		}
+		if int64(cap(buf)) < partSize {
+			buf = make([]byte, partSize)
+		}
+		n, rerr := io.ReadFull(src, buf[:partSize])
+		switch rerr {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			last = true
+		default:
+			return 0, hash.HashBlock{}, rerr
+		}
+
+		// streams ending at a part boundary have no empty last part, but
+		// empty streams are sent as a single empty part
+		if n == 0 && num > 1 {
+			break
+		}
+		if l := r.Manifest.Limits; l != nil && l.PartsMax > 0 && num > l.PartsMax {
+			return 0, hash.HashBlock{}, trimmer.NewUsageError("upload stream exceeds volume part limit", nil)
+		}
+
+		part := buf[:n]
+		sent, h, err := r.sendPart(ctx, partJob{num: num}, func() (io.Reader, error) {
+			return bytes.NewReader(part), nil
+		})
+		if err != nil {
+			return 0, hash.HashBlock{}, err
+		}
+		r.partStored(ctx, num, sent, h)
+		r.PartNum = num + 1
+	}
+	total.Sum()
+
+	// compare the stream with hashes known in advance
+	if err = r.Hashes.Check(total, true); err != nil {
+		return 0, hash.HashBlock{}, trimmer.NewChecksumError(r.Hashes, total.Clone(flags))
+	}
+
+	// the volume checks final size and hashes at commit
+	if r.Size <= 0 {
+		r.Size = r.UploadedSize
+		r.Query.Set("size", strconv.FormatInt(r.Size, 10))
+	}
+	r.Hashes = total.Clone(flags)
+	hashes, err = r.commitMulti(ctx)
+	size = r.UploadedSize
+	return
+}
+
+// streamPartSize returns the size of part num of a stream of unknown size.
+// Starting at PartSize, the size doubles every 1/32 of the volume's PartsMax
+// parts until it reaches PartSizeMax, so short streams use small buffers and
+// long streams do not run out of parts.
+func (r *UploadRequest) streamPartSize(num int64) int64 {
+	s := r.PartSize
+	l := r.Manifest.Limits
+	if l == nil || l.PartsMax <= 0 || l.PartSizeMax <= 0 {
+		return s
+	}
+	step := max(l.PartsMax/32, 1)
+	for n := (num - 1) / step; n > 0 && s < l.PartSizeMax; n-- {
+		s *= 2
+	}
+	return min(s, l.PartSizeMax)
+}
+
+// startStream starts a new multipart upload. Unfinished uploads of the same
+// file the volume continues are aborted, because their parts cannot be
+// checked against a stream.
+func (r *UploadRequest) startStream(ctx context.Context) error {
+	for {
+		upload, err := r.initMulti(ctx)
+		if err != nil {
+			return err
+		}
+		if upload.State != UploadStateProgress {
+			return nil
+		}
+		trimmer.LoggerFromContext(ctx).Info("aborting unfinished upload of stream", trimmer.F("filename", r.Filename), trimmer.F("uploadId", r.UploadId))
+		if err := r.abortMulti(ctx); err != nil {
+			return err
+		}
+	}
+}
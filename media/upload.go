@@ -19,6 +19,7 @@ package media
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -90,23 +91,27 @@ type PartInfoList []*PartInfo
 type ProgressFunc func(ctx context.Context, r *UploadRequest, size int64)
 
 type UploadRequest struct {
-	C            Client
-	Reader       io.ReadSeeker
-	Media        *trimmer.Media
-	Size         int64
-	JobId        string
-	Url          string
-	Query        url.Values
-	Filename     string
-	UUID         string
-	Mimetype     string
-	Hashes       hash.HashBlock
-	UploadId     string
-	PartNum      int64
-	PartSize     int64
-	UploadedSize int64
-	Manifest     *trimmer.VolumeManifest
-	Progress     ProgressFunc
+	C             Client
+	Reader        io.ReadSeeker
+	Stream        io.Reader // non-seekable source, used instead of Reader
+	Media         *trimmer.Media
+	Size          int64
+	JobId         string
+	Url           string
+	Query         url.Values
+	Filename      string
+	UUID          string
+	Mimetype      string
+	Hashes        hash.HashBlock
+	UploadId      string
+	PartNum       int64
+	PartSize      int64
+	UploadedSize  int64
+	Manifest      *trimmer.VolumeManifest
+	Progress      ProgressFunc
+	Concurrency   int // parts sent in parallel, 0 uses the context or client setting
+	FailurePolicy trimmer.UploadFailurePolicy
+	journal       *trimmer.UploadRecord
 }
 
 type ManifestCache struct {
@@ -154,6 +159,17 @@ func UploadImage(ctx context.Context, uri string, params *trimmer.FileInfo, src
 }
 
 func (c Client) Upload(ctx context.Context, dst *trimmer.Media, src io.ReadSeeker) (*trimmer.FileInfo, error) {
+	fi, err := uploadFileInfo(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	// overwrites media on completion
+	return c.finishUpload(ctx, dst, c.NewUploadRequest(fi, dst, src))
+}
+
+// uploadFileInfo describes the file to upload for media dst.
+func uploadFileInfo(dst *trimmer.Media) (*trimmer.FileInfo, error) {
 	if dst == nil {
 		return nil, trimmer.ENilPointer
 	}
@@ -171,11 +187,12 @@ func (c Client) Upload(ctx context.Context, dst *trimmer.Media, src io.ReadSeeke
 		Mimetype: dst.Mimetype,
 		Url:      dst.Url,
 	}
+	return fi, nil
+}
 
-	// overwrites media on completion
-	var err error
-	r := c.NewUploadRequest(fi, dst, src)
-	fi, err = r.Do(ctx)
+// finishUpload sends r and updates dst after the upload job has completed.
+func (c Client) finishUpload(ctx context.Context, dst *trimmer.Media, r *UploadRequest) (*trimmer.FileInfo, error) {
+	fi, err := r.Do(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -396,7 +413,8 @@ func (c Client) ProgressUpload(ctx context.Context, r *UploadRequest, size int64
 	p := &trimmer.JobParams{
 		Progress: int(size * 100 / r.Media.Size),
 	}
-	if _, err := job.Update(ctx, r.Media.JobId, p); err == nil {
+	jc := job.Client{B: c.B, CDN: c.CDN, Key: c.Key, Sess: c.Sess}
+	if _, err := jc.Update(ctx, r.Media.JobId, p); err == nil {
 		c.lastProgress = now
 	}
 	return
@@ -416,6 +434,8 @@ func (c Client) NewUploadRequest(fi *trimmer.FileInfo, dst *trimmer.Media, src i
 		Hashes:   fi.Hashes,
 		Progress: c.ProgressUpload,
 		Query:    url.Values{},
+
+		FailurePolicy: c.FailurePolicy,
 	}
 
 	parts := strings.Split(fi.Url, "?")
@@ -457,6 +477,13 @@ func (r UploadRequest) HasCallback() bool {
 	return r.Query.Get("cb") != ""
 }
 
+// Resumable returns true when a failed multipart upload was kept on the
+// volume by the KeepFailedUploads policy. Uploading the same file to the
+// same media again sends only the missing parts.
+func (r UploadRequest) Resumable() bool {
+	return r.FailurePolicy == trimmer.KeepFailedUploads && r.Stream == nil && r.UploadId != ""
+}
+
 func (r *UploadRequest) SingleUrl() string {
 	return fmt.Sprintf("%s?%s", r.Url, r.Query.Encode())
 }
@@ -470,8 +497,25 @@ func (r *UploadRequest) InitMultiUrl() string {
 }
 
 func (r *UploadRequest) UploadMultiUrl(part int64) string {
-	r.Query.Set("part", strconv.FormatInt(part, 10))
-	return fmt.Sprintf("%s/%s?%s", r.Url, r.UploadId, r.Query.Encode())
+	return r.partUrl(part, false)
+}
+
+// partUrl returns the URL for uploading a part. It leaves r.Query unchanged,
+// so parts can be sent in parallel.
+func (r *UploadRequest) partUrl(part int64, overwrite bool) string {
+	q := make(url.Values, len(r.Query)+2)
+	for k, v := range r.Query {
+		q[k] = v
+	}
+	q.Set("part", strconv.FormatInt(part, 10))
+	// signal a part is supposed to be overwritten
+	// (i.e. because of earlier checksum failure)
+	if overwrite {
+		q.Set("overwrite", "true")
+	} else {
+		q.Del("overwrite")
+	}
+	return fmt.Sprintf("%s/%s?%s", r.Url, r.UploadId, q.Encode())
 }
 
 func (r *UploadRequest) EndMultiUrl() string {
@@ -491,11 +535,10 @@ func (r *UploadRequest) uploadSingle(ctx context.Context) (int64, hash.HashBlock
 		ContentDisposition: ct.Encode(),
 		Hashes:             r.Hashes,
 		Size:               r.Size,
+		Scope:              trimmer.API_SCOPE_UPLOAD,
 	}
 
-	if trimmer.LogLevel > 2 {
-		trimmer.Logger.Printf("Uploading single file %s %d bytes", r.Filename, r.Size)
-	}
+	trimmer.LoggerFromContext(ctx).Debug("uploading single file", trimmer.F("filename", r.Filename), trimmer.F("size", r.Size))
 
 	i := &UploadInfo{}
 	_, clientHashes, serverHashes, err := r.C.CDN.CallChecksum(ctx, http.MethodPut, r.SingleUrl(), r.C.Key, r.C.Sess, h, r.Hashes.AnyFlag(), r.Reader, nil, i)
@@ -504,20 +547,16 @@ func (r *UploadRequest) uploadSingle(ctx context.Context) (int64, hash.HashBlock
 	}
 
 	if err = clientHashes.Check(serverHashes, true); err != nil {
-		if trimmer.LogLevel > 0 {
-			trimmer.Logger.Println("ERROR: checksum mismatch", err.Error())
-		}
-		return i.Size, i.Hashes, err
+		trimmer.LoggerFromContext(ctx).Error("checksum mismatch", trimmer.F("filename", r.Filename), trimmer.F("error", err))
+		return i.Size, i.Hashes, trimmer.NewChecksumError(clientHashes, serverHashes)
 	}
 
-	if trimmer.LogLevel > 2 {
-		trimmer.Logger.Printf("Upload success VolumeUUID=%s hashes=%s", i.VolumeUUID, i.Hashes.String())
-	}
+	trimmer.LoggerFromContext(ctx).Debug("upload success", trimmer.F("volumeUuid", i.VolumeUUID), trimmer.F("hashes", i.Hashes.String()))
 
 	return i.Size, i.Hashes, nil
 }
 
-func (r *UploadRequest) initMulti(ctx context.Context) error {
+func (r *UploadRequest) initMulti(ctx context.Context) (*UploadInfo, error) {
 
 	ct := rfc.NewContentDisposition("attachement")
 	ct.Set("filename", r.Filename)
@@ -527,46 +566,39 @@ func (r *UploadRequest) initMulti(ctx context.Context) error {
 	h := &trimmer.CallHeaders{
 		ContentType:        r.Mimetype,
 		ContentDisposition: ct.Encode(),
+		Scope:              trimmer.API_SCOPE_UPLOAD,
 	}
 
 	upload := &UploadInfo{}
 	err := r.C.CDN.Call(ctx, http.MethodPost, r.InitMultiUrl(), r.C.Key, r.C.Sess, h, nil, upload)
 	if err != nil {
-		return err
-	}
-
-	// check upload state, assuming sequential upload progress
-	if upload.State == UploadStateProgress {
-		r.PartNum = upload.TotalParts + 1
+		return nil, err
 	}
 
 	r.UploadId = upload.UploadId
-	return nil
+	return upload, nil
 }
 
-func (r *UploadRequest) uploadPart(ctx context.Context, reader io.Reader, overwrite bool) error {
+func (r *UploadRequest) uploadPart(ctx context.Context, num int64, reader io.Reader, overwrite bool) (int64, hash.HashBlock, error) {
 	// don't send on empty upload id
 	if r.UploadId == "" {
-		return trimmer.NewInternalError("empty upload id", nil)
+		return 0, hash.HashBlock{}, trimmer.NewInternalError("empty upload id", nil)
 	}
 
 	// CDN requires special headers
 	h := &trimmer.CallHeaders{
 		ContentType: r.Mimetype,
+		Scope:       trimmer.API_SCOPE_UPLOAD,
 	}
 
-	// signal a part is supposed to be overwritten
-	// (i.e. because of earlier checksum failure)
-	if overwrite {
-		r.Query.Set("overwrite", "true")
-	} else {
-		r.Query.Del("overwrite")
-	}
+	// hide io.Seeker, so the backend does not replay parts; sendPart retries
+	// them with overwrite set
+	reader = struct{ io.Reader }{reader}
 
 	i := &UploadInfo{}
-	_, clientHash, serverHash, err := r.C.CDN.CallChecksum(ctx, http.MethodPut, r.UploadMultiUrl(r.PartNum), r.C.Key, r.C.Sess, h, hash.HASH_TYPE_SHA256, io.LimitReader(reader, r.PartSize), nil, i)
+	_, clientHash, serverHash, err := r.C.CDN.CallChecksum(ctx, http.MethodPut, r.partUrl(num, overwrite), r.C.Key, r.C.Sess, h, hash.HASH_TYPE_SHA256, reader, nil, i)
 	if err != nil {
-		return err
+		return 0, hash.HashBlock{}, err
 	}
 
 	// verify part checksums
@@ -581,15 +613,72 @@ func (r *UploadRequest) uploadPart(ctx context.Context, reader io.Reader, overwr
 	//       x-trimmer-hash header is missing)
 	//
 	if err := clientHash.Check(serverHash, true); err != nil {
-		if trimmer.LogLevel > 0 {
-			trimmer.Logger.Println("ERROR: checksum mismatch on part", r.PartNum, err.Error())
+		trimmer.LoggerFromContext(ctx).Error("checksum mismatch on part", trimmer.F("part", num), trimmer.F("error", err))
+		return 0, hash.HashBlock{}, trimmer.NewChecksumError(clientHash, serverHash)
+	}
+
+	if i.Part == nil {
+		return 0, hash.HashBlock{}, trimmer.NewInternalError("missing part info in upload response", nil)
+	}
+	return i.Part.Size, clientHash, nil
+}
+
+// partJob is a part to send. Parts already stored on the volume, e.g.
+// corrupt parts of a resumed upload, must be overwritten.
+type partJob struct {
+	num       int64
+	overwrite bool
+}
+
+// sendPart uploads a part from the reader returned by open. Parts that fail
+// with checksum mismatches or transient network and server errors are read
+// again and overwritten, because the volume may have stored them before the
+// failure.
+func (r *UploadRequest) sendPart(ctx context.Context, job partJob, open func() (io.Reader, error)) (int64, hash.HashBlock, error) {
+	policy := r.retryPolicy()
+	num, overwrite := job.num, job.overwrite
+	var retryAfter time.Duration
+	for retry := 0; ; retry++ {
+		if retry > 0 {
+			wait := policy.Delay(retry, retryAfter)
+			trimmer.LoggerFromContext(ctx).Info("retrying part upload", trimmer.F("part", num), trimmer.F("wait", wait), trimmer.F("retry", retry))
+			if err := trimmer.SleepContext(ctx, wait); err != nil {
+				return 0, hash.HashBlock{}, err
+			}
+		}
+		reader, err := open()
+		if err != nil {
+			return 0, hash.HashBlock{}, err
+		}
+		size, hashes, err := r.uploadPart(ctx, num, reader, overwrite)
+		if err == nil {
+			return size, hashes, nil
+		}
+
+		// fail when the upload has been cancelled or removed
+		if retry >= policy.MaxRetries || ctx.Err() != nil || !isPartRetryable(err) {
+			return 0, hash.HashBlock{}, err
+		}
+		overwrite = true
+		retryAfter = 0
+		if e, ok := err.(trimmer.TrimmerError); ok {
+			retryAfter = e.RetryAfter
 		}
-		return err
 	}
+}
 
-	r.UploadedSize += i.Part.Size
+// isPartRetryable returns true for part upload errors that are worth sending
+// the part again. Removed uploads (ENotFound) are never retried.
+func isPartRetryable(err error) bool {
+	return errors.Is(err, trimmer.EChecksumMismatch) || errors.Is(err, trimmer.ETransient)
+}
 
-	return nil
+// retryPolicy returns the client's part retry policy or the default policy.
+func (r *UploadRequest) retryPolicy() trimmer.RetryPolicy {
+	if r.C.Retry != nil {
+		return *r.C.Retry
+	}
+	return trimmer.DefaultRetryPolicy
 }
 
 func (r *UploadRequest) abortMulti(ctx context.Context) error {
@@ -606,6 +695,7 @@ func (r *UploadRequest) abortMulti(ctx context.Context) error {
 	h := &trimmer.CallHeaders{
 		ContentType:        r.Mimetype,
 		ContentDisposition: ct.Encode(),
+		Scope:              trimmer.API_SCOPE_UPLOAD,
 	}
 
 	err := r.C.CDN.Call(ctx, http.MethodDelete, r.EndMultiUrl(), r.C.Key, r.C.Sess, h, nil, nil)
@@ -615,6 +705,7 @@ func (r *UploadRequest) abortMulti(ctx context.Context) error {
 
 	// clear upload id
 	r.UploadId = ""
+	r.forget(ctx)
 	return nil
 }
 
@@ -633,6 +724,7 @@ func (r *UploadRequest) commitMulti(ctx context.Context) (hash.HashBlock, error)
 		ContentType:        r.Mimetype,
 		ContentDisposition: ct.Encode(),
 		Hashes:             r.Hashes,
+		Scope:              trimmer.API_SCOPE_UPLOAD,
 	}
 
 	i := &UploadInfo{}
@@ -648,13 +740,17 @@ func (r *UploadRequest) commitMulti(ctx context.Context) (hash.HashBlock, error)
 
 	// clear upload id (so the subsequent `defer abort()` will not execute)
 	r.UploadId = ""
+	r.forget(ctx)
 	return i.Hashes, nil
 }
 
 func (r *UploadRequest) CalculatePartSize() int64 {
 
 	// use user-defined minimum/default
-	s := trimmer.UploadPartSize
+	s := r.C.PartSize
+	if s <= 0 {
+		s = trimmer.UploadPartSize
+	}
 
 	// requires volume manifest to be present
 	if r.Manifest == nil {
@@ -681,67 +777,175 @@ func (r *UploadRequest) CalculatePartSize() int64 {
 	return s
 }
 
+// PartConcurrency returns the number of parts sent in parallel. Concurrency
+// takes precedence over the context and the client setting, the volume's
+// PartsParallelMax limit caps all of them.
+func (r *UploadRequest) PartConcurrency(ctx context.Context) int {
+	n := r.Concurrency
+	if n <= 0 {
+		n = trimmer.PartConcurrencyFromContext(ctx)
+	}
+	if n <= 0 {
+		n = r.C.PartConcurrency
+	}
+	if n <= 0 {
+		n = trimmer.UploadPartConcurrency
+	}
+	if r.Manifest != nil && r.Manifest.Limits != nil {
+		if max := r.Manifest.Limits.PartsParallelMax; max > 0 && int64(n) > max {
+			n = int(max)
+		}
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
 func (r *UploadRequest) uploadMulti(ctx context.Context) (size int64, hashes hash.HashBlock, err error) {
 
 	// first, choose a reasonable part size between PartSizeMin & PartSizeMax
 	r.PartSize = r.CalculatePartSize()
 
-	// init upload
-	if err = r.initMulti(ctx); err != nil {
+	// init or resume upload
+	var todo []partJob
+	if todo, err = r.startMulti(ctx); err != nil {
 		return
 	}
 
-	// on subsequent failure abort upload
-	defer r.abortMulti(ctx)
+	// on subsequent failure abort upload, unless it should be resumed later
+	defer func() {
+		if err != nil && r.FailurePolicy == trimmer.KeepFailedUploads {
+			trimmer.LoggerFromContext(ctx).Info("keeping failed upload", trimmer.F("filename", r.Filename), trimmer.F("uploadId", r.UploadId))
+			return
+		}
+		r.abortMulti(ctx)
+	}()
+
+	// upload missing parts (assuming sizeHint is correct); parts are only
+	// sent in parallel when they can be read independently
+	_, ok := r.Reader.(io.ReaderAt)
+	if n := r.PartConcurrency(ctx); ok && n > 1 && len(todo) > 1 {
+		err = r.uploadParallel(ctx, todo, n)
+	} else {
+		err = r.uploadSequential(ctx, todo)
+	}
+	if err != nil {
+		return
+	}
 
-	// upload all parts (assuming sizeHint is correct); consider continuation
-	var sz int64 = (r.PartNum - 1) * r.PartSize
-	var retries int = trimmer.MaxRetries
-	var overwritePart bool = false
-	r.Reader.Seek(sz, io.SeekStart)
+	// finish upload after all parts are stored
+	hashes, err = r.commitMulti(ctx)
+	size = r.UploadedSize
+	return
+}
 
-	for sz < r.Size {
-		// retry on checksum errors
-		if err = r.uploadPart(ctx, r.Reader, overwritePart); err != nil {
-			// fail when upload has been cancelled
-			if e, ok := err.(trimmer.TrimmerError); ok && e.IsApi() && e.StatusCode == 404 {
-				return
-			}
+// partReader returns a reader for part num of Reader.
+func (r *UploadRequest) partReader(num int64) (io.Reader, error) {
+	off := (num - 1) * r.PartSize
+	if src, ok := r.Reader.(io.ReaderAt); ok {
+		return io.NewSectionReader(src, off, min(r.PartSize, r.Size-off)), nil
+	}
+	if _, err := r.Reader.Seek(off, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.LimitReader(r.Reader, r.PartSize), nil
+}
 
-			// FIXME: support retries on more transient error conditions
-			//        such as io/network errors and 5xx server errors
-			//
-			retries--
-			if retries == 0 || err != hash.EInvalidHash {
-				return
-			}
-			r.Reader.Seek(sz, io.SeekStart)
-			overwritePart = true
-			err = nil
-			wait := trimmer.RetryBackoffTime * time.Duration(trimmer.MaxRetries-retries-1)
-			if trimmer.LogLevel > 1 {
-				trimmer.Logger.Printf("Retrying upload in %v", wait)
-			}
-			time.Sleep(wait)
+// partStored updates size, journal and progress after a part was sent.
+func (r *UploadRequest) partStored(ctx context.Context, num, size int64, hashes hash.HashBlock) {
+	r.UploadedSize += size
+	r.recordPart(ctx, num, size, hashes)
 
-		} else {
-			// auto-progress update
-			if r.Progress != nil {
-				r.Progress(ctx, r, sz)
-			}
+	// auto-progress update
+	if r.Progress != nil {
+		r.Progress(ctx, r, r.UploadedSize)
+	}
+}
 
-			// on success upload next part
-			overwritePart = false
-			retries = trimmer.MaxRetries
-			r.PartNum++
-			sz += r.PartSize
+// uploadSequential sends parts one after another from Reader.
+func (r *UploadRequest) uploadSequential(ctx context.Context, todo []partJob) error {
+	for _, job := range todo {
+		n, h, err := r.sendPart(ctx, job, func() (io.Reader, error) {
+			return r.partReader(job.num)
+		})
+		if err != nil {
+			return err
 		}
+		r.partStored(ctx, job.num, n, h)
+
+		// on success upload next part
+		r.PartNum = job.num + 1
 	}
+	return nil
+}
 
-	// finish upload
-	hashes, err = r.commitMulti(ctx)
-	size = r.UploadedSize
-	return
+// uploadParallel sends parts from workers goroutines, each reading its own
+// section of Reader, which must be an io.ReaderAt. It returns after all
+// started parts have finished. The first error cancels the parts still in
+// flight.
+func (r *UploadRequest) uploadParallel(ctx context.Context, todo []partJob, workers int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		num, size int64
+		hashes    hash.HashBlock
+		err       error
+	}
+	workers = min(workers, len(todo))
+
+	jobs := make(chan partJob)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				n, h, err := r.sendPart(ctx, job, func() (io.Reader, error) {
+					return r.partReader(job.num)
+				})
+				results <- result{job.num, n, h, err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, job := range todo {
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var err error
+	for res := range results {
+		if err != nil {
+			continue
+		}
+		if res.err != nil {
+			err = res.err
+			cancel()
+			continue
+		}
+		r.partStored(ctx, res.num, res.size, res.hashes)
+	}
+	if err == nil {
+		// parts may have been skipped when ctx was cancelled
+		err = ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	r.PartNum = todo[len(todo)-1].num + 1
+	return nil
 }
 
 // hides the complexity of single/multipart upload handling
@@ -772,9 +976,12 @@ func (r *UploadRequest) Do(ctx context.Context) (*trimmer.FileInfo, error) {
 		hashes hash.HashBlock
 	)
 
-	if r.Size < r.Manifest.Limits.SinglePartMax {
+	switch {
+	case r.Stream != nil:
+		size, hashes, err = r.uploadStream(ctx)
+	case r.Size < r.Manifest.Limits.SinglePartMax:
 		size, hashes, err = r.uploadSingle(ctx)
-	} else {
+	default:
 		size, hashes, err = r.uploadMulti(ctx)
 	}
 
@@ -843,6 +1050,7 @@ func (c Client) UploadImage(ctx context.Context, uri string, params *trimmer.Fil
 
 	ch := &trimmer.CallHeaders{
 		ContentType: "multipart/form-data; boundary=" + writer.Boundary(),
+		Scope:       trimmer.API_SCOPE_UPLOAD,
 	}
 
 	v := &trimmer.Media{}
@@ -25,10 +25,10 @@ import (
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.Member]
 }
 
 // Member returns the most recent User visited by a call to Next.
 func (i *Iter) Member() *trimmer.Member {
-	return i.Current().(*trimmer.Member)
+	return i.Item()
 }
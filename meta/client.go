@@ -25,10 +25,10 @@ import (
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.MetaRevision]
 }
 
 // Revision returns the most recent metadata revision visited by a call to Next.
 func (i *Iter) Revision() *trimmer.MetaRevision {
-	return i.Current().(*trimmer.MetaRevision)
+	return i.Item()
 }
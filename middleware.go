@@ -0,0 +1,72 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"net/http"
+)
+
+// RoundTrip sends a single HTTP request to a backend and returns its response.
+type RoundTrip func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps a RoundTrip to add cross-cutting behaviour like extra
+// headers, auditing or response rewriting. A middleware may also answer a
+// request itself without calling next, e.g. to stub out calls in tests.
+//
+// Middlewares run once per attempt, so a request that is retried passes the
+// chain again. They apply to JSON, multipart and checksummed CDN calls alike.
+type Middleware func(next RoundTrip) RoundTrip
+
+var (
+	apiMiddleware []Middleware
+	cdnMiddleware []Middleware
+)
+
+// Use appends middlewares to the backend's chain. The first middleware added
+// is the outermost one and sees requests first and responses last.
+func (s *BackendConfiguration) Use(mw ...Middleware) {
+	chain := make([]Middleware, 0, len(s.Middleware)+len(mw))
+	chain = append(chain, s.Middleware...)
+	s.Middleware = append(chain, mw...)
+}
+
+// Use appends middlewares to the chain of the package-level backend.
+func Use(backend SupportedBackend, mw ...Middleware) {
+	switch backend {
+	case APIBackend:
+		apiMiddleware = append(apiMiddleware, mw...)
+		if b, ok := backends.API.(BackendConfiguration); ok {
+			b.Use(mw...)
+			backends.API = b
+		}
+	case CDNBackend:
+		cdnMiddleware = append(cdnMiddleware, mw...)
+		if b, ok := backends.CDN.(BackendConfiguration); ok {
+			b.Use(mw...)
+			backends.CDN = b
+		}
+	}
+}
+
+// roundTrip builds the middleware chain around the backend's HTTP client.
+func (s *BackendConfiguration) roundTrip() RoundTrip {
+	rt := RoundTrip(s.HTTPClient.Do)
+	for i := len(s.Middleware) - 1; i >= 0; i-- {
+		rt = s.Middleware[i](rt)
+	}
+	return rt
+}
@@ -0,0 +1,64 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewareOrderAndShortCircuit(t *testing.T) {
+	LogLevel = 0
+	var order []string
+	b := newTestBackend("http://127.0.0.1:1")
+	b.Use(func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			order = append(order, "outer")
+			req.Header.Set("X-Audit", "yes")
+			return next(req)
+		}
+	}, func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			order = append(order, "inner")
+			if req.Header.Get("X-Audit") != "yes" {
+				t.Error("header from outer middleware missing")
+			}
+			// answer without touching the network
+			return &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        http.Header{"Content-Type": {"application/json"}},
+				Body:          ioutil.NopCloser(strings.NewReader(`{"ID":"stub"}`)),
+				ContentLength: -1,
+				Request:       req,
+			}, nil
+		}
+	})
+
+	v := &struct{ ID string }{}
+	if err := b.Call(context.Background(), http.MethodGet, "/x", "key", nil, nil, nil, v); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.ID != "stub" {
+		t.Errorf("expected stubbed response, got %q", v.ID)
+	}
+	if strings.Join(order, ",") != "outer,inner" {
+		t.Errorf("unexpected middleware order %v", order)
+	}
+}
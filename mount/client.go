@@ -25,10 +25,10 @@ import (
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.Mount]
 }
 
 // Mount returns the most recent Mount visited by a call to Next.
 func (i *Iter) Mount() *trimmer.Mount {
-	return i.Current().(*trimmer.Mount)
+	return i.Item()
 }
@@ -49,12 +49,12 @@ func getC() Client {
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.Org]
 }
 
 // Org returns the most recent Org visited by a call to Next.
 func (i *Iter) Org() *trimmer.Org {
-	return i.Current().(*trimmer.Org)
+	return i.Item()
 }
 
 func Get(ctx context.Context, orgId string, params *trimmer.OrgParams) (*trimmer.Org, error) {
@@ -66,8 +66,7 @@ func Update(ctx context.Context, orgId string, params *trimmer.OrgParams) (*trim
 }
 
 func UploadImage(ctx context.Context, orgId string, params *trimmer.FileInfo, src io.Reader) (*trimmer.Media, error) {
-	uri := fmt.Sprintf("/orgs/%v/media", orgId)
-	return media.UploadImage(ctx, uri, params, src)
+	return getC().UploadImage(ctx, orgId, params, src)
 }
 
 func ListMedia(ctx context.Context, orgId string, params *trimmer.MediaListParams) *media.Iter {
@@ -133,14 +132,22 @@ func (c Client) Update(ctx context.Context, orgId string, params *trimmer.OrgPar
 		return nil, trimmer.ENilPointer
 	}
 	v := &trimmer.Org{}
-	err := c.B.Call(ctx, http.MethodPatch, fmt.Sprintf("/orgs/%v", orgId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPatch, fmt.Sprintf("/orgs/%v", orgId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, params, v)
 	return v, err
 }
 
+func (c Client) UploadImage(ctx context.Context, orgId string, params *trimmer.FileInfo, src io.Reader) (*trimmer.Media, error) {
+	if orgId == "" {
+		return nil, trimmer.EIDMissing
+	}
+	mc := media.Client{B: c.B, Key: c.Key, Sess: c.Sess}
+	return mc.UploadImage(ctx, fmt.Sprintf("/orgs/%v/media", orgId), params, src)
+}
+
 func (c Client) ListMedia(ctx context.Context, orgId string, params *trimmer.MediaListParams) *media.Iter {
 
 	if orgId == "" {
-		return &media.Iter{Iter: trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &media.Iter{Iter: trimmer.GetIterErr[*trimmer.Media](trimmer.EIDMissing)}
 	}
 
 	type mediaList struct {
@@ -182,24 +189,17 @@ func (c Client) ListMedia(ctx context.Context, orgId string, params *trimmer.Med
 		lp = &params.ListParams
 	}
 
-	return &media.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &media.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Media, trimmer.ListMeta, error) {
 		list := &mediaList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/orgs/%v/media?%v", orgId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
 func (c Client) ListEvents(ctx context.Context, orgId string, params *trimmer.EventListParams) *event.Iter {
 
 	if orgId == "" {
-		return &event.Iter{trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &event.Iter{trimmer.GetIterErr[*trimmer.Event](trimmer.EIDMissing)}
 	}
 
 	type eventList struct {
@@ -226,17 +226,10 @@ func (c Client) ListEvents(ctx context.Context, orgId string, params *trimmer.Ev
 		lp = &params.ListParams
 	}
 
-	return &event.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &event.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Event, trimmer.ListMeta, error) {
 		list := &eventList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/orgs/%v/events?%v", orgId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -245,14 +238,14 @@ func (c Client) NewWorkspace(ctx context.Context, orgId string, params *trimmer.
 		return nil, trimmer.ENilPointer
 	}
 	v := &trimmer.Workspace{}
-	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/orgs/%v/workspaces", orgId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/orgs/%v/workspaces", orgId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
 func (c Client) ListWorkspaces(ctx context.Context, orgId string, params *trimmer.WorkspaceListParams) *workspace.Iter {
 
 	if orgId == "" {
-		return &workspace.Iter{trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &workspace.Iter{trimmer.GetIterErr[*trimmer.Workspace](trimmer.EIDMissing)}
 	}
 
 	type workspaceList struct {
@@ -282,17 +275,10 @@ func (c Client) ListWorkspaces(ctx context.Context, orgId string, params *trimme
 		lp = &params.ListParams
 	}
 
-	return &workspace.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &workspace.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Workspace, trimmer.ListMeta, error) {
 		list := &workspaceList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/orgs/%v/workspaces?%v", orgId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -301,14 +287,14 @@ func (c Client) NewVolume(ctx context.Context, orgId string, params *trimmer.Vol
 		return nil, trimmer.ENilPointer
 	}
 	v := &trimmer.Volume{}
-	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/users/%v/volumes", orgId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/users/%v/volumes", orgId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, params, v)
 	return v, err
 }
 
 func (c Client) ListVolumes(ctx context.Context, orgId string, params *trimmer.VolumeListParams) *volume.Iter {
 
 	if orgId == "" {
-		return &volume.Iter{Iter: trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &volume.Iter{Iter: trimmer.GetIterErr[*trimmer.Volume](trimmer.EIDMissing)}
 	}
 
 	type volumeList struct {
@@ -359,17 +345,10 @@ func (c Client) ListVolumes(ctx context.Context, orgId string, params *trimmer.V
 		lp = &params.ListParams
 	}
 
-	return &volume.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &volume.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Volume, trimmer.ListMeta, error) {
 		list := &volumeList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/orgs/%v/volumes?%v", orgId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -387,7 +366,7 @@ func (c Client) NewMember(ctx context.Context, orgId, userId string, params *tri
 		return nil, trimmer.EIDMissing
 	}
 	v := &trimmer.Member{}
-	err := c.B.Call(ctx, http.MethodPut, fmt.Sprintf("/orgs/%v/members/%v", orgId, userId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPut, fmt.Sprintf("/orgs/%v/members/%v", orgId, userId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, params, v)
 	return v, err
 }
 
@@ -395,17 +374,17 @@ func (c Client) DeleteMember(ctx context.Context, orgId, userId string) error {
 	if orgId == "" || userId == "" {
 		return trimmer.EIDMissing
 	}
-	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/orgs/%v/members/%v", orgId, userId), c.Key, c.Sess, nil, nil, nil)
+	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/orgs/%v/members/%v", orgId, userId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, nil, nil)
 }
 
 func (c Client) ListMembers(ctx context.Context, orgId string, params *trimmer.MemberListParams) *member.Iter {
 	if orgId == "" {
-		return &member.Iter{trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &member.Iter{trimmer.GetIterErr[*trimmer.Member](trimmer.EIDMissing)}
 	}
 
 	type memberList struct {
 		trimmer.ListMeta
-		Values trimmer.VolumeList `json:"members"`
+		Values trimmer.MemberList `json:"members"`
 	}
 
 	var q *url.Values
@@ -427,16 +406,9 @@ func (c Client) ListMembers(ctx context.Context, orgId string, params *trimmer.M
 		lp = &params.ListParams
 	}
 
-	return &member.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &member.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Member, trimmer.ListMeta, error) {
 		list := &memberList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/orgs/%v/members?%v", orgId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
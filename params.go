@@ -47,6 +47,8 @@ type ListParams struct {
 	After  time.Time `json:"after,omitempty"`
 	MaxId  string    `json:"maxId,omitempty"`
 	MinId  string    `json:"minId,omitempty"`
+	Order  ListOrder `json:"-"` // iteration order, newest first by default
+	Cursor string    `json:"-"` // resume iteration, see Iter.Cursor
 }
 
 // ListMeta is the structure that contains the common properties
@@ -73,11 +75,11 @@ func (p *ListParams) AppendTo(q *url.Values) {
 	}
 
 	if !p.Before.IsZero() {
-		q.Add(before, p.Before.String())
+		q.Add(before, p.Before.UTC().Format(time.RFC3339Nano))
 	}
 
 	if !p.After.IsZero() {
-		q.Add(after, p.After.String())
+		q.Add(after, p.After.UTC().Format(time.RFC3339Nano))
 	}
 
 	if p.Count > 0 {
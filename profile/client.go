@@ -25,10 +25,10 @@ import (
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.Profile]
 }
 
 // Profile returns the most recent Profile visited by a call to Next.
 func (i *Iter) Profile() *trimmer.Profile {
-	return i.Current().(*trimmer.Profile)
+	return i.Item()
 }
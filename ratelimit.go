@@ -0,0 +1,167 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket that shapes outgoing traffic on a backend.
+// Tokens are refilled at Rate per second up to Burst. A limiter with zero
+// Rate does not limit traffic, but can still be paused when the server
+// pushes back with 429 Too Many Requests.
+//
+// A RateLimiter is safe for concurrent use and is meant to be shared by all
+// goroutines that use the same API key.
+type RateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	until  time.Time
+	mu     sync.Mutex
+}
+
+// NewRateLimiter creates a limiter that allows rate requests per second with
+// bursts of up to burst requests.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// SetRate changes the limiter's rate and burst size.
+func (l *RateLimiter) SetRate(rate float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	l.rate = rate
+	l.burst = float64(burst)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// Pause blocks all requests through this limiter for d.
+func (l *RateLimiter) Pause(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.until) {
+		l.until = until
+	}
+}
+
+// Wait blocks until a request may be sent or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		d := l.reserve()
+		if d <= 0 {
+			return nil
+		}
+		if err := SleepContext(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// reserve takes a token and returns zero, or returns the time to wait until
+// a token may become available.
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Before(l.until) {
+		return l.until.Sub(now)
+	}
+	if l.rate <= 0 {
+		return 0
+	}
+	l.refill(now)
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+func (l *RateLimiter) refill(now time.Time) {
+	if l.rate > 0 {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+}
+
+// parseRetryAfter returns the wait time requested by a server in the
+// Retry-After header, either as delay in seconds or as HTTP date.
+func parseRetryAfter(h http.Header, now time.Time) time.Duration {
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if sec < 0 {
+			return 0
+		}
+		return time.Duration(sec) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// parseRateLimitReset returns the time until the server's rate limit window
+// resets when the X-RateLimit-Remaining header signals an exhausted quota.
+// X-RateLimit-Reset may contain a unix timestamp or a delay in seconds.
+func parseRateLimitReset(h http.Header, now time.Time) time.Duration {
+	remaining := strings.TrimSpace(h.Get("X-RateLimit-Remaining"))
+	if remaining != "0" {
+		return 0
+	}
+	reset, err := strconv.ParseInt(strings.TrimSpace(h.Get("X-RateLimit-Reset")), 10, 64)
+	if err != nil || reset <= 0 {
+		return 0
+	}
+	// values larger than one year are unix timestamps
+	if reset > 365*24*3600 {
+		if t := time.Unix(reset, 0); t.After(now) {
+			return t.Sub(now)
+		}
+		return 0
+	}
+	return time.Duration(reset) * time.Second
+}
@@ -0,0 +1,85 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryTooManyRequests(t *testing.T) {
+	LogLevel = 0
+	srv, calls := newFlakyServer(1, http.StatusTooManyRequests)
+	defer srv.Close()
+
+	// POST without idempotency key is replayed because the server rejected it
+	b := newTestBackend(srv.URL)
+	b.Limiter = NewRateLimiter(0, 1)
+	if err := b.Call(context.Background(), http.MethodPost, "/x", "key", nil, nil, nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("expected 2 calls, got %d", n)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2018, 8, 4, 12, 0, 0, 0, time.UTC)
+	h := http.Header{}
+	h.Set("Retry-After", "7")
+	if d := parseRetryAfter(h, now); d != 7*time.Second {
+		t.Errorf("expected 7s, got %v", d)
+	}
+	h.Set("Retry-After", now.Add(time.Minute).Format(http.TimeFormat))
+	if d := parseRetryAfter(h, now); d != time.Minute {
+		t.Errorf("expected 1m, got %v", d)
+	}
+	h = http.Header{}
+	h.Set("X-RateLimit-Remaining", "0")
+	h.Set("X-RateLimit-Reset", "30")
+	if d := parseRateLimitReset(h, now); d != 30*time.Second {
+		t.Errorf("expected 30s, got %v", d)
+	}
+	h.Set("X-RateLimit-Remaining", "5")
+	if d := parseRateLimitReset(h, now); d != 0 {
+		t.Errorf("expected no wait with remaining quota, got %v", d)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(100, 2)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// two requests are served from the burst, two more need 10ms each
+	if d := time.Since(start); d < 15*time.Millisecond {
+		t.Errorf("limiter too fast: %v", d)
+	}
+
+	l.Pause(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); err == nil {
+		t.Error("expected paused limiter to block until context expires")
+	}
+}
@@ -0,0 +1,186 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultRefreshMargin is how long before expiry a session is refreshed.
+const DefaultRefreshMargin = time.Minute
+
+// RefreshFunc obtains new tokens for a session and stores them with
+// Session.Update, e.g. session.Client.Refresh.
+type RefreshFunc func(ctx context.Context) error
+
+// RefreshPolicy controls automatic session refreshes.
+type RefreshPolicy struct {
+	Margin  time.Duration // refresh this long before expiry, 0 uses DefaultRefreshMargin
+	OnError func(error)   // called from any goroutine when a refresh fails
+}
+
+// refreshCall is a refresh in flight that concurrent callers wait for.
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// noRefreshKey marks contexts of refresh calls, which must not trigger
+// another refresh when they fail with 401 Unauthorized.
+type noRefreshKey struct{}
+
+// AutoRefresh keeps the session valid until stop is called. A goroutine calls
+// refresh shortly before ExpiresAt, and calls that fail with 401 Unauthorized
+// refresh the session once and are replayed before the error is returned.
+// Concurrent refreshes from goroutines sharing the session are coalesced.
+//
+// Failed refreshes are retried with backoff and reported to p.OnError. When
+// the server rejects the refresh token the session is reset and the
+// goroutine waits for the next login. Use session.AutoRefresh to refresh
+// the global LoginSession.
+func (s *Session) AutoRefresh(refresh RefreshFunc, p RefreshPolicy) (stop func()) {
+	if p.Margin <= 0 {
+		p.Margin = DefaultRefreshMargin
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	wake := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.refresh, s.refreshPolicy, s.wake = refresh, p, wake
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.refreshLoop(ctx, wake, p.Margin)
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			if s.wake == wake {
+				s.refresh, s.refreshPolicy, s.wake = nil, RefreshPolicy{}, nil
+			}
+			s.mu.Unlock()
+			cancel()
+			wg.Wait()
+		})
+	}
+}
+
+func (s *Session) refreshLoop(ctx context.Context, wake <-chan struct{}, margin time.Duration) {
+	var failures int
+	var last time.Time
+	for {
+		var timer *time.Timer
+		var fire <-chan time.Time
+		s.mu.RLock()
+		expires, canRefresh := s.ExpiresAt, s.RefreshToken != ""
+		s.mu.RUnlock()
+		if canRefresh && !expires.IsZero() {
+			d := time.Until(expires) - margin
+			if failures > 0 {
+				d = DefaultRetryPolicy.Backoff(failures + 1)
+			}
+			if min := time.Until(last.Add(time.Second)); d < min {
+				// at most one refresh per second, even when the server
+				// hands out tokens that expire within the margin
+				d = min
+			}
+			timer = time.NewTimer(d)
+			fire = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-wake:
+			// new tokens from login or another refresh
+			failures = 0
+		case <-fire:
+			last = time.Now()
+			switch err := s.Refresh(ctx); {
+			case err == nil:
+				failures = 0
+			case errors.Is(err, EUnauthorized):
+				// refresh token revoked or expired, wait for a new login
+				s.Reset()
+				failures = 0
+			case ctx.Err() == nil:
+				failures++
+			}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Refresh obtains new tokens through the RefreshFunc installed by
+// AutoRefresh. Concurrent calls share a single refresh.
+func (s *Session) Refresh(ctx context.Context) error {
+	s.mu.Lock()
+	fn, onError := s.refresh, s.refreshPolicy.OnError
+	if fn == nil {
+		s.mu.Unlock()
+		return NewUsageError("session has no automatic refresh", nil)
+	}
+	if c := s.inflight; c != nil {
+		s.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.err
+		case <-ctx.Done():
+			return NewInternalError("session refresh cancelled", ctx.Err())
+		}
+	}
+	c := &refreshCall{done: make(chan struct{})}
+	s.inflight = c
+	s.mu.Unlock()
+
+	c.err = fn(context.WithValue(ctx, noRefreshKey{}, true))
+
+	s.mu.Lock()
+	s.inflight = nil
+	s.mu.Unlock()
+	close(c.done)
+
+	if c.err != nil && ctx.Err() == nil {
+		LoggerFromContext(ctx).Error("session refresh failed", F("error", c.err))
+		if onError != nil {
+			onError(c.err)
+		}
+	}
+	return c.err
+}
+
+// canRefresh returns true when a call made with ctx that failed with
+// 401 Unauthorized should refresh the session and be replayed.
+func (s *Session) canRefresh(ctx context.Context) bool {
+	if s == nil || ctx.Value(noRefreshKey{}) != nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.refresh != nil && s.RefreshToken != ""
+}
@@ -25,10 +25,10 @@ import (
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.Replica]
 }
 
 // Replica returns the most recent Replica visited by a call to Next.
 func (i *Iter) Replica() *trimmer.Replica {
-	return i.Current().(*trimmer.Replica)
+	return i.Item()
 }
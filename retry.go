@@ -0,0 +1,174 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	crand "crypto/rand"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// IdempotencyKeyHeader is the HTTP header that marks a POST or PATCH request
+// as safe to replay. Requests without this header are only retried when the
+// server has rejected them with 429 Too Many Requests.
+//
+// The SDK adds a key to all POST, PATCH and DELETE requests. Use
+// CallHeaders.IdempotencyKey or ContextWithIdempotencyKey to supply your own,
+// e.g. to safely repeat a call after a process restart.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type idempotencyKey struct{}
+
+// ContextWithIdempotencyKey returns a context that makes the SDK send key as
+// idempotency key with mutating calls. All calls made with the context share
+// the key, so use it for a single call only. An empty key disables keys for
+// calls made with the context, these calls are not retried.
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the idempotency key stored in ctx, if any.
+func IdempotencyKeyFromContext(ctx context.Context) string {
+	if ctx != nil {
+		if key, ok := ctx.Value(idempotencyKey{}).(string); ok {
+			return key
+		}
+	}
+	return ""
+}
+
+// NewIdempotencyKey returns a random idempotency key.
+func NewIdempotencyKey() string {
+	var b [16]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		// fall back to the weaker math/rand source
+		rand.Read(b[:])
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // UUID version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// setIdempotencyKey adds an idempotency key to mutating requests that do not
+// have one yet.
+func setIdempotencyKey(ctx context.Context, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost, http.MethodPatch, http.MethodDelete:
+	default:
+		return
+	}
+	if req.Header.Get(IdempotencyKeyHeader) != "" {
+		return
+	}
+	key, ok := ctx.Value(idempotencyKey{}).(string)
+	if ok && key == "" {
+		return
+	}
+	if key == "" {
+		key = NewIdempotencyKey()
+	}
+	req.Header.Set(IdempotencyKeyHeader, key)
+}
+
+// RetryPolicy controls how a backend retries calls that failed with transient
+// errors like network errors, connection resets or 5xx server responses.
+type RetryPolicy struct {
+	MaxRetries int           // maximum number of retries per call, 0 disables retries
+	MinBackoff time.Duration // wait time before the second retry
+	MaxBackoff time.Duration // upper limit for the wait time between retries
+}
+
+// DefaultRetryPolicy is used by backends that have no explicit policy set.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: MaxRetries,
+	MinBackoff: RetryBackoffTime,
+	MaxBackoff: MaxRetryBackoffTime,
+}
+
+// Backoff returns the time to wait before sending retry number n (starting at 1).
+// The first retry is sent immediately, every subsequent retry waits twice as
+// long as the previous one until MaxBackoff is reached. Wait times are jittered
+// randomly between 50% and 100% so that many clients failing at the same time
+// do not retry in lock-step.
+func (p RetryPolicy) Backoff(n int) time.Duration {
+	if n <= 1 || p.MinBackoff <= 0 {
+		return 0
+	}
+	wait := p.MaxBackoff
+	if n-2 < 32 {
+		if d := p.MinBackoff << uint(n-2); d > 0 && (p.MaxBackoff <= 0 || d < p.MaxBackoff) {
+			wait = d
+		}
+	}
+	if wait <= 0 {
+		return 0
+	}
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(wait-half)+1))
+}
+
+// Delay returns the time to wait before retry n, which is the backoff or
+// retryAfter as requested by the server, whichever is longer.
+func (p RetryPolicy) Delay(n int, retryAfter time.Duration) time.Duration {
+	return max(p.Backoff(n), retryAfter)
+}
+
+// isIdempotent returns true when a request may be safely sent more than once.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// isReplayable returns true when a request's body, if any, can be recreated
+// for another attempt.
+func isReplayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// isTransientStatus returns true for server responses that are worth retrying.
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// SleepContext waits for d or until ctx is done, whichever happens first.
+func SleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,160 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newFlakyServer(failures int32, status int) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1"}`))
+	}))
+	return srv, &calls
+}
+
+func newTestBackend(url string) BackendConfiguration {
+	return BackendConfiguration{
+		Type:       APIBackend,
+		URL:        url,
+		HTTPClient: &http.Client{Timeout: time.Second},
+		Retry:      &RetryPolicy{MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond},
+	}
+}
+
+func TestRetryTransientStatus(t *testing.T) {
+	LogLevel = 0
+	srv, calls := newFlakyServer(2, http.StatusBadGateway)
+	defer srv.Close()
+
+	v := &struct{ ID string }{}
+	b := newTestBackend(srv.URL)
+	if err := b.Call(context.Background(), http.MethodPut, "/x", "key", nil, nil, map[string]string{"a": "b"}, v); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := atomic.LoadInt32(calls); n != 3 {
+		t.Errorf("expected 3 calls, got %d", n)
+	}
+	if v.ID != "1" {
+		t.Errorf("expected decoded response, got %q", v.ID)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	LogLevel = 0
+	srv, calls := newFlakyServer(10, http.StatusServiceUnavailable)
+	defer srv.Close()
+
+	b := newTestBackend(srv.URL)
+	err := b.Call(context.Background(), http.MethodGet, "/x", "key", nil, nil, nil, nil)
+	if e, ok := err.(TrimmerError); !ok || e.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 error, got %v", err)
+	}
+	if n := atomic.LoadInt32(calls); n != 4 {
+		t.Errorf("expected 4 calls, got %d", n)
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	LogLevel = 0
+	srv, calls := newFlakyServer(1, http.StatusBadGateway)
+	defer srv.Close()
+
+	b := newTestBackend(srv.URL)
+	ctx := ContextWithIdempotencyKey(context.Background(), "")
+	if err := b.Call(ctx, http.MethodPost, "/x", "key", nil, nil, nil, nil); err == nil {
+		t.Fatal("expected error on POST without idempotency key")
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("expected 1 call, got %d", n)
+	}
+}
+
+func TestRetryNoRetryOn4xx(t *testing.T) {
+	LogLevel = 0
+	srv, calls := newFlakyServer(1, http.StatusNotFound)
+	defer srv.Close()
+
+	b := newTestBackend(srv.URL)
+	if err := b.Call(context.Background(), http.MethodGet, "/x", "key", nil, nil, nil, nil); err == nil {
+		t.Fatal("expected 404 error")
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("expected 1 call, got %d", n)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 10, MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	if d := p.Backoff(1); d != 0 {
+		t.Errorf("first retry should be immediate, got %v", d)
+	}
+	for n, max := range map[int]time.Duration{2: time.Second, 3: 2 * time.Second, 4: 4 * time.Second, 8: 10 * time.Second, 100: 10 * time.Second} {
+		d := p.Backoff(n)
+		if d < max/2 || d > max {
+			t.Errorf("retry %d: backoff %v out of range [%v, %v]", n, d, max/2, max)
+		}
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		w.Header().Set("Content-Type", "application/json")
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write(NewApiError(http.StatusBadGateway).Marshal())
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	b := newTestBackend(srv.URL)
+
+	headers := &CallHeaders{}
+	if err := b.Call(context.Background(), http.MethodPost, "/assets", "key", nil, headers, struct{}{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] || headers.IdempotencyKey != keys[0] {
+		t.Fatalf("expected retry with same key, got %q (returned %q)", keys, headers.IdempotencyKey)
+	}
+
+	ctx := ContextWithIdempotencyKey(context.Background(), "my-key")
+	if err := b.Call(ctx, http.MethodPatch, "/assets/1", "key", nil, nil, struct{}{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Call(ctx, http.MethodGet, "/assets/1", "key", nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if keys[2] != "my-key" || keys[3] != "" {
+		t.Errorf("got keys %q, want my-key for PATCH and none for GET", keys[2:])
+	}
+}
@@ -0,0 +1,106 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"strings"
+)
+
+// StepUpFunc obtains a session that is granted scope in addition to the
+// current scopes and stores it with Session.Update, e.g. by logging in
+// again. See session.Client.StepUp.
+type StepUpFunc func(ctx context.Context, scope ApiAccessScope) error
+
+// noStepUpKey marks contexts of step-up logins.
+type noStepUpKey struct{}
+
+// ParseApiScopes splits a comma or space separated scope list as sent in
+// LoginParams.Scopes, Session.Scopes and the X-OAuth-Scopes header.
+func ParseApiScopes(s string) []ApiAccessScope {
+	f := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+	scopes := make([]ApiAccessScope, len(f))
+	for i, v := range f {
+		scopes[i] = ApiAccessScope(v)
+	}
+	return scopes
+}
+
+// GrantedScopes returns the scopes granted to the session. The list is
+// empty when the server has not reported them.
+func (s *Session) GrantedScopes() []ApiAccessScope {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return ParseApiScopes(s.Scopes)
+}
+
+// HasScope returns true when the session has been granted scope.
+func (s *Session) HasScope(scope ApiAccessScope) bool {
+	for _, v := range s.GrantedScopes() {
+		if v == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// OnStepUp installs a function that is called when a call requires a scope
+// the session has not been granted. Without it such calls fail with an
+// EForbidden usage error before they are sent. Pass nil to remove it.
+func (s *Session) OnStepUp(fn StepUpFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stepUp = fn
+}
+
+// setScopes updates the granted scopes from the X-OAuth-Scopes header of a
+// response to a request sent with authorization auth. Responses to
+// requests made with a previous token are ignored.
+func (s *Session) setScopes(auth, scopes string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.TokenType+" "+s.AccessToken == auth {
+		s.Scopes = scopes
+	}
+}
+
+// requireScope checks that the session may be used for calls that need
+// scope. Sessions without known scopes are not checked, the server has the
+// final word.
+func (s *Session) requireScope(ctx context.Context, scope ApiAccessScope) error {
+	if s == nil || scope == "" || !s.IsValid() {
+		return nil
+	}
+	granted := s.GrantedScopes()
+	if len(granted) == 0 || s.HasScope(scope) {
+		return nil
+	}
+	s.mu.RLock()
+	fn := s.stepUp
+	s.mu.RUnlock()
+	if fn == nil || ctx.Value(noStepUpKey{}) != nil {
+		return NewScopeError(scope, ApiScopes(granted...))
+	}
+	LoggerFromContext(ctx).Info("requesting additional scope", F("scope", string(scope)))
+	if err := fn(context.WithValue(ctx, noStepUpKey{}, true), scope); err != nil {
+		return err
+	}
+	if !s.HasScope(scope) {
+		return NewScopeError(scope, ApiScopes(s.GrantedScopes()...))
+	}
+	return nil
+}
@@ -20,10 +20,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
-	"net/http/httputil"
 	"os"
 	"strings"
 	"time"
@@ -51,16 +52,24 @@ const TotalBackends = 2
 // immediately, subsequent retries will wait
 const MaxRetries = 10
 
-// RetryBackoffTime defines the time instant that is added to the wait time before
-// a new retry attempt is made. The first retry is sent immediately, any subsequent
-// retry will wait an additional RetryBackoffTime longer.
+// RetryBackoffTime defines the wait time before the second retry attempt. The first
+// retry is sent immediately, any subsequent retry will wait twice as long as the
+// previous one (see RetryPolicy).
 const RetryBackoffTime = time.Duration(5) * time.Second
 
+// MaxRetryBackoffTime is the upper limit for the wait time between two retries.
+const MaxRetryBackoffTime = time.Duration(2) * time.Minute
+
 // DefaultPartSize defines the minimum size in bytes for upload parts (= 16 MiB).
 const DefaultPartSize = int64(16) << 20
 
+// DefaultPartConcurrency defines the number of multipart upload parts sent in
+// parallel.
+const DefaultPartConcurrency = 4
+
 // Backend is an interface for making calls against a Trimmer service.
-// This interface exists to enable mocking for tests if needed.
+// This interface exists to enable mocking for tests if needed, see Cassette
+// for recording and replaying real interactions.
 type Backend interface {
 	GetUrl() string
 	Call(ctx context.Context, method, path string, key ApiKey, sess *Session, headers *CallHeaders, data, v interface{}) error
@@ -73,6 +82,18 @@ type BackendConfiguration struct {
 	Type       SupportedBackend
 	URL        string
 	HTTPClient *http.Client
+	Retry      *RetryPolicy  // nil uses DefaultRetryPolicy
+	Limiter    *RateLimiter  // nil disables client-side rate limiting
+	Middleware []Middleware  // request/response interceptors, see Use
+	Logger     LeveledLogger // nil uses the package-level Log
+	UserAgent  string        // empty uses the package-level UserAgent
+
+	Instrumentation Instrumentation // nil disables metrics and tracing
+	Stall           *StallPolicy    // nil disables stall detection
+
+	Credentials CredentialProvider // resolves the API key when calls have none
+
+	err error // returned by all calls, e.g. a config file that failed to load
 }
 
 // SupportedBackend is an enumeration of supported Trimmer endpoints.
@@ -97,6 +118,11 @@ type CallHeaders struct {
 	Accept             string         // Accept
 	Size               int64          // Content-Length
 	Hashes             hash.HashBlock // Content-MD5, X-Trimmer-Hash
+	IdempotencyKey     string         // Idempotency-Key, generated for POST, PATCH and DELETE
+
+	// in only
+	Scope    ApiAccessScope // scope the call requires, checked against the session
+	External bool           // endpoint outside the API, called without API key
 
 	// out only
 	OAuthScopes string // X-OAuth-Scopes
@@ -112,9 +138,14 @@ const (
 	TRIMMER_CDN_SERVER_KEY = "TRIMMER_CDN_SERVER"
 )
 
+const (
+	defaultAPIURL = "https://api.trimmer.io"
+	defaultCDNURL = "https://cdn.trimmer.io"
+)
+
 var (
-	apiURL = "https://api.trimmer.io"
-	cdnURL = "https://cdn.trimmer.io"
+	apiURL = defaultAPIURL
+	cdnURL = defaultCDNURL
 )
 
 // user-settable API key
@@ -128,32 +159,46 @@ var UserAgent string
 // minimal size of upload parts
 var UploadPartSize = DefaultPartSize
 
+// number of upload parts sent in parallel, 1 uploads parts in sequence
+var UploadPartConcurrency = DefaultPartConcurrency
+
 // LogLevel is the logging level for this library.
-// 0: no logging
-// 1: errors only
-// 2: errors + informational (default)
-// 3: errors + informational + debug
-var LogLevel = 2
-
-// Logger controls how the SDK performs logging at a package level. It is useful
-// to customise if you need it prefixed for your application to meet other
-// requirements
+// 0: no logging (LogLevelNone)
+// 1: errors only (LogLevelError)
+// 2: errors + informational (LogLevelInfo, default)
+// 3: errors + informational + debug (LogLevelDebug)
+//
+// Request and response dumps are only produced at debug level, even when Log
+// is replaced by a custom LeveledLogger.
+var LogLevel = LogLevelInfo
+
+// Logger is the output of the default LeveledLogger. It is useful to customise
+// if you need it prefixed for your application to meet other requirements. To
+// send structured logs elsewhere replace Log instead.
 var Logger *log.Logger
 
 func init() {
 	// setup the logger
 	Logger = log.New(os.Stderr, "", log.LstdFlags)
 
-	// read API Key from environment
+	// apply the config file profile, environment variables take precedence;
+	// package-level calls fail when the profile cannot be loaded
+	p, err := LoadProfile("")
+	if err != nil {
+		Logger.Printf("trimmer: %v", err)
+		profileErr = err
+	}
+	applyProfile(p)
+}
+
+// applyEnv reads the API key and servers from the environment.
+func applyEnv() {
 	if s := os.Getenv(TRIMMER_API_KEY_KEY); s != "" {
 		Key = ApiKey(s)
 	}
-
-	// read API/CDN servers from environment
 	if s := os.Getenv(TRIMMER_API_SERVER_KEY); s != "" {
 		apiURL = s
 	}
-
 	if s := os.Getenv(TRIMMER_CDN_SERVER_KEY); s != "" {
 		cdnURL = s
 	}
@@ -161,6 +206,8 @@ func init() {
 
 var apiHttpClient = &http.Client{Timeout: defaultHTTPTimeout}
 var cdnHttpClient = &http.Client{}
+var apiRateLimiter = NewRateLimiter(0, 1)
+var cdnRateLimiter = NewRateLimiter(0, 1)
 var backends Backends
 
 // SetHTTPClient overrides the default HTTP client.
@@ -175,14 +222,27 @@ func SetHTTPClient(backend SupportedBackend, client *http.Client) {
 	}
 }
 
+// SetRateLimit limits the number of requests per second sent to a backend.
+// Bursts of up to burst requests are allowed. A rate of zero disables client-side
+// limiting, but the backend still pauses when the server responds with
+// 429 Too Many Requests.
+func SetRateLimit(backend SupportedBackend, rate float64, burst int) {
+	switch backend {
+	case APIBackend:
+		apiRateLimiter.SetRate(rate, burst)
+	case CDNBackend:
+		cdnRateLimiter.SetRate(rate, burst)
+	}
+}
+
 // NewBackends creates a new set of backends with the given HTTP client. You
 // should only need to use this for testing purposes or on App Engine.
 func NewBackends(httpClient *http.Client) *Backends {
 	return &Backends{
 		API: BackendConfiguration{
-			APIBackend, apiURL, apiHttpClient},
+			Type: APIBackend, URL: apiURL, HTTPClient: apiHttpClient, Limiter: apiRateLimiter, Middleware: apiMiddleware, Instrumentation: instrumentation, Stall: apiStallPolicy, Credentials: credentialProvider, err: profileErr},
 		CDN: BackendConfiguration{
-			CDNBackend, cdnURL, cdnHttpClient},
+			Type: CDNBackend, URL: cdnURL, HTTPClient: cdnHttpClient, Limiter: cdnRateLimiter, Middleware: cdnMiddleware, Instrumentation: instrumentation, Stall: cdnStallPolicy, Credentials: credentialProvider, err: profileErr},
 	}
 }
 
@@ -191,12 +251,12 @@ func GetBackend(backend SupportedBackend) Backend {
 	switch backend {
 	case APIBackend:
 		if backends.API == nil {
-			backends.API = BackendConfiguration{backend, apiURL, apiHttpClient}
+			backends.API = BackendConfiguration{Type: backend, URL: apiURL, HTTPClient: apiHttpClient, Limiter: apiRateLimiter, Middleware: apiMiddleware, Instrumentation: instrumentation, Stall: apiStallPolicy, Credentials: credentialProvider, err: profileErr}
 		}
 		return backends.API
 	case CDNBackend:
 		if backends.CDN == nil {
-			backends.CDN = BackendConfiguration{backend, cdnURL, cdnHttpClient}
+			backends.CDN = BackendConfiguration{Type: backend, URL: cdnURL, HTTPClient: cdnHttpClient, Limiter: cdnRateLimiter, Middleware: cdnMiddleware, Instrumentation: instrumentation, Stall: cdnStallPolicy, Credentials: credentialProvider, err: profileErr}
 		}
 		return backends.CDN
 	}
@@ -218,6 +278,19 @@ func (s BackendConfiguration) GetUrl() string {
 	return s.URL
 }
 
+// logger returns the logger from ctx, the backend's logger or the package logger.
+func (s *BackendConfiguration) logger(ctx context.Context) LeveledLogger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerKey{}).(LeveledLogger); ok && l != nil {
+			return l
+		}
+	}
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return Log
+}
+
 // Call is the Backend.Call implementation for invoking Trimmer APIs.
 func (s BackendConfiguration) Call(ctx context.Context, method, path string, key ApiKey, sess *Session, headers *CallHeaders, data, v interface{}) error {
 
@@ -243,7 +316,7 @@ func (s BackendConfiguration) Call(ctx context.Context, method, path string, key
 		headers.Accept = "application/json"
 	}
 
-	req, err := s.NewRequest(method, path, key, sess, headers, body)
+	req, err := s.newRequest(ctx, method, path, key, sess, headers, body)
 	if err != nil {
 		return err
 	}
@@ -272,7 +345,7 @@ func (s BackendConfiguration) CallMultipart(ctx context.Context, method, path st
 		headers.Accept = "application/json"
 	}
 
-	req, err := s.NewRequest(method, path, key, sess, headers, body)
+	req, err := s.newRequest(ctx, method, path, key, sess, headers, body)
 	if err != nil {
 		return err
 	}
@@ -344,11 +417,28 @@ func (s BackendConfiguration) CallChecksum(ctx context.Context, method, path str
 		}
 	}
 
-	req, err := s.NewRequest(method, path, key, sess, headers, requestBody)
+	req, err := s.newRequest(ctx, method, path, key, sess, headers, requestBody)
 	if err != nil {
 		return 0, hash.HashBlock{}, hash.HashBlock{}, err
 	}
 
+	// Allow Do() to replay uploads on transient errors when the source can be
+	// rewound. Outgoing data is hashed from scratch on every attempt.
+	if seeker, ok := r.(io.Seeker); ok {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+					return nil, err
+				}
+				if flags > 0 {
+					clientHash.Reset()
+					return ioutil.NopCloser(clientHash.NewReader(r, flags)), nil
+				}
+				return ioutil.NopCloser(r), nil
+			}
+		}
+	}
+
 	// reuse request headers as response header to return
 	responseHeaders := headers
 
@@ -369,8 +459,17 @@ func (s BackendConfiguration) CallChecksum(ctx context.Context, method, path str
 	return size, clientHash, serverHash, nil
 }
 
-// NewRequest is used by Call to generate an http.Request. It adds appropriate headers.
+// NewRequest is used by Call to generate an http.Request. It adds appropriate
+// headers. When key is empty the API key is resolved through the backend's
+// CredentialProvider.
 func (s *BackendConfiguration) NewRequest(method, path string, key ApiKey, sess *Session, headers *CallHeaders, body io.Reader) (*http.Request, error) {
+	return s.newRequest(context.Background(), method, path, key, sess, headers, body)
+}
+
+func (s *BackendConfiguration) newRequest(ctx context.Context, method, path string, key ApiKey, sess *Session, headers *CallHeaders, body io.Reader) (*http.Request, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
 
 	// build full API/CDN URL unless path already starts with http
 	if !strings.HasPrefix(path, "http") {
@@ -379,9 +478,7 @@ func (s *BackendConfiguration) NewRequest(method, path string, key ApiKey, sess
 
 	req, err := http.NewRequest(method, path, body)
 	if err != nil {
-		if LogLevel > 0 {
-			Logger.Printf("ERROR: cannot create request: %v\n", err)
-		}
+		s.logger(nil).Error("cannot create request", F("error", err))
 		return nil, NewUsageError("preparing request failed", err)
 	}
 
@@ -406,23 +503,44 @@ func (s *BackendConfiguration) NewRequest(method, path string, key ApiKey, sess
 		}
 	}
 
+	// replay protection for mutating requests
+	if headers.IdempotencyKey != "" {
+		req.Header.Add(IdempotencyKeyHeader, headers.IdempotencyKey)
+	}
+
 	// API Key
+	if headers.External {
+		key = ""
+	} else if key == "" && s.Credentials != nil {
+		c, err := resolveCredentials(ctx, s.Credentials, s.URL)
+		if err != nil {
+			s.logger(ctx).Error("cannot resolve credentials", F("error", err))
+			return nil, err
+		}
+		key = c.Key
+	}
 	if key != "" {
 		req.Header.Add("X-API-Key", string(key))
-	} else {
-		if LogLevel > 0 {
-			Logger.Println("ERROR: API Key missing")
-		}
+	} else if !headers.External {
+		s.logger(nil).Error("API Key missing")
 		return nil, NewUsageError("API Key missing", nil)
 	}
 
+	// fail fast when the session lacks the scope the call requires
+	if err := sess.requireScope(ctx, headers.Scope); err != nil {
+		s.logger(ctx).Error("scope missing", F("scope", string(headers.Scope)), F("error", err))
+		return nil, err
+	}
+
 	// Authorization header
 	if sess != nil && sess.IsValid() {
 		req.Header.Add("Authorization", sess.GetAuthorization())
 	}
 
 	req.Header.Add("X-API-Version", ApiVersion)
-	if UserAgent != "" {
+	if s.UserAgent != "" {
+		req.Header.Add("User-Agent", s.UserAgent)
+	} else if UserAgent != "" {
 		req.Header.Add("User-Agent", UserAgent)
 	} else {
 		req.Header.Add("User-Agent", "Trimmer-SDK-Go/"+ClientVersion)
@@ -437,20 +555,159 @@ func (s *BackendConfiguration) NewRequest(method, path string, key ApiKey, sess
 //
 // This function also handles binary responses like downloading data.
 //
+// Transient failures (network errors, connection resets and 5xx responses) are
+// retried according to the backend's RetryPolicy as long as the request is
+// idempotent and its body can be replayed. Requests rejected with 429 Too Many
+// Requests are retried regardless of their method after the time the server
+// asked for in Retry-After. Retries stop when ctx is done.
+//
+// POST, PATCH and DELETE requests are sent with an idempotency key that is
+// reused by all retries so the server can detect replays. Unless the request
+// already has one, the key is taken from ctx (see ContextWithIdempotencyKey)
+// or generated, and returned in responseHeaders.IdempotencyKey.
+//
+// Calls are reported to the backend's Instrumentation, if any.
 func (s *BackendConfiguration) Do(ctx context.Context, req *http.Request, sess *Session, v interface{}, responseHeaders *CallHeaders) (int64, hash.HashBlock, error) {
+	setIdempotencyKey(ctx, req)
+	if responseHeaders != nil {
+		responseHeaders.IdempotencyKey = req.Header.Get(IdempotencyKeyHeader)
+	}
 
-	if LogLevel > 1 {
-		q := req.URL.RawQuery
-		if len(q) > 0 {
-			Logger.Printf("%v %v%v?%v\n", req.Method, req.URL.Host, req.URL.Path, q)
-		} else {
-			Logger.Printf("%v %v%v\n", req.Method, req.URL.Host, req.URL.Path)
+	if s.Instrumentation == nil {
+		return s.retryAuth(ctx, req, sess, v, responseHeaders, nil)
+	}
+
+	call := CallInfo{
+		Backend: s.Type,
+		Method:  req.Method,
+		Route:   routeTemplate(s.Type, req.URL.Path),
+	}
+	ctx, span := s.Instrumentation.StartSpan(ctx, call)
+	span.SetAttribute(AttrBackend, string(call.Backend))
+	span.SetAttribute(AttrMethod, call.Method)
+	span.SetAttribute(AttrRoute, call.Route)
+
+	stats := &callStats{CallStats: CallStats{CallInfo: call}}
+	start := time.Now()
+	size, serverHash, err := s.retryAuth(ctx, req, sess, v, responseHeaders, stats)
+	stats.Duration = time.Since(start)
+	instrument(s.Instrumentation, span, stats, err)
+	return size, serverHash, err
+}
+
+// retryAuth runs retry and, when the call fails with 401 Unauthorized and the
+// session refreshes automatically, refreshes the session and replays the
+// request once. The session is reset when the refresh fails.
+func (s *BackendConfiguration) retryAuth(ctx context.Context, req *http.Request, sess *Session, v interface{}, responseHeaders *CallHeaders, stats *callStats) (int64, hash.HashBlock, error) {
+	size, serverHash, err := s.retry(ctx, req, sess, v, responseHeaders, stats)
+	if err == nil || !errors.Is(err, EUnauthorized) || !sess.canRefresh(ctx) {
+		return size, serverHash, err
+	}
+	// another goroutine may have refreshed the session in the meantime
+	if auth := sess.GetAuthorization(); auth == req.Header.Get("Authorization") || !sess.IsValid() {
+		if rerr := sess.Refresh(ctx); rerr != nil {
+			sess.Reset()
+			return size, serverHash, err
+		}
+	}
+	if !isReplayable(req) {
+		return size, serverHash, err
+	}
+	if req.GetBody != nil {
+		body, berr := req.GetBody()
+		if berr != nil {
+			return size, serverHash, err
+		}
+		req.Body = body
+	}
+	s.logger(ctx).Info("replaying request with refreshed session",
+		F("backend", s.Type),
+		F("method", req.Method),
+		F("url", req.URL.Host+req.URL.Path),
+	)
+	req.Header.Set("Authorization", sess.GetAuthorization())
+	return s.retry(ctx, req, sess, v, responseHeaders, stats)
+}
+
+// retry runs the retry loop for Do and collects call statistics into stats
+// when it is not nil.
+func (s *BackendConfiguration) retry(ctx context.Context, req *http.Request, sess *Session, v interface{}, responseHeaders *CallHeaders, stats *callStats) (int64, hash.HashBlock, error) {
+
+	policy := DefaultRetryPolicy
+	if s.Retry != nil {
+		policy = *s.Retry
+	}
+	canRetry := policy.MaxRetries > 0 && isReplayable(req)
+	idempotent := isIdempotent(req)
+
+	var retryAfter time.Duration
+	for retry := 0; ; retry++ {
+		if retry > 0 {
+			wait := policy.Delay(retry, retryAfter)
+			s.logger(ctx).Info("retrying request",
+				F("backend", s.Type),
+				F("method", req.Method),
+				F("url", req.URL.Host+req.URL.Path),
+				F("wait", wait),
+				F("retry", retry),
+				F("maxRetries", policy.MaxRetries),
+			)
+			if err := SleepContext(ctx, wait); err != nil {
+				return 0, hash.HashBlock{}, NewInternalError("request cancelled", err)
+			}
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return 0, hash.HashBlock{}, NewInternalError("rewinding request body failed", err)
+				}
+				req.Body = body
+			}
 		}
 
-		if LogLevel > 2 {
-			// only dump content-type application/json
-			r, _ := httputil.DumpRequestOut(req, req.Header.Get("Content-Type") == "application/json")
-			log.Println(string(r))
+		if stats != nil {
+			stats.Retries = retry
+		}
+		size, serverHash, transient, err := s.do(ctx, req, sess, v, responseHeaders, stats)
+		if err == nil || !transient || !canRetry || retry >= policy.MaxRetries || ctx.Err() != nil {
+			return size, serverHash, err
+		}
+
+		// rate-limited requests have not been processed by the server and
+		// are safe to replay even when they are not idempotent
+		e, _ := err.(TrimmerError)
+		if !idempotent && e.StatusCode != http.StatusTooManyRequests {
+			return size, serverHash, err
+		}
+		retryAfter = e.RetryAfter
+	}
+}
+
+// do executes a single attempt of an API request. It returns true when the
+// call failed with a transient error that is worth retrying.
+func (s *BackendConfiguration) do(ctx context.Context, req *http.Request, sess *Session, v interface{}, responseHeaders *CallHeaders, stats *callStats) (int64, hash.HashBlock, bool, error) {
+
+	// request-scoped logger
+	l := s.logger(ctx).With(
+		F("backend", s.Type),
+		F("method", req.Method),
+		F("url", req.URL.Host+req.URL.Path),
+	)
+
+	if q := req.URL.RawQuery; len(q) > 0 {
+		l.Info("request", F("query", q))
+	} else {
+		l.Info("request")
+	}
+
+	if LogLevel >= LogLevelDebug {
+		// only dump content-type application/json, credentials are redacted
+		l.Debug(dumpRequest(req, req.Header.Get("Content-Type") == "application/json"))
+	}
+
+	// shape traffic before the server has to push back
+	if s.Limiter != nil {
+		if err := s.Limiter.Wait(ctx); err != nil {
+			return 0, hash.HashBlock{}, false, NewInternalError("request cancelled", err)
 		}
 	}
 
@@ -468,24 +725,55 @@ func (s *BackendConfiguration) Do(ctx context.Context, req *http.Request, sess *
 	}
 	defer cancel()
 
+	// abort transfers that stop making progress
+	callCtx, wd := newWatchdog(callCtx, s.Stall)
+	defer wd.stop()
+
 	// wrap http request in context
 	req = req.WithContext(callCtx)
 
-	// calling the API, will fail on timeout
-	resp, err := s.HTTPClient.Do(req)
+	if wd != nil && req.Body != nil && req.Body != http.NoBody {
+		req.Body = wd.watch(req.Body, false)
+	}
+
+	if stats != nil && req.Body != nil && req.Body != http.NoBody {
+		req.Body = countingReader{req.Body, &stats.sent}
+	}
+
+	// calling the API through the middleware chain, will fail on timeout
+	resp, err := s.roundTrip()(req)
+
+	if err == nil && resp.Body == nil {
+		// middlewares may short-circuit calls with body-less responses
+		resp.Body = http.NoBody
+	}
 
 	if err != nil {
-		if LogLevel > 0 {
-			Logger.Println("ERROR: request failed:", err)
+		if stall := wd.stalled(callCtx); stall != nil {
+			err = stall
 		}
-		return 0, hash.HashBlock{}, NewInternalError("request failed", err)
+		l.Error("request failed", F("error", err), F("duration", time.Since(start)))
+		return 0, hash.HashBlock{}, ctx.Err() == nil, NewInternalError("request failed", err)
 	}
 	defer resp.Body.Close()
 
-	if LogLevel > 1 {
-		Logger.Printf("Completed in %v\n", time.Since(start))
+	if wd != nil {
+		resp.Body = wd.watch(resp.Body, true)
 	}
 
+	if stats != nil {
+		stats.Status = resp.StatusCode
+		stats.RequestId = resp.Header.Get("X-Request-Id")
+		resp.Body = countingReader{resp.Body, &stats.received}
+	}
+
+	l = l.With(
+		F("status", resp.StatusCode),
+		F("requestId", resp.Header.Get("X-Request-Id")),
+		F("sessionId", resp.Header.Get("X-Session-Id")),
+	)
+	l.Info("completed", F("duration", time.Since(start)))
+
 	// extract response headers
 	responseHeaders.ContentDisposition = resp.Header.Get("Content-Disposition")
 	responseHeaders.ContentType = resp.Header.Get("Content-Type")
@@ -496,6 +784,20 @@ func (s *BackendConfiguration) Do(ctx context.Context, req *http.Request, sess *
 	responseHeaders.Runtime = resp.Header.Get("X-Runtime")
 	responseHeaders.Size = resp.ContentLength
 
+	// track the scopes granted to the session
+	if scopes := responseHeaders.OAuthScopes; scopes != "" && sess != nil {
+		sess.setScopes(req.Header.Get("Authorization"), scopes)
+	}
+
+	// pause all requests on this backend when the server's quota is exhausted
+	retryAfter := parseRetryAfter(resp.Header, time.Now())
+	if reset := parseRateLimitReset(resp.Header, time.Now()); reset > retryAfter {
+		retryAfter = reset
+	}
+	if s.Limiter != nil {
+		s.Limiter.Pause(retryAfter)
+	}
+
 	isJsonResponse := strings.Contains(resp.Header.Get("Content-Type"), "application/json")
 	serverHash := hash.ParseString(resp.Header.Get("X-Trimmer-Hash"))
 	if serverHash.Md5 == "" {
@@ -506,43 +808,55 @@ func (s *BackendConfiguration) Do(ctx context.Context, req *http.Request, sess *
 	if resp.StatusCode >= 400 {
 
 		// API responses in JSON get handled here
-		if LogLevel > 2 {
-			s, _ := httputil.DumpResponse(resp, true)
-			log.Println(string(s))
+		if LogLevel >= LogLevelDebug {
+			l.Debug(dumpResponse(resp))
 		}
 
-		// clear session on 401
-		if resp.StatusCode == 401 && sess != nil {
+		// clear session on 401 unless it can be refreshed
+		if resp.StatusCode == 401 && sess != nil && !sess.canRefresh(ctx) {
 			sess.Reset()
 		}
 
 		// parse error
 		e := NewApiError(resp.StatusCode)
 		if isJsonResponse && req.Method != http.MethodHead && resp.ContentLength != 0 {
-			e = ParseApiError(resp.Body)
+			if pe := ParseApiError(resp.Body); pe.IsApi() {
+				e = pe
+			} else {
+				// keep the status when the body is not a valid error list
+				e.Err = pe
+			}
+		}
+		if e.StatusCode == 0 {
+			e.StatusCode = resp.StatusCode
 		}
 		e.RequestId = resp.Header.Get("X-Request-Id")
 		e.SessionId = resp.Header.Get("X-Session-Id")
 		e.OauthScopes = resp.Header.Get("X-OAuth-Scopes")
-		if LogLevel > 0 {
-			Logger.Println("ERROR:", e.Error())
+		e.RetryAfter = retryAfter
+		if e.isOAuthPending() {
+			l.Debug("authorization pending", F("error", e.Message))
+		} else {
+			l.Error(e.Error())
 		}
-		return resp.ContentLength, serverHash, e
+		return resp.ContentLength, serverHash, isTransientStatus(resp.StatusCode), e
 	}
 
 	// on success parse the response
 	if isJsonResponse {
 
 		// API responses in JSON get handled here
-		if LogLevel > 2 {
-			s, _ := httputil.DumpResponse(resp, true)
-			log.Println(string(s))
+		if LogLevel >= LogLevelDebug {
+			l.Debug(dumpResponse(resp))
 		}
 
 		if v != nil && (resp.ContentLength > 0 || resp.ContentLength == -1) {
 			jsonDecoder := json.NewDecoder(resp.Body)
 			if err := jsonDecoder.Decode(v); err != nil {
-				return resp.ContentLength, serverHash, NewInternalError("parsing response failed", err)
+				if stall := wd.stalled(callCtx); stall != nil {
+					return resp.ContentLength, serverHash, true, NewInternalError("parsing response failed", stall)
+				}
+				return resp.ContentLength, serverHash, false, NewInternalError("parsing response failed", err)
 			}
 		}
 
@@ -560,12 +874,16 @@ func (s *BackendConfiguration) Do(ctx context.Context, req *http.Request, sess *
 			}
 
 			if err != nil {
-				return size, serverHash, NewInternalError("copying response failed", err)
+				// data already passed to w cannot be taken back
+				if stall := wd.stalled(callCtx); stall != nil {
+					return size, serverHash, size == 0, NewInternalError("copying response failed", stall)
+				}
+				return size, serverHash, false, NewInternalError("copying response failed", err)
 			}
 
-			return size, serverHash, nil
+			return size, serverHash, false, nil
 		}
 	}
 
-	return resp.ContentLength, serverHash, nil
+	return resp.ContentLength, serverHash, false, nil
 }
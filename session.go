@@ -17,6 +17,7 @@
 package trimmer
 
 import (
+	"context"
 	"os"
 	"strings"
 	"sync"
@@ -84,6 +85,40 @@ func (p *LoginParams) Reset() {
 	p.Scopes = ""
 }
 
+// DeviceLoginParams configures the OAuth 2.0 device authorization flow
+// (RFC 8628) used by session.DeviceLogin on machines without a browser.
+type DeviceLoginParams struct {
+	ClientId string // OAuth client id, sent as client_id when set
+	Scopes   string // requested scopes, see ApiScopes
+
+	// Endpoints of the authorization server, relative to the API server
+	// unless they start with http. Empty values use /auth/device and
+	// /auth/token.
+	AuthorizationURL string
+	TokenURL         string
+
+	// Interval is the polling interval when the server sends none,
+	// 0 uses 5 seconds.
+	Interval time.Duration
+
+	// Prompt shows the verification URL and user code to the user. It
+	// returns immediately, polling starts afterwards. nil prints both to
+	// stderr.
+	Prompt func(ctx context.Context, a *DeviceAuthorization) error
+}
+
+// DeviceAuthorization is the authorization server's answer to a device
+// authorization request. The user enters UserCode at VerificationURI on
+// another device, or opens VerificationURIComplete which contains the code.
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`         // seconds
+	Interval                int    `json:"interval,omitempty"` // seconds between polls
+}
+
 // Global Login Session State
 type Session struct {
 	TokenId      string    `json:"tokenId"`
@@ -94,6 +129,15 @@ type Session struct {
 	ExpiresAt    time.Time `json:"expiresAt"`
 	User         *User     `json:"user"`
 	mu           sync.RWMutex
+
+	// automatic refresh, see AutoRefresh
+	refresh       RefreshFunc
+	refreshPolicy RefreshPolicy
+	inflight      *refreshCall
+	wake          chan struct{}
+
+	store  SessionStore // see UseStore
+	stepUp StepUpFunc   // see OnStepUp
 }
 
 func NewSession() *Session {
@@ -105,6 +149,16 @@ func NewClientSession(token ClientToken) (*Session, error) {
 	if token == "" {
 		token = ClientToken(os.Getenv(TRIMMER_CLIENT_TOKEN_KEY))
 	}
+	if p := activeProfile; token == "" && p != nil && p.Auth != AuthPassword {
+		token = p.ClientToken
+	}
+	if token == "" {
+		c, err := resolveCredentials(context.Background(), credentialProvider, apiURL)
+		if err != nil {
+			return nil, err
+		}
+		token = c.ClientToken
+	}
 	if token == "" {
 		return nil, EParamMissing
 	}
@@ -125,6 +179,12 @@ func (s *Session) Update(ss *Session) {
 	s.RefreshToken = ss.RefreshToken
 	s.ExpiresAt = ss.ExpiresAt
 	s.User = ss.User
+	if s.wake != nil {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
 }
 
 func (s *Session) GetAuthorization() string {
@@ -32,10 +32,19 @@ type Client struct {
 	Sess *trimmer.Session
 }
 
+// ParseEnv returns login parameters from the current config file profile,
+// overridden by the TRIMMER_API_USERNAME and TRIMMER_API_PASSWORD
+// environment variables.
 func ParseEnv() *trimmer.LoginParams {
 	p := &trimmer.LoginParams{
 		Scopes: trimmer.ApiScopes(trimmer.API_SCOPE_PUBLIC, trimmer.API_SCOPE_PRIVATE, trimmer.API_SCOPE_UPLOAD),
 	}
+	if prof, _ := trimmer.CurrentProfile(); prof != nil && prof.Auth != trimmer.AuthToken {
+		p.Username, p.Email, p.Password = prof.Username, prof.Email, prof.Password
+		if prof.Scopes != "" {
+			p.Scopes = prof.Scopes
+		}
+	}
 	if v := os.Getenv(trimmer.TRIMMER_USERNAME_KEY); v != "" {
 		p.Username = v
 	}
@@ -55,7 +64,6 @@ func (c Client) Check(ctx context.Context) error {
 	err := c.B.Call(ctx, http.MethodGet, "/users/me", c.Key, c.Sess, nil, nil, v)
 	if err == nil {
 		c.Sess.User = v
-		// TODO: extract auth scopes from header
 	}
 	return err
 }
@@ -64,52 +72,259 @@ func Refresh(ctx context.Context) error {
 	return getC().Refresh(ctx)
 }
 
+// Refresh obtains new tokens with the session's refresh token. When the
+// session has a store, the refresh runs under the store's lock and adopts a
+// session another process has refreshed in the meantime instead.
 func (c Client) Refresh(ctx context.Context) error {
+	st := c.Sess.Store()
+	if st == nil {
+		return c.refresh(ctx, c.Sess.GetRefreshToken())
+	}
+	unlock, err := st.Lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	token := c.Sess.GetRefreshToken()
+	if s := c.load(ctx, st); s != nil {
+		if s.GetAuthorization() != c.Sess.GetAuthorization() && isFresh(s) {
+			c.Sess.Update(s)
+			return nil
+		}
+		// the refresh token may have been rotated by another process
+		if s.RefreshToken != "" {
+			token = s.RefreshToken
+		}
+	}
+	if err := c.refresh(ctx, token); err != nil {
+		return err
+	}
+	return st.Save(c.Sess)
+}
+
+func (c Client) refresh(ctx context.Context, token string) error {
 	authRefresh := struct {
 		RefreshToken string `json:"refreshToken"`
 	}{
-		RefreshToken: c.Sess.GetRefreshToken(),
+		RefreshToken: token,
 	}
 
+	// like login, the call must not trigger an automatic refresh on 401
 	s := &trimmer.Session{}
-	err := c.B.Call(ctx, http.MethodPost, "/auth/refresh", c.Key, c.Sess, nil, authRefresh, s)
+	err := c.B.Call(ctx, http.MethodPost, "/auth/refresh", c.Key, nil, nil, authRefresh, s)
 	if err != nil {
 		return err
 	}
+	// servers that do not rotate refresh tokens omit them
+	if s.RefreshToken == "" {
+		s.RefreshToken = authRefresh.RefreshToken
+	}
 	c.Sess.Update(s)
 	return nil
 }
 
+// load returns the stored session or nil when the store is empty or
+// cannot be read.
+func (c Client) load(ctx context.Context, st trimmer.SessionStore) *trimmer.Session {
+	s, err := st.Load()
+	if err != nil {
+		trimmer.LoggerFromContext(ctx).Error("loading stored session failed", trimmer.F("error", err))
+		return nil
+	}
+	return s
+}
+
+// isFresh returns true for sessions that do not need a refresh yet.
+func isFresh(s *trimmer.Session) bool {
+	return s.IsValid() && (s.ExpiresAt.IsZero() || s.ValidFor() > trimmer.DefaultRefreshMargin)
+}
+
+// AutoRefresh refreshes the global LoginSession before it expires and when
+// calls fail with 401 Unauthorized until stop is called.
+func AutoRefresh(p trimmer.RefreshPolicy) (stop func()) {
+	return getC().AutoRefresh(p)
+}
+
+// AutoRefresh refreshes the client's session before it expires and when
+// calls fail with 401 Unauthorized until stop is called. See
+// trimmer.Session.AutoRefresh.
+func (c Client) AutoRefresh(p trimmer.RefreshPolicy) (stop func()) {
+	return c.Sess.AutoRefresh(c.Refresh, p)
+}
+
 func Login(ctx context.Context, params *trimmer.LoginParams) error {
 	return getC().Login(ctx, params)
 }
 
+// Login creates a new session. Missing username, email or password are
+// resolved through the backend's credential provider.
 func (c Client) Login(ctx context.Context, params *trimmer.LoginParams) error {
+	return c.storeLogin(ctx, func() error { return c.login(ctx, params) })
+}
+
+// storeLogin runs login under the lock of the session's store, if any, and
+// saves the new session.
+func (c Client) storeLogin(ctx context.Context, login func() error) error {
+	st := c.Sess.Store()
+	if st == nil {
+		return login()
+	}
+	unlock, err := st.Lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := login(); err != nil {
+		return err
+	}
+	return st.Save(c.Sess)
+}
+
+func (c Client) login(ctx context.Context, params *trimmer.LoginParams) error {
+	if params == nil {
+		params = ParseEnv()
+	}
+	if !params.IsValid() {
+		creds, err := trimmer.ResolveCredentials(ctx, c.B)
+		if err != nil {
+			return err
+		}
+		p := *params
+		if p.Username == "" && p.Email == "" {
+			p.Username, p.Email = creds.Username, creds.Email
+		}
+		if p.Password == "" {
+			p.Password = creds.Password
+		}
+		params = &p
+	}
 	s := &trimmer.Session{}
 	err := c.B.Call(ctx, http.MethodPost, "/auth/login", c.Key, nil, nil, params, s)
 	if err == nil {
 		c.Sess.Update(s)
 
-		if trimmer.LogLevel > 1 {
-			trimmer.Logger.Printf("INFO: Logged in as [%v] %v %v\n", c.Sess.User.ID, c.Sess.User.Name, c.Sess.User.DisplayName)
+		if u := c.Sess.User; u != nil {
+			trimmer.LoggerFromContext(ctx).Info("logged in",
+				trimmer.F("userId", u.ID),
+				trimmer.F("name", u.Name),
+				trimmer.F("displayName", u.DisplayName),
+			)
 		}
-
 	}
 	return err
 }
 
+func StepUp(params *trimmer.LoginParams) trimmer.StepUpFunc {
+	return getC().StepUp(params)
+}
+
+// StepUp returns a function for trimmer.Session.OnStepUp that logs in again
+// with params when a call requires a scope the session lacks. The new login
+// requests the scopes in params, the currently granted scopes and the
+// missing scope. A nil params uses ParseEnv.
+func (c Client) StepUp(params *trimmer.LoginParams) trimmer.StepUpFunc {
+	return func(ctx context.Context, scope trimmer.ApiAccessScope) error {
+		base := params
+		if base == nil {
+			base = ParseEnv()
+		}
+		p := *base
+		scopes := append(trimmer.ParseApiScopes(p.Scopes), c.Sess.GrantedScopes()...)
+		p.Scopes = trimmer.ApiScopes(uniqueScopes(append(scopes, scope))...)
+		return c.Login(ctx, &p)
+	}
+}
+
+// uniqueScopes removes duplicate scopes, keeping their order.
+func uniqueScopes(scopes []trimmer.ApiAccessScope) []trimmer.ApiAccessScope {
+	seen := make(map[trimmer.ApiAccessScope]bool, len(scopes))
+	u := scopes[:0]
+	for _, v := range scopes {
+		if !seen[v] {
+			seen[v] = true
+			u = append(u, v)
+		}
+	}
+	return u
+}
+
+func Resume(ctx context.Context, params *trimmer.LoginParams) error {
+	return getC().Resume(ctx, params)
+}
+
+// Resume continues the session saved in the session's store, refreshing it
+// when it is about to expire, and logs in with params only when there is no
+// usable stored session. The store stays locked until the new session is
+// saved, so parallel processes sharing the store reuse a single session.
+// Without a store Resume is the same as Login.
+func (c Client) Resume(ctx context.Context, params *trimmer.LoginParams) error {
+	return c.resume(ctx, func() error { return c.login(ctx, params) })
+}
+
+func ResumeDevice(ctx context.Context, params *trimmer.DeviceLoginParams) error {
+	return getC().ResumeDevice(ctx, params)
+}
+
+// ResumeDevice is like Resume, but starts a device login when there is no
+// usable stored session.
+func (c Client) ResumeDevice(ctx context.Context, params *trimmer.DeviceLoginParams) error {
+	return c.resume(ctx, func() error { return c.deviceLogin(ctx, params) })
+}
+
+// resume adopts or refreshes the stored session, or calls login, all under
+// the store's lock.
+func (c Client) resume(ctx context.Context, login func() error) error {
+	st := c.Sess.Store()
+	if st == nil {
+		return login()
+	}
+	unlock, err := st.Lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	s := c.load(ctx, st)
+	switch {
+	case s != nil && isFresh(s):
+		c.Sess.Update(s)
+		return nil
+	case s != nil && s.RefreshToken != "":
+		c.Sess.Update(s)
+		err := c.refresh(ctx, s.RefreshToken)
+		if err == nil {
+			return st.Save(c.Sess)
+		}
+		trimmer.LoggerFromContext(ctx).Info("stored session expired", trimmer.F("error", err))
+		c.Sess.Reset()
+	}
+	if err := login(); err != nil {
+		return err
+	}
+	return st.Save(c.Sess)
+}
+
 func Logout(ctx context.Context) error {
 	return getC().Logout(ctx)
 }
 
+// Logout ends the session and clears the session's store, even when the
+// server rejects the logout because the session has already expired.
 func (c Client) Logout(ctx context.Context) error {
 	err := c.B.Call(ctx, http.MethodPost, "/auth/logout", c.Key, c.Sess, nil, nil, nil)
 	if err == nil {
-		if trimmer.LogLevel > 1 {
-			trimmer.Logger.Printf("INFO: Logged out\n")
-		}
+		trimmer.LoggerFromContext(ctx).Info("logged out")
 		c.Sess.Reset()
 	}
+	if st := c.Sess.Store(); st != nil {
+		unlock, lerr := st.Lock(ctx)
+		if lerr != nil {
+			return lerr
+		}
+		defer unlock()
+		if cerr := st.Clear(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
 	return err
 }
 
@@ -0,0 +1,223 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	trimmer "trimmer.io/go-trimmer"
+)
+
+const (
+	// DeviceGrantType is the OAuth grant type of device code token requests.
+	DeviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+	defaultDeviceInterval = 5 * time.Second
+	slowDownInterval      = 5 * time.Second
+)
+
+// deviceToken is an OAuth 2.0 token response (RFC 6749 section 5.1).
+type deviceToken struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+func (t deviceToken) session() *trimmer.Session {
+	s := &trimmer.Session{
+		AccessToken:  t.AccessToken,
+		TokenType:    t.TokenType,
+		RefreshToken: t.RefreshToken,
+		Scopes:       t.Scope,
+	}
+	// token types are case insensitive
+	if s.TokenType == "" || strings.EqualFold(s.TokenType, "bearer") {
+		s.TokenType = "Bearer"
+	}
+	if t.ExpiresIn > 0 {
+		s.ExpiresAt = time.Now().UTC().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	return s
+}
+
+func DeviceLogin(ctx context.Context, params *trimmer.DeviceLoginParams) error {
+	return getC().DeviceLogin(ctx, params)
+}
+
+// DeviceLogin creates a new session with the OAuth 2.0 device authorization
+// flow. It requests a device code, shows the verification URL and user code
+// through params.Prompt and polls for tokens until the user approves the
+// login on another device, the code expires or ctx is done. The session
+// keeps the refresh token, so it works with Refresh and AutoRefresh, and is
+// saved to the session's store like after Login. A nil params requests the
+// scopes of ParseEnv.
+func (c Client) DeviceLogin(ctx context.Context, params *trimmer.DeviceLoginParams) error {
+	return c.storeLogin(ctx, func() error { return c.deviceLogin(ctx, params) })
+}
+
+func (c Client) deviceLogin(ctx context.Context, params *trimmer.DeviceLoginParams) error {
+	if params == nil {
+		params = &trimmer.DeviceLoginParams{Scopes: ParseEnv().Scopes}
+	}
+	p := *params
+	if p.AuthorizationURL == "" {
+		p.AuthorizationURL = "/auth/device"
+	}
+	if p.TokenURL == "" {
+		p.TokenURL = "/auth/token"
+	}
+	if p.Prompt == nil {
+		p.Prompt = promptDevice
+	}
+
+	form := url.Values{}
+	if p.ClientId != "" {
+		form.Set("client_id", p.ClientId)
+	}
+	if p.Scopes != "" {
+		form.Set("scope", p.Scopes)
+	}
+	a := &trimmer.DeviceAuthorization{}
+	if err := c.postForm(ctx, p.AuthorizationURL, form, a); err != nil {
+		return err
+	}
+	if a.DeviceCode == "" {
+		return trimmer.NewInternalError("device authorization response without device code", nil)
+	}
+	if err := p.Prompt(ctx, a); err != nil {
+		return err
+	}
+
+	s, err := c.pollDevice(ctx, &p, a)
+	if err != nil {
+		return err
+	}
+	c.Sess.Update(s)
+
+	// the token response does not contain the user
+	if err := c.Check(ctx); err != nil {
+		return err
+	}
+	if u := c.Sess.User; u != nil {
+		trimmer.LoggerFromContext(ctx).Info("logged in",
+			trimmer.F("userId", u.ID),
+			trimmer.F("name", u.Name),
+			trimmer.F("displayName", u.DisplayName),
+		)
+	}
+	return nil
+}
+
+// pollDevice requests tokens for an authorized device code until the user
+// has approved or denied the login.
+func (c Client) pollDevice(ctx context.Context, p *trimmer.DeviceLoginParams, a *trimmer.DeviceAuthorization) (*trimmer.Session, error) {
+	interval := p.Interval
+	if a.Interval > 0 {
+		interval = time.Duration(a.Interval) * time.Second
+	}
+	if interval <= 0 {
+		interval = defaultDeviceInterval
+	}
+	var expired <-chan time.Time
+	if a.ExpiresIn > 0 {
+		t := time.NewTimer(time.Duration(a.ExpiresIn) * time.Second)
+		defer t.Stop()
+		expired = t.C
+	}
+
+	form := url.Values{
+		"grant_type":  {DeviceGrantType},
+		"device_code": {a.DeviceCode},
+	}
+	if p.ClientId != "" {
+		form.Set("client_id", p.ClientId)
+	}
+	for {
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, trimmer.NewInternalError("device login cancelled", ctx.Err())
+		case <-expired:
+			t.Stop()
+			return nil, trimmer.NewUsageError("device code expired before the login was approved", nil)
+		case <-t.C:
+		}
+
+		tok := &deviceToken{}
+		err := c.postForm(ctx, p.TokenURL, form, tok)
+		if err == nil {
+			return tok.session(), nil
+		}
+		var e trimmer.TrimmerError
+		if !errors.As(err, &e) || !e.IsApi() {
+			return nil, err
+		}
+		switch e.Message {
+		case "authorization_pending":
+		case "slow_down":
+			interval += slowDownInterval
+		default:
+			// access_denied, expired_token and invalid requests
+			return nil, err
+		}
+	}
+}
+
+// postForm sends a form encoded request to an OAuth endpoint. Endpoints on
+// another host than the API receive neither the API key nor an idempotency
+// key.
+func (c Client) postForm(ctx context.Context, u string, form url.Values, v interface{}) error {
+	h := &trimmer.CallHeaders{
+		ContentType: "application/x-www-form-urlencoded",
+		External:    isExternal(u, c.B.GetUrl()),
+	}
+	if h.External {
+		ctx = trimmer.ContextWithIdempotencyKey(ctx, "")
+	}
+	return c.B.CallMultipart(ctx, http.MethodPost, u, c.Key, nil, h, strings.NewReader(form.Encode()), v)
+}
+
+// isExternal returns true when u is an absolute URL on another host than the
+// API at api.
+func isExternal(u, api string) bool {
+	eu, err := url.Parse(u)
+	if err != nil || !eu.IsAbs() {
+		return false
+	}
+	au, err := url.Parse(api)
+	return err != nil || !strings.EqualFold(eu.Host, au.Host)
+}
+
+// promptDevice asks the user to approve the login on another device.
+func promptDevice(_ context.Context, a *trimmer.DeviceAuthorization) error {
+	if a.VerificationURIComplete != "" {
+		_, err := fmt.Fprintf(os.Stderr, "To log in, open %s\nor visit %s and enter the code %s\n", a.VerificationURIComplete, a.VerificationURI, a.UserCode)
+		return err
+	}
+	_, err := fmt.Fprintf(os.Stderr, "To log in, visit %s and enter the code %s\n", a.VerificationURI, a.UserCode)
+	return err
+}
@@ -0,0 +1,309 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SessionStore persists login sessions, so that processes can share a
+// session instead of logging in on every run. Attach a store to a session
+// with Session.UseStore. The session package then saves the session after
+// login and refresh, and clears the store on logout.
+//
+// Lock serializes logins and refreshes across processes. Stores that are
+// not shared between processes can return a no-op unlock function.
+type SessionStore interface {
+	Load() (*Session, error) // returns nil without error when the store is empty
+	Save(s *Session) error
+	Clear() error
+	Lock(ctx context.Context) (unlock func(), err error)
+}
+
+// DefaultStaleLock is the age after which session lock files are considered
+// abandoned by a crashed process.
+const DefaultStaleLock = time.Minute
+
+// UseStore attaches a store that the session package keeps up to date.
+func (s *Session) UseStore(st SessionStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = st
+}
+
+// Store returns the attached store or nil.
+func (s *Session) Store() SessionStore {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.store
+}
+
+// snapshot returns a copy of the session's tokens and user.
+func (s *Session) snapshot() *Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &Session{
+		TokenId:      s.TokenId,
+		AccessToken:  s.AccessToken,
+		TokenType:    s.TokenType,
+		Scopes:       s.Scopes,
+		RefreshToken: s.RefreshToken,
+		ExpiresAt:    s.ExpiresAt,
+		User:         s.User,
+	}
+}
+
+// DefaultSessionPath returns the session file of the current config file
+// profile in the user's config directory.
+func DefaultSessionPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	name := DefaultProfile
+	if p := activeProfile; p != nil {
+		name = p.Name
+	}
+	return filepath.Join(dir, "trimmer", "sessions", name)
+}
+
+// FileSessionStore keeps a session in a file encrypted with AES-256-GCM.
+// Unless Key is set, the encryption key is read from Path+".key", which is
+// created with random contents on first use. Like the session file it is
+// only accessible by its owner, so encryption protects sessions copied
+// without their key, e.g. in backups of the config directory.
+//
+// Lock creates Path+".lock" exclusively and waits while another process
+// holds it. The holder refreshes the lock file's modification time, lock
+// files older than StaleLock are removed. Unlock removes the lock file only
+// while it is still the holder's own.
+type FileSessionStore struct {
+	Path      string
+	Key       []byte        // 32 bytes, nil uses the key file
+	StaleLock time.Duration // 0 uses DefaultStaleLock
+}
+
+// NewFileSessionStore creates a store for the session file at path.
+func NewFileSessionStore(path string) *FileSessionStore {
+	return &FileSessionStore{Path: path}
+}
+
+func (f *FileSessionStore) Load() (*Session, error) {
+	b, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	aead, err := f.aead()
+	if err != nil {
+		return nil, err
+	}
+	n := aead.NonceSize()
+	if len(b) < n {
+		return nil, NewUsageError("session file "+f.Path+" is truncated", nil)
+	}
+	plain, err := aead.Open(nil, b[:n], b[n:], []byte(f.Path))
+	if err != nil {
+		return nil, NewUsageError("decrypting session file "+f.Path+" failed", err)
+	}
+	s := &Session{}
+	if err := json.Unmarshal(plain, s); err != nil {
+		return nil, NewUsageError("parsing session file "+f.Path+" failed", err)
+	}
+	return s, nil
+}
+
+func (f *FileSessionStore) Save(s *Session) error {
+	plain, err := json.Marshal(s.snapshot())
+	if err != nil {
+		return err
+	}
+	aead, err := f.aead()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return NewInternalError("generating nonce failed", err)
+	}
+	return writeFileAtomic(f.Path, aead.Seal(nonce, nonce, plain, []byte(f.Path)))
+}
+
+func (f *FileSessionStore) Clear() error {
+	if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f *FileSessionStore) Lock(ctx context.Context) (func(), error) {
+	lock := f.Path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lock), 0700); err != nil {
+		return nil, err
+	}
+	stale := f.StaleLock
+	if stale <= 0 {
+		stale = DefaultStaleLock
+	}
+	for {
+		fd, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			token := strconv.Itoa(os.Getpid()) + " " + NewIdempotencyKey() + "\n"
+			_, err = fd.WriteString(token)
+			if cerr := fd.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(lock)
+				return nil, err
+			}
+			return holdLock(lock, token, stale), nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, err := os.Stat(lock); err == nil && time.Since(fi.ModTime()) > stale {
+			os.Remove(lock)
+			continue
+		}
+		if err := SleepContext(ctx, 20*time.Millisecond); err != nil {
+			return nil, NewInternalError("waiting for session lock cancelled", err)
+		}
+	}
+}
+
+// holdLock refreshes the modification time of lock file lock while it holds
+// token, so other processes do not take it for stale during a slow login.
+// The returned unlock function removes the file only while it holds token.
+func holdLock(lock, token string, stale time.Duration) func() {
+	owned := func() bool {
+		b, err := ioutil.ReadFile(lock)
+		return err == nil && string(b) == token
+	}
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(stale / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-t.C:
+				if owned() {
+					os.Chtimes(lock, now, now)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			if owned() {
+				os.Remove(lock)
+			}
+		})
+	}
+}
+
+// aead returns the cipher for the store's key.
+func (f *FileSessionStore) aead() (cipher.AEAD, error) {
+	key := f.Key
+	if key == nil {
+		var err error
+		if key, err = loadOrCreateKey(f.Path + ".key"); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, NewUsageError("invalid session key", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// loadOrCreateKey reads a 32 byte key file or creates it.
+func loadOrCreateKey(path string) ([]byte, error) {
+	key, err := ioutil.ReadFile(path)
+	if err == nil {
+		if fi, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && fi.Mode().Perm()&0077 != 0 {
+			return nil, NewUsageError("session key "+path+" is accessible by other users, use chmod 600", nil)
+		}
+		if len(key) != 32 {
+			return nil, NewUsageError("session key "+path+" is invalid", nil)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key = make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, NewInternalError("generating session key failed", err)
+	}
+	// link the complete file into place, another process may have won
+	// the race to create the key
+	err = writeFile(path, key, func(tmp, path string) error { return os.Link(tmp, path) })
+	if os.IsExist(err) {
+		return loadOrCreateKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// writeFileAtomic replaces a file with data readable only by its owner.
+func writeFileAtomic(path string, data []byte) error {
+	return writeFile(path, data, os.Rename)
+}
+
+// writeFile writes data to a temporary file readable only by its owner and
+// moves it to path with move.
+func writeFile(path string, data []byte, move func(tmp, path string) error) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return move(tmp.Name(), path)
+}
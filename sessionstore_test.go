@@ -0,0 +1,146 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSessionStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions", "default")
+	st := NewFileSessionStore(path)
+	if s, err := st.Load(); s != nil || err != nil {
+		t.Fatalf("expected empty store, got %v, %v", s, err)
+	}
+
+	sess := &Session{
+		AccessToken:  "access-secret",
+		TokenType:    "Bearer",
+		RefreshToken: "refresh-secret",
+		Scopes:       "public",
+		ExpiresAt:    time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+		User:         &User{ID: "1"},
+	}
+	if err := st.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("secret")) {
+		t.Error("session file is not encrypted")
+	}
+	for _, p := range []string{path, path + ".key"} {
+		if fi, err := os.Stat(p); err != nil || fi.Mode().Perm() != 0600 {
+			t.Errorf("%s: expected mode 0600, got %v", p, fi.Mode())
+		}
+	}
+
+	s, err := NewFileSessionStore(path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.AccessToken != sess.AccessToken || s.RefreshToken != sess.RefreshToken || !s.ExpiresAt.Equal(sess.ExpiresAt) || s.User.ID != "1" {
+		t.Errorf("loaded session differs: %+v", s)
+	}
+
+	other := &FileSessionStore{Path: path, Key: bytes.Repeat([]byte{1}, 32)}
+	if _, err := other.Load(); err == nil {
+		t.Error("expected error with wrong key")
+	}
+
+	if err := st.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := st.Load(); s != nil || err != nil {
+		t.Errorf("expected empty store after clear, got %v, %v", s, err)
+	}
+}
+
+func TestFileSessionStoreLock(t *testing.T) {
+	st := NewFileSessionStore(filepath.Join(t.TempDir(), "default"))
+	unlock, err := st.Lock(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := st.Lock(ctx); err == nil {
+		t.Fatal("expected second lock to wait")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		unlock2, err := st.Lock(context.Background())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		unlock2()
+	}()
+	time.Sleep(30 * time.Millisecond)
+	unlock()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lock not acquired after unlock")
+	}
+
+	// held locks stay fresh
+	st.StaleLock = 30 * time.Millisecond
+	unlock, err = st.Lock(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := st.Lock(ctx); err == nil {
+		t.Fatal("expected held lock not to become stale")
+	}
+	unlock()
+
+	// locks of crashed processes expire
+	lock := st.Path + ".lock"
+	if err := ioutil.WriteFile(lock, []byte("1 crashed\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Second)
+	os.Chtimes(lock, old, old)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	unlock, err = st.Lock(ctx)
+	if err != nil {
+		t.Fatalf("expected stale lock to be removed, got %v", err)
+	}
+
+	// unlocking leaves a lock taken over by another process alone
+	if err := ioutil.WriteFile(lock, []byte("2 other\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+	if b, err := ioutil.ReadFile(lock); err != nil || string(b) != "2 other\n" {
+		t.Errorf("expected lock of other process to be kept, got %q, %v", b, err)
+	}
+}
@@ -0,0 +1,280 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// StallPolicy aborts transfers that stop making progress. CDN transfers can
+// take hours, so the CDN HTTP client has no overall timeout. Instead, a
+// watchdog checks request and response bodies while they are transferred and
+// aborts the attempt with a StallError when data stops moving. Time the
+// server spends processing a request before it responds is not counted.
+//
+// Stalled attempts are retried like network errors when the request body can
+// be replayed and no response data has been passed to the caller yet.
+type StallPolicy struct {
+	IdleTimeout   time.Duration // abort when no bytes move for this long, 0 disables
+	MinThroughput int64         // abort when fewer bytes per second move, 0 disables
+	Window        time.Duration // throughput measurement window, 0 uses DefaultThroughputWindow
+}
+
+// DefaultThroughputWindow is the default window for measuring throughput.
+const DefaultThroughputWindow = 30 * time.Second
+
+// DefaultStallPolicy is used for the CDN backend unless changed with
+// SetStallPolicy or WithStallPolicy.
+var DefaultStallPolicy = StallPolicy{
+	IdleTimeout: time.Minute,
+}
+
+var (
+	apiStallPolicy *StallPolicy
+	cdnStallPolicy = &DefaultStallPolicy
+)
+
+// SetStallPolicy sets the stall policy of a package-level backend. Use nil
+// to disable stall detection.
+func SetStallPolicy(backend SupportedBackend, p *StallPolicy) {
+	switch backend {
+	case APIBackend:
+		apiStallPolicy = p
+		if b, ok := backends.API.(BackendConfiguration); ok {
+			b.Stall = p
+			backends.API = b
+		}
+	case CDNBackend:
+		cdnStallPolicy = p
+		if b, ok := backends.CDN.(BackendConfiguration); ok {
+			b.Stall = p
+			backends.CDN = b
+		}
+	}
+}
+
+// StallError is the cause of errors returned for aborted transfers.
+type StallError struct {
+	Idle          time.Duration // time without progress, set when IdleTimeout was exceeded
+	Throughput    int64         // measured bytes per second, set when below MinThroughput
+	MinThroughput int64
+	Bytes         int64 // body bytes transferred by the attempt
+}
+
+func (e *StallError) Error() string {
+	if e.Idle > 0 {
+		return fmt.Sprintf("transfer stalled: no progress for %s after %d bytes", e.Idle, e.Bytes)
+	}
+	return fmt.Sprintf("transfer too slow: %d bytes/s below minimum of %d bytes/s after %d bytes", e.Throughput, e.MinThroughput, e.Bytes)
+}
+
+// Timeout and Temporary make StallError a net.Error.
+func (e *StallError) Timeout() bool   { return true }
+func (e *StallError) Temporary() bool { return true }
+
+// IsStalled returns true when err was caused by an aborted transfer.
+func IsStalled(err error) bool {
+	_, ok := stallCause(err)
+	return ok
+}
+
+func stallCause(err error) (*StallError, bool) {
+	var s *StallError
+	ok := errors.As(err, &s)
+	return s, ok
+}
+
+// watchdog monitors the bodies of a single attempt and cancels its context
+// when the transfer stalls.
+type watchdog struct {
+	policy StallPolicy
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+
+	mu          sync.Mutex
+	active      int // bodies currently transferring
+	last        time.Time
+	bytes       int64
+	windowStart time.Time
+	windowBytes int64
+}
+
+// newWatchdog returns a context for the attempt and a watchdog that is
+// stopped with stop. It returns a nil watchdog when the policy is disabled.
+func newWatchdog(ctx context.Context, p *StallPolicy) (context.Context, *watchdog) {
+	if p == nil || (p.IdleTimeout <= 0 && p.MinThroughput <= 0) {
+		return ctx, nil
+	}
+	w := &watchdog{policy: *p, done: make(chan struct{})}
+	if w.policy.Window <= 0 {
+		w.policy.Window = DefaultThroughputWindow
+	}
+	ctx, w.cancel = context.WithCancelCause(ctx)
+	go w.run()
+	return ctx, w
+}
+
+func (w *watchdog) interval() time.Duration {
+	d := w.policy.Window
+	if w.policy.IdleTimeout > 0 && (w.policy.MinThroughput <= 0 || w.policy.IdleTimeout < d) {
+		d = w.policy.IdleTimeout
+	}
+	if d /= 4; d < time.Millisecond {
+		d = time.Millisecond
+	}
+	return d
+}
+
+func (w *watchdog) run() {
+	t := time.NewTicker(w.interval())
+	defer t.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case now := <-t.C:
+			if err := w.check(now); err != nil {
+				w.cancel(err)
+				return
+			}
+		}
+	}
+}
+
+// check returns an error when the transfer has stalled at time now.
+func (w *watchdog) check(now time.Time) *StallError {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.active == 0 {
+		return nil
+	}
+	if idle := now.Sub(w.last); w.policy.IdleTimeout > 0 && idle >= w.policy.IdleTimeout {
+		return &StallError{Idle: idle, Bytes: w.bytes}
+	}
+	if elapsed := now.Sub(w.windowStart); w.policy.MinThroughput > 0 && elapsed >= w.policy.Window {
+		rate := int64(float64(w.windowBytes) / elapsed.Seconds())
+		if rate < w.policy.MinThroughput {
+			return &StallError{Throughput: rate, MinThroughput: w.policy.MinThroughput, Bytes: w.bytes}
+		}
+		w.windowStart, w.windowBytes = now, 0
+	}
+	return nil
+}
+
+// stop ends monitoring.
+func (w *watchdog) stop() {
+	if w != nil {
+		close(w.done)
+		w.cancel(nil)
+	}
+}
+
+// stalled returns the error when the watchdog has aborted the attempt
+// running with ctx.
+func (w *watchdog) stalled(ctx context.Context) *StallError {
+	if w == nil {
+		return nil
+	}
+	err, _ := context.Cause(ctx).(*StallError)
+	return err
+}
+
+// start marks the beginning of a body transfer.
+func (w *watchdog) start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.active == 0 {
+		// time between transfers belongs to the server
+		now := time.Now()
+		w.last, w.windowStart, w.windowBytes = now, now, 0
+	}
+	w.active++
+}
+
+// finish marks the end of a body transfer.
+func (w *watchdog) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.active--
+}
+
+func (w *watchdog) progress(n int) {
+	if n <= 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.last = time.Now()
+	w.bytes += int64(n)
+	w.windowBytes += int64(n)
+}
+
+// watch wraps a body so that reads count as progress. Request bodies become
+// active on their first read, response bodies immediately.
+func (w *watchdog) watch(rc io.ReadCloser, active bool) io.ReadCloser {
+	b := &watchedBody{ReadCloser: rc, w: w}
+	if active {
+		b.begin()
+	}
+	return b
+}
+
+type watchedBody struct {
+	io.ReadCloser
+	w       *watchdog
+	started bool
+	ended   bool
+	mu      sync.Mutex
+}
+
+func (b *watchedBody) begin() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.started {
+		b.started = true
+		b.w.start()
+	}
+}
+
+func (b *watchedBody) end() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.started && !b.ended {
+		b.ended = true
+		b.w.finish()
+	}
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	b.begin()
+	n, err := b.ReadCloser.Read(p)
+	b.w.progress(n)
+	if err != nil {
+		b.end()
+	}
+	return n, err
+}
+
+func (b *watchedBody) Close() error {
+	b.end()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,121 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newStallingServer sends response headers and the first n bytes of the body,
+// then stops sending data on the first stalls calls.
+func newStallingServer(stalls int32, n int) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		call := atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Length", "10")
+		w.WriteHeader(http.StatusOK)
+		if call <= stalls {
+			w.Write(make([]byte, n))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		w.Write(make([]byte, 10))
+	}))
+	return srv, &calls
+}
+
+func TestStallRetry(t *testing.T) {
+	LogLevel = 0
+	srv, calls := newStallingServer(1, 0)
+	defer srv.Close()
+
+	b := newTestBackend(srv.URL)
+	b.Stall = &StallPolicy{IdleTimeout: 50 * time.Millisecond}
+	var buf bytes.Buffer
+	size, _, _, err := b.CallChecksum(context.Background(), http.MethodGet, "/file", "key", nil, nil, 0, nil, &buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 10 || atomic.LoadInt32(calls) != 2 {
+		t.Errorf("got %d bytes in %d calls, want 10 bytes in 2 calls", size, atomic.LoadInt32(calls))
+	}
+}
+
+func TestStallPartialDownload(t *testing.T) {
+	LogLevel = 0
+	srv, calls := newStallingServer(1, 5)
+	defer srv.Close()
+
+	b := newTestBackend(srv.URL)
+	b.Stall = &StallPolicy{IdleTimeout: 50 * time.Millisecond}
+	var buf bytes.Buffer
+	_, _, _, err := b.CallChecksum(context.Background(), http.MethodGet, "/file", "key", nil, nil, 0, nil, &buf, nil)
+	if !IsStalled(err) {
+		t.Fatalf("expected stall error, got %v", err)
+	}
+	if ClassifyError(err) != ErrorClassTimeout {
+		t.Errorf("got error class %q, want timeout", ClassifyError(err))
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("partial download must not be retried, got %d calls", n)
+	}
+}
+
+// slowReader returns one byte per delay.
+type slowReader struct {
+	n     int
+	delay time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	r.n--
+	p[0] = 'x'
+	return 1, nil
+}
+
+func TestStallMinThroughput(t *testing.T) {
+	LogLevel = 0
+	srv, _ := newStallingServer(0, 0)
+	defer srv.Close()
+
+	b := newTestBackend(srv.URL)
+	b.Retry = &RetryPolicy{}
+	b.Stall = &StallPolicy{MinThroughput: 1000, Window: 50 * time.Millisecond}
+	body := &slowReader{n: 100, delay: 10 * time.Millisecond}
+	_, _, _, err := b.CallChecksum(context.Background(), http.MethodPut, "/file", "key", nil, nil, 0, body, nil, nil)
+	e, ok := stallCause(err)
+	if !ok {
+		t.Fatalf("expected stall error, got %v", err)
+	}
+	if e.MinThroughput != 1000 || e.Throughput >= 1000 {
+		t.Errorf("unexpected stall error %v", e)
+	}
+}
@@ -42,12 +42,12 @@ func getC() Client {
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.Stash]
 }
 
 // Stash returns the most recent User visited by a call to Next.
 func (i *Iter) Stash() *trimmer.Stash {
-	return i.Current().(*trimmer.Stash)
+	return i.Item()
 }
 
 func Get(ctx context.Context, stashId string, params *trimmer.StashParams) (*trimmer.Stash, error) {
@@ -121,7 +121,7 @@ func (c Client) Update(ctx context.Context, stashId string, params *trimmer.Stas
 		return nil, trimmer.ENilPointer
 	}
 	v := &trimmer.Stash{}
-	err := c.B.Call(ctx, http.MethodPatch, fmt.Sprintf("/stashes/%v", stashId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPatch, fmt.Sprintf("/stashes/%v", stashId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -129,28 +129,28 @@ func (c Client) Delete(ctx context.Context, stashId string) error {
 	if stashId == "" {
 		return trimmer.EIDMissing
 	}
-	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/stashes/%v", stashId), c.Key, c.Sess, nil, nil, nil)
+	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/stashes/%v", stashId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, nil)
 }
 
 func (c Client) Watch(ctx context.Context, stashId string) error {
 	if stashId == "" {
 		return trimmer.EIDMissing
 	}
-	return c.B.Call(ctx, http.MethodPut, fmt.Sprintf("/stashes/%v/watch", stashId), c.Key, c.Sess, nil, nil, nil)
+	return c.B.Call(ctx, http.MethodPut, fmt.Sprintf("/stashes/%v/watch", stashId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, nil)
 }
 
 func (c Client) Unwatch(ctx context.Context, stashId string) error {
 	if stashId == "" {
 		return trimmer.EIDMissing
 	}
-	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/stashes/%v/watch", stashId), c.Key, c.Sess, nil, nil, nil)
+	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/stashes/%v/watch", stashId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, nil)
 }
 
 func (c Client) IsWatching(ctx context.Context, stashId string) (bool, error) {
 	if stashId == "" {
 		return false, trimmer.EIDMissing
 	}
-	err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/stashes/%v/watch", stashId), c.Key, c.Sess, nil, nil, nil)
+	err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/stashes/%v/watch", stashId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, nil)
 	switch e := err.(type) {
 	case trimmer.TrimmerError:
 		switch e.StatusCode {
@@ -168,7 +168,7 @@ func (c Client) IsWatching(ctx context.Context, stashId string) (bool, error) {
 
 func (c Client) ListLinks(ctx context.Context, stashId string, params *trimmer.LinkListParams) *link.Iter {
 	if stashId == "" {
-		return &link.Iter{trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &link.Iter{trimmer.GetIterErr[*trimmer.Link](trimmer.EIDMissing)}
 	}
 
 	type linkList struct {
@@ -204,17 +204,10 @@ func (c Client) ListLinks(ctx context.Context, stashId string, params *trimmer.L
 		lp = &params.ListParams
 	}
 
-	return &link.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &link.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Link, trimmer.ListMeta, error) {
 		list := &linkList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/stashes/%v/links?%v", stashId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -223,7 +216,7 @@ func (c Client) NewLink(ctx context.Context, stashId string, params *trimmer.Lin
 		return nil, trimmer.EIDMissing
 	}
 	v := &trimmer.Link{}
-	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/stashes/%v/links", stashId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, fmt.Sprintf("/stashes/%v/links", stashId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PUBLISH}, params, v)
 	return v, err
 }
 
@@ -250,7 +243,7 @@ func (c Client) UpdateLink(ctx context.Context, stashId, linkId string, params *
 		return nil, trimmer.ENilPointer
 	}
 	v := &trimmer.Link{}
-	err := c.B.Call(ctx, http.MethodPatch, fmt.Sprintf("/stashes/%v/links/%v", stashId, linkId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPatch, fmt.Sprintf("/stashes/%v/links/%v", stashId, linkId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PUBLISH}, params, v)
 	return v, err
 }
 
@@ -258,12 +251,12 @@ func (c Client) DeleteLink(ctx context.Context, stashId, linkId string) error {
 	if stashId == "" || linkId == "" {
 		return trimmer.EIDMissing
 	}
-	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/stashes/%v/links/%v", stashId, linkId), c.Key, c.Sess, nil, nil, nil)
+	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/stashes/%v/links/%v", stashId, linkId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PUBLISH}, nil, nil)
 }
 
 func (c Client) ClearLinks(ctx context.Context, stashId string) error {
 	if stashId == "" {
 		return trimmer.EIDMissing
 	}
-	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/stashes/%v/links", stashId), c.Key, c.Sess, nil, nil, nil)
+	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/stashes/%v/links", stashId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PUBLISH}, nil, nil)
 }
@@ -41,12 +41,12 @@ func getC() Client {
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.Tag]
 }
 
 // Tag returns the most recent Tag visited by a call to Next.
 func (i *Iter) Tag() *trimmer.Tag {
-	return i.Current().(*trimmer.Tag)
+	return i.Item()
 }
 
 func Get(ctx context.Context, tagId string, params *trimmer.TagParams) (*trimmer.Tag, error) {
@@ -93,7 +93,7 @@ func (c Client) Update(ctx context.Context, tagId string, params *trimmer.TagPar
 	}
 	v := &trimmer.Tag{}
 	u := fmt.Sprintf("/tags/%v", tagId)
-	err := c.B.Call(ctx, http.MethodPatch, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPatch, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -102,7 +102,7 @@ func (c Client) Delete(ctx context.Context, tagId string) error {
 		return trimmer.EIDMissing
 	}
 	u := fmt.Sprintf("/tags/%v", tagId)
-	return c.B.Call(ctx, http.MethodDelete, u, c.Key, c.Sess, nil, nil, nil)
+	return c.B.Call(ctx, http.MethodDelete, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, nil)
 }
 
 func (c Client) Reply(ctx context.Context, tagId string, params *trimmer.TagParams) (*trimmer.Tag, error) {
@@ -114,13 +114,13 @@ func (c Client) Reply(ctx context.Context, tagId string, params *trimmer.TagPara
 	}
 	v := &trimmer.Tag{}
 	u := fmt.Sprintf("/tags/%v/replies", tagId)
-	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, nil, nil, v)
+	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, v)
 	return v, err
 }
 
 func (c Client) ListReplies(ctx context.Context, tagId string, params *trimmer.TagListParams) *Iter {
 	if tagId == "" {
-		return &Iter{trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &Iter{trimmer.GetIterErr[*trimmer.Tag](trimmer.EIDMissing)}
 	}
 
 	type tagList struct {
@@ -139,16 +139,9 @@ func (c Client) ListReplies(ctx context.Context, tagId string, params *trimmer.T
 		lp = &params.ListParams
 	}
 
-	return &Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Tag, trimmer.ListMeta, error) {
 		list := &tagList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/tags/%v/replies?%v", tagId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
@@ -0,0 +1,238 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package telemetry provides metrics and tracing adapters for the SDK's
+// Instrumentation hook.
+//
+//	metrics := telemetry.NewPrometheus("trimmer")
+//	trimmer.SetInstrumentation(metrics)
+//	http.Handle("/metrics", metrics)
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	trimmer "trimmer.io/go-trimmer"
+)
+
+// DefaultBuckets are the latency histogram buckets in seconds.
+var DefaultBuckets = []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// Prometheus collects call metrics and exposes them in the Prometheus text
+// exposition format. It implements trimmer.Instrumentation and http.Handler.
+//
+// All metrics are labelled by backend, method and route template:
+//
+//	<ns>_requests_total                 calls, also labelled by code and error class
+//	<ns>_request_duration_seconds       call latency histogram including retries
+//	<ns>_request_sent_bytes_total       request body bytes
+//	<ns>_response_received_bytes_total  response body bytes
+//	<ns>_retries_total                  retried attempts
+type Prometheus struct {
+	Namespace string
+	Buckets   []float64
+
+	mu     sync.Mutex
+	calls  map[callKey]uint64
+	routes map[routeKey]*routeStats
+}
+
+type routeKey struct {
+	backend, method, route string
+}
+
+type callKey struct {
+	routeKey
+	code, class string
+}
+
+type routeStats struct {
+	buckets  []uint64 // not cumulative
+	count    uint64
+	sum      float64
+	sent     int64
+	received int64
+	retries  uint64
+}
+
+// NewPrometheus creates a collector that prefixes metric names with
+// namespace and uses DefaultBuckets.
+func NewPrometheus(namespace string) *Prometheus {
+	return &Prometheus{
+		Namespace: namespace,
+		Buckets:   DefaultBuckets,
+	}
+}
+
+// StartSpan implements trimmer.Instrumentation, the collector does not trace.
+func (p *Prometheus) StartSpan(ctx context.Context, call trimmer.CallInfo) (context.Context, trimmer.Span) {
+	return ctx, noopSpan{}
+}
+
+// ObserveCall implements trimmer.Instrumentation.
+func (p *Prometheus) ObserveCall(stats trimmer.CallStats) {
+	rk := routeKey{string(stats.Backend), stats.Method, stats.Route}
+	ck := callKey{rk, strconv.Itoa(stats.Status), string(stats.Error)}
+	d := stats.Duration.Seconds()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.calls == nil {
+		p.calls = make(map[callKey]uint64)
+		p.routes = make(map[routeKey]*routeStats)
+	}
+	p.calls[ck]++
+	r, ok := p.routes[rk]
+	if !ok {
+		r = &routeStats{buckets: make([]uint64, len(p.Buckets))}
+		p.routes[rk] = r
+	}
+	for i, b := range p.Buckets {
+		if d <= b {
+			r.buckets[i]++
+			break
+		}
+	}
+	r.count++
+	r.sum += d
+	r.sent += stats.BytesSent
+	r.received += stats.BytesReceived
+	r.retries += uint64(stats.Retries)
+}
+
+// ServeHTTP writes all metrics to w.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+// WriteTo writes all metrics to w in the Prometheus text format.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	p.mu.Lock()
+	p.write(&buf)
+	p.mu.Unlock()
+	return buf.WriteTo(w)
+}
+
+func (p *Prometheus) write(w io.Writer) {
+	calls := make([]callKey, 0, len(p.calls))
+	for k := range p.calls {
+		calls = append(calls, k)
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		a, b := calls[i], calls[j]
+		if a.routeKey != b.routeKey {
+			return a.routeKey.less(b.routeKey)
+		}
+		if a.code != b.code {
+			return a.code < b.code
+		}
+		return a.class < b.class
+	})
+	routes := make([]routeKey, 0, len(p.routes))
+	for k := range p.routes {
+		routes = append(routes, k)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].less(routes[j]) })
+
+	name := p.name("requests_total")
+	header(w, name, "counter", "Number of calls to Trimmer backends.")
+	for _, k := range calls {
+		fmt.Fprintf(w, "%s{%s,code=%s,error=%s} %d\n", name, k.labels(), quote(k.code), quote(k.class), p.calls[k])
+	}
+
+	name = p.name("request_duration_seconds")
+	header(w, name, "histogram", "Latency of calls to Trimmer backends including retries.")
+	for _, k := range routes {
+		r := p.routes[k]
+		var n uint64
+		for i, b := range p.Buckets {
+			n += r.buckets[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=%s} %d\n", name, k.labels(), quote(formatFloat(b)), n)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, k.labels(), r.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, k.labels(), formatFloat(r.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, k.labels(), r.count)
+	}
+
+	name = p.name("request_sent_bytes_total")
+	header(w, name, "counter", "Request body bytes sent to Trimmer backends.")
+	for _, k := range routes {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, k.labels(), p.routes[k].sent)
+	}
+
+	name = p.name("response_received_bytes_total")
+	header(w, name, "counter", "Response body bytes received from Trimmer backends.")
+	for _, k := range routes {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, k.labels(), p.routes[k].received)
+	}
+
+	name = p.name("retries_total")
+	header(w, name, "counter", "Number of retried call attempts.")
+	for _, k := range routes {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, k.labels(), p.routes[k].retries)
+	}
+}
+
+func (p *Prometheus) name(s string) string {
+	if p.Namespace == "" {
+		return s
+	}
+	return p.Namespace + "_" + s
+}
+
+func (k routeKey) less(o routeKey) bool {
+	if k.backend != o.backend {
+		return k.backend < o.backend
+	}
+	if k.route != o.route {
+		return k.route < o.route
+	}
+	return k.method < o.method
+}
+
+func (k routeKey) labels() string {
+	return fmt.Sprintf("backend=%s,method=%s,route=%s", quote(k.backend), quote(k.method), quote(k.route))
+}
+
+func header(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quote escapes a label value as required by the text format.
+func quote(s string) string {
+	return `"` + labelEscaper.Replace(s) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, interface{}) {}
+func (noopSpan) End(error)                        {}
@@ -0,0 +1,114 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package telemetry_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	trimmer "trimmer.io/go-trimmer"
+	"trimmer.io/go-trimmer/client"
+	"trimmer.io/go-trimmer/telemetry"
+	"trimmer.io/go-trimmer/trimmertest"
+)
+
+type span struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *span) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *span) End(err error)                              { s.err, s.ended = err, true }
+
+type recorder struct {
+	mu    sync.Mutex
+	spans []*span
+}
+
+func (r *recorder) Start(ctx context.Context, name string) (context.Context, trimmer.Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := &span{name: name, attrs: make(map[string]interface{})}
+	r.spans = append(r.spans, s)
+	return ctx, s
+}
+
+func TestInstrumentation(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+
+	metrics := telemetry.NewPrometheus("trimmer")
+	tracer := &recorder{}
+	opts := append(srv.Options(),
+		trimmer.WithRetryPolicy(trimmer.RetryPolicy{MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}),
+		trimmer.WithInstrumentation(trimmer.MultiInstrumentation(metrics, telemetry.NewTracing(tracer))),
+	)
+	api := client.New(opts...)
+	ctx := context.Background()
+	if err := api.Session.Login(ctx, srv.LoginParams()); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Inject(trimmertest.Fault{Method: http.MethodGet, Path: "/users/me", Times: 1, Status: http.StatusServiceUnavailable})
+	if _, err := api.Users.Me(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.Assets.Get(ctx, "0000000000000042", nil); err == nil {
+		t.Fatal("expected error for missing asset")
+	}
+
+	var buf bytes.Buffer
+	if _, err := metrics.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		`trimmer_requests_total{backend="api",method="POST",route="/auth/login",code="200",error=""} 1`,
+		`trimmer_requests_total{backend="api",method="GET",route="/users/me",code="200",error=""} 1`,
+		`trimmer_requests_total{backend="api",method="GET",route="/assets/{id}",code="404",error="client"} 1`,
+		`trimmer_retries_total{backend="api",method="GET",route="/users/me"} 1`,
+		`trimmer_request_duration_seconds_count{backend="api",method="GET",route="/users/me"} 1`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("missing metric %s", line)
+		}
+	}
+
+	if len(tracer.spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(tracer.spans))
+	}
+	s := tracer.spans[1]
+	if s.name != "GET /users/me" || !s.ended || s.err != nil {
+		t.Errorf("unexpected span %+v", s)
+	}
+	if id, _ := s.attrs[trimmer.AttrRequestId].(string); id == "" {
+		t.Error("span has no request id")
+	}
+	if s.attrs[trimmer.AttrRetries] != 1 || s.attrs[trimmer.AttrStatus] != http.StatusOK {
+		t.Errorf("unexpected span attributes %v", s.attrs)
+	}
+	if s := tracer.spans[2]; s.err == nil || s.attrs[trimmer.AttrErrorClass] != "client" {
+		t.Errorf("unexpected error span %+v", s)
+	}
+}
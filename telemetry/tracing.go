@@ -0,0 +1,69 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package telemetry
+
+import (
+	"context"
+
+	trimmer "trimmer.io/go-trimmer"
+)
+
+// Tracer starts spans. It is small enough to be implemented by a thin
+// wrapper around an OpenTelemetry tracer without this package depending on
+// the OpenTelemetry SDK:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, trimmer.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		return ctx, otelSpan{span}
+//	}
+//
+// where otelSpan maps SetAttribute to span.SetAttributes and End to
+// span.RecordError, span.SetStatus and span.End.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, trimmer.Span)
+}
+
+// TracerFunc adapts a function to the Tracer interface.
+type TracerFunc func(ctx context.Context, name string) (context.Context, trimmer.Span)
+
+func (f TracerFunc) Start(ctx context.Context, name string) (context.Context, trimmer.Span) {
+	return f(ctx, name)
+}
+
+// Tracing creates one client span per backend call. Spans are named
+// "<METHOD> <route>" following OpenTelemetry HTTP conventions and carry the
+// attributes listed in package trimmer, including the X-Request-Id of the
+// response as trimmer.request_id.
+type Tracing struct {
+	Tracer Tracer
+}
+
+// NewTracing creates a tracing instrumentation using t.
+func NewTracing(t Tracer) *Tracing {
+	return &Tracing{Tracer: t}
+}
+
+// StartSpan implements trimmer.Instrumentation.
+func (t *Tracing) StartSpan(ctx context.Context, call trimmer.CallInfo) (context.Context, trimmer.Span) {
+	return t.Tracer.Start(ctx, call.Method+" "+call.Route)
+}
+
+// ObserveCall implements trimmer.Instrumentation. Call statistics are set
+// as span attributes, so there is nothing left to do.
+func (t *Tracing) ObserveCall(stats trimmer.CallStats) {}
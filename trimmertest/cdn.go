@@ -0,0 +1,410 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmertest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	trimmer "trimmer.io/go-trimmer"
+	"trimmer.io/go-trimmer/hash"
+	"trimmer.io/go-trimmer/media"
+)
+
+// upload is a multipart upload in progress.
+type upload struct {
+	id       string
+	key      string
+	mimetype string
+	expires  time.Time
+	parts    map[int]*part
+}
+
+type part struct {
+	data []byte
+	info media.PartInfo
+}
+
+// file is a completely uploaded file stored on the volume.
+type file struct {
+	mimetype string
+	data     []byte
+	hashes   hash.HashBlock
+}
+
+// File returns the contents of a file stored on the volume. Media uploaded
+// through the SDK are stored under their media id.
+func (s *Server) File(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[key]
+	if !ok {
+		return nil, false
+	}
+	return f.data, true
+}
+
+// PutFile stores a file on the volume and returns its download URL.
+func (s *Server) PutFile(key, mimetype string, data []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[key] = &file{
+		mimetype: mimetype,
+		data:     data,
+		hashes:   s.sum(data, hash.HashBlock{}),
+	}
+	return s.fileURL(key)
+}
+
+func (s *Server) fileURL(key string) string {
+	return fmt.Sprintf("%s/%s/files/%s", s.CDN.URL, s.Manifest.UrlPrefix, key)
+}
+
+// serveCDN implements the volume protocol:
+//
+//	GET    /{prefix}/manifest.json        volume manifest
+//	PUT    /{prefix}/uploads?key=..       single upload
+//	POST   /{prefix}/uploads?key=..       init multipart upload
+//	PUT    /{prefix}/uploads/{id}?part=N  upload part
+//	GET    /{prefix}/uploads/{id}         list parts
+//	POST   /{prefix}/uploads/{id}         commit multipart upload
+//	DELETE /{prefix}/uploads/{id}         abort multipart upload
+//	GET    /{prefix}/files/{key}          download
+func (s *Server) serveCDN(w http.ResponseWriter, r *http.Request) {
+	if trimmer.ApiKey(r.Header.Get("X-API-Key")) != s.Key {
+		writeError(w, http.StatusUnauthorized, "invalid API key")
+		return
+	}
+	prefix := "/" + s.Manifest.UrlPrefix + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		writeError(w, http.StatusNotFound, "volume not found")
+		return
+	}
+	p := strings.TrimPrefix(r.URL.Path, prefix)
+
+	switch {
+	case p == "manifest.json" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.Manifest)
+	case p == "uploads" && r.Method == http.MethodPut:
+		s.putFile(w, r)
+	case p == "uploads" && r.Method == http.MethodPost:
+		s.initUpload(w, r)
+	case strings.HasPrefix(p, "uploads/"):
+		id := strings.TrimPrefix(p, "uploads/")
+		switch r.Method {
+		case http.MethodPut:
+			s.putPart(w, r, id)
+		case http.MethodGet:
+			s.listParts(w, r, id)
+		case http.MethodPost:
+			s.commitUpload(w, r, id)
+		case http.MethodDelete:
+			s.abortUpload(w, r, id)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	case strings.HasPrefix(p, "files/") && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		s.getFile(w, r, strings.TrimPrefix(p, "files/"))
+	default:
+		writeError(w, http.StatusNotFound, "route not found")
+	}
+}
+
+func (s *Server) putFile(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "missing key")
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkSize(r, int64(len(data))); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if int64(len(data)) > s.Manifest.Limits.SinglePartMax {
+		writeError(w, http.StatusRequestEntityTooLarge, "file too large for single upload")
+		return
+	}
+	declared := hash.ParseString(r.Header.Get("X-Trimmer-Hash"))
+	h := s.sum(data, declared)
+	if err := declared.Check(h, true); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.files[key] = &file{mimetype: r.Header.Get("Content-Type"), data: data, hashes: h}
+	s.mu.Unlock()
+
+	w.Header().Set("X-Trimmer-Hash", h.String())
+	writeJSON(w, http.StatusOK, &media.UploadInfo{
+		VolumeUUID:  s.Manifest.UUID,
+		Key:         key,
+		ContentType: r.Header.Get("Content-Type"),
+		State:       media.UploadStateComplete,
+		Hashes:      h,
+		Size:        int64(len(data)),
+	})
+}
+
+func (s *Server) initUpload(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "missing key")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// continue an unfinished upload of the same file
+	for id, u := range s.uploads {
+		if _, ok := s.upload(id); ok && u.key == key {
+			writeJSON(w, http.StatusOK, s.uploadInfo(u, media.UploadStateProgress))
+			return
+		}
+	}
+
+	u := &upload{
+		id:       randomHex(16),
+		key:      key,
+		mimetype: r.Header.Get("Content-Type"),
+		expires:  time.Now().UTC().Add(s.UploadTTL),
+		parts:    make(map[int]*part),
+	}
+	s.uploads[u.id] = u
+	writeJSON(w, http.StatusOK, s.uploadInfo(u, media.UploadStateIdle))
+}
+
+func (s *Server) putPart(w http.ResponseWriter, r *http.Request, id string) {
+	q := r.URL.Query()
+	num, err := strconv.Atoi(q.Get("part"))
+	if err != nil || num < 1 || int64(num) > s.Manifest.Limits.PartsMax {
+		writeError(w, http.StatusBadRequest, "invalid part number")
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if int64(len(data)) > s.Manifest.Limits.PartSizeMax {
+		writeError(w, http.StatusRequestEntityTooLarge, "part too large")
+		return
+	}
+	declared := hash.ParseString(r.Header.Get("X-Trimmer-Hash"))
+	h := s.sum(data, declared)
+	if err := declared.Check(h, true); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.upload(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "upload not found")
+		return
+	}
+	if _, exists := u.parts[num]; exists && q.Get("overwrite") != "true" {
+		writeError(w, http.StatusConflict, fmt.Sprintf("part %d already exists", num))
+		return
+	}
+	p := &part{
+		data: data,
+		info: media.PartInfo{
+			PartId:     num,
+			Hashes:     h,
+			Etag:       h.Etag(),
+			Size:       int64(len(data)),
+			State:      media.PartStateComplete,
+			UploadedAt: time.Now().UTC(),
+		},
+	}
+	u.parts[num] = p
+
+	i := s.uploadInfo(u, media.UploadStateProgress)
+	i.Part = &p.info
+	w.Header().Set("X-Trimmer-Hash", h.String())
+	writeJSON(w, http.StatusOK, i)
+}
+
+func (s *Server) listParts(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.upload(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "upload not found")
+		return
+	}
+	res := &media.PartListResponse{
+		UploadId: u.id,
+		Key:      u.key,
+		Parts:    make(media.PartInfoList, 0, len(u.parts)),
+	}
+	for _, num := range sortedParts(u) {
+		info := u.parts[num].info
+		res.Parts = append(res.Parts, &info)
+	}
+	res.Count = len(res.Parts)
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) commitUpload(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	u, ok := s.upload(id)
+	if !ok {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "upload not found")
+		return
+	}
+	nums := sortedParts(u)
+	var buf bytes.Buffer
+	for i, num := range nums {
+		if num != i+1 {
+			s.mu.Unlock()
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("missing part %d", i+1))
+			return
+		}
+		p := u.parts[num]
+		if i < len(nums)-1 && p.info.Size < s.Manifest.Limits.PartSizeMin {
+			s.mu.Unlock()
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("part %d too small", num))
+			return
+		}
+		buf.Write(p.data)
+	}
+	s.mu.Unlock()
+
+	data := buf.Bytes()
+	if err := checkSize(r, int64(len(data))); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	declared := hash.ParseString(r.Header.Get("X-Trimmer-Hash"))
+	h := s.sum(data, declared)
+	if err := declared.Check(h, true); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.uploads, id)
+	s.files[u.key] = &file{mimetype: u.mimetype, data: data, hashes: h}
+	i := s.uploadInfo(u, media.UploadStateComplete)
+	i.Hashes = h
+	i.Size = int64(len(data))
+	w.Header().Set("X-Trimmer-Hash", h.String())
+	writeJSON(w, http.StatusOK, i)
+}
+
+func (s *Server) abortUpload(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.upload(id); !ok {
+		writeError(w, http.StatusNotFound, "upload not found")
+		return
+	}
+	delete(s.uploads, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getFile(w http.ResponseWriter, r *http.Request, key string) {
+	s.mu.Lock()
+	f, ok := s.files[key]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "file not found")
+		return
+	}
+	ct := f.mimetype
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", ct)
+	w.Header().Set("Content-Length", strconv.Itoa(len(f.data)))
+	w.Header().Set("X-Trimmer-Hash", f.hashes.String())
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(f.data)
+	}
+}
+
+// upload returns the unfinished upload with id. Callers must hold s.mu.
+func (s *Server) upload(id string) (*upload, bool) {
+	u, ok := s.uploads[id]
+	if ok && time.Now().After(u.expires) {
+		delete(s.uploads, id)
+		return nil, false
+	}
+	return u, ok
+}
+
+// uploadInfo describes an upload's state. Callers must hold s.mu.
+func (s *Server) uploadInfo(u *upload, state media.UploadState) *media.UploadInfo {
+	return &media.UploadInfo{
+		VolumeUUID:  s.Manifest.UUID,
+		Key:         u.key,
+		ContentType: u.mimetype,
+		UploadId:    u.id,
+		State:       state,
+		Expires:     u.expires,
+		TotalParts:  int64(len(u.parts)),
+	}
+}
+
+// sum hashes data with all hash types supported by the volume, the types the
+// client has declared and the default part hash.
+func (s *Server) sum(data []byte, declared hash.HashBlock) hash.HashBlock {
+	var h hash.HashBlock
+	flags := s.Manifest.HashTypes.Flags() | declared.Flags() | hash.DefaultHash.Flag()
+	h.NewWriter(ioutil.Discard, flags).Write(data)
+	h.Sum()
+	h.Reset()
+	return h
+}
+
+func sortedParts(u *upload) []int {
+	nums := make([]int, 0, len(u.parts))
+	for num := range u.parts {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// checkSize compares the expected size sent as query parameter, if any.
+func checkSize(r *http.Request, size int64) error {
+	v := r.URL.Query().Get("size")
+	if v == "" {
+		return nil
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err != nil || n != size {
+		return fmt.Errorf("size mismatch: expected %s bytes, got %d", v, size)
+	}
+	return nil
+}
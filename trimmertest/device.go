@@ -0,0 +1,139 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmertest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DeviceCodeTTL is the lifetime of device codes issued by the server.
+const DeviceCodeTTL = 10 * time.Minute
+
+type deviceState int
+
+const (
+	devicePending deviceState = iota
+	deviceApproved
+	deviceDenied
+)
+
+// device is a device authorization waiting for the user.
+type device struct {
+	userCode string
+	scopes   string
+	expires  time.Time
+	state    deviceState
+}
+
+// ApproveDevice approves the device login with userCode as if the user had
+// entered the code at the verification URL. It returns false when the code
+// is unknown.
+func (s *Server) ApproveDevice(userCode string) bool {
+	return s.setDeviceState(userCode, deviceApproved)
+}
+
+// DenyDevice rejects the device login with userCode.
+func (s *Server) DenyDevice(userCode string) bool {
+	return s.setDeviceState(userCode, deviceDenied)
+}
+
+func (s *Server) setDeviceState(userCode string, state deviceState) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.devices {
+		if d.userCode == userCode && d.state == devicePending {
+			d.state = state
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeDevice issues a device code (RFC 8628 section 3.2).
+func (s *Server) authorizeDevice(w http.ResponseWriter, r *http.Request, _ []string) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, "invalid_request", err.Error())
+		return
+	}
+	code := strings.ToUpper(randomHex(4))
+	userCode := code[:4] + "-" + code[4:]
+	verify := s.API.URL + "/device"
+	deviceCode := randomHex(16)
+	s.mu.Lock()
+	s.devices[deviceCode] = &device{
+		userCode: userCode,
+		scopes:   r.PostForm.Get("scope"),
+		expires:  time.Now().Add(DeviceCodeTTL),
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"device_code":               deviceCode,
+		"user_code":                 userCode,
+		"verification_uri":          verify,
+		"verification_uri_complete": verify + "?user_code=" + userCode,
+		"expires_in":                int(DeviceCodeTTL / time.Second),
+	})
+}
+
+// deviceToken answers token requests for device codes (RFC 8628 section 3.4).
+func (s *Server) deviceToken(w http.ResponseWriter, r *http.Request, _ []string) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, "invalid_request", err.Error())
+		return
+	}
+	if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:device_code" {
+		writeOAuthError(w, "unsupported_grant_type", "")
+		return
+	}
+	code := r.PostForm.Get("device_code")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[code]
+	switch {
+	case !ok:
+		writeOAuthError(w, "invalid_grant", "unknown device code")
+	case time.Now().After(d.expires):
+		delete(s.devices, code)
+		writeOAuthError(w, "expired_token", "")
+	case d.state == deviceDenied:
+		delete(s.devices, code)
+		writeOAuthError(w, "access_denied", "")
+	case d.state == devicePending:
+		writeOAuthError(w, "authorization_pending", "")
+	default:
+		delete(s.devices, code)
+		sess := s.newSession(d.scopes)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token":  sess.AccessToken,
+			"token_type":    "bearer",
+			"expires_in":    int(s.TokenTTL / time.Second),
+			"refresh_token": sess.RefreshToken,
+			"scope":         sess.Scopes,
+		})
+	}
+}
+
+// writeOAuthError sends an OAuth 2.0 error response (RFC 6749 section 5.2).
+func writeOAuthError(w http.ResponseWriter, code, desc string) {
+	v := map[string]string{"error": code}
+	if desc != "" {
+		v["error_description"] = desc
+	}
+	writeJSON(w, http.StatusBadRequest, v)
+}
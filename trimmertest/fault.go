@@ -0,0 +1,165 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmertest
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"trimmer.io/go-trimmer/hash"
+)
+
+// Fault describes an error the server injects into matching requests on the
+// API or CDN. Path is matched as substring of the URL path, so "/uploads"
+// matches all upload calls and "/uploads/" only multipart calls.
+type Fault struct {
+	Method      string        // HTTP method to match, empty matches all
+	Path        string        // URL path substring to match, empty matches all
+	Times       int           // number of requests to affect, 0 affects all
+	Latency     time.Duration // delay before the request is handled
+	Status      int           // fail the request with this status code
+	Lost        bool          // handle the request before failing with Status
+	RetryAfter  time.Duration // Retry-After header sent with Status
+	Truncate    bool          // send only the first half of the response body
+	CorruptHash bool          // report wrong checksums in X-Trimmer-Hash
+}
+
+// Inject adds a fault. Faults are checked in the order they were added and
+// the first match is applied to a request.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes all faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// fault returns the fault to apply to r, if any. Callers must hold s.mu.
+func (s *Server) fault(r *http.Request) *Fault {
+	for i, f := range s.faults {
+		if (f.Method != "" && f.Method != r.Method) || !strings.Contains(r.URL.Path, f.Path) {
+			continue
+		}
+		match := *f
+		if f.Times > 0 {
+			if f.Times--; f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return &match
+	}
+	return nil
+}
+
+// handler records requests, assigns request ids and applies injected faults
+// before passing them to h.
+func (s *Server) handler(h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		f := s.fault(r)
+		isPart := r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/uploads/")
+		if isPart {
+			s.parts++
+			s.maxParts = max(s.maxParts, s.parts)
+		}
+		s.mu.Unlock()
+		if isPart {
+			defer func() {
+				s.mu.Lock()
+				s.parts--
+				s.mu.Unlock()
+			}()
+		}
+
+		w.Header().Set("X-Request-Id", newUUID())
+
+		if f == nil {
+			h(w, r)
+			return
+		}
+
+		if f.Latency > 0 {
+			select {
+			case <-time.After(f.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if f.Status > 0 {
+			if f.Lost {
+				// the request takes effect, but its response never arrives
+				h(httptest.NewRecorder(), r)
+			}
+			// consume the body so clients see the response, not a write error
+			io.Copy(ioutil.Discard, r.Body)
+			if f.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(f.RetryAfter/time.Second)))
+			}
+			writeError(w, f.Status, "injected fault")
+			return
+		}
+
+		if !f.Truncate && !f.CorruptHash {
+			h(w, r)
+			return
+		}
+
+		rec := httptest.NewRecorder()
+		h(rec, r)
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		body := rec.Body.Bytes()
+		if v := w.Header().Get("X-Trimmer-Hash"); f.CorruptHash && v != "" {
+			w.Header().Set("X-Trimmer-Hash", corrupt(v))
+		}
+		if f.Truncate {
+			// announce the full length, the server closes the connection early
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			body = body[:len(body)/2]
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(body)
+	})
+}
+
+// corrupt changes every checksum in a X-Trimmer-Hash header value.
+func corrupt(v string) string {
+	h := hash.ParseString(v)
+	for _, t := range hash.HashTypesAll {
+		if s := h.Get(t); s != "" {
+			last := byte('0')
+			if s[len(s)-1] == '0' {
+				last = '1'
+			}
+			h.Set(t, s[:len(s)-1]+string(last))
+		}
+	}
+	return h.String()
+}
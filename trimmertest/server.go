@@ -0,0 +1,993 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package trimmertest provides an in-process fake of the Trimmer API and CDN
+// for testing code that uses the SDK without network access.
+//
+// The server keeps all state in memory. It implements authentication, a
+// stand-in OAuth device authorization server, the main resource routes
+// (workspaces, assets, media, tags, stashes, links, jobs and events) and the
+// CDN volume upload protocol with real checksum verification. Faults like latency, server errors, truncated responses and
+// corrupted checksums can be injected to exercise error handling.
+//
+//	srv := trimmertest.NewServer()
+//	defer srv.Close()
+//
+//	api := client.New(srv.Options()...)
+//	err := api.Session.Login(ctx, srv.LoginParams())
+package trimmertest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	trimmer "trimmer.io/go-trimmer"
+	"trimmer.io/go-trimmer/hash"
+)
+
+// Default credentials accepted by a new Server.
+const (
+	DefaultKey      = trimmer.ApiKey("test-api-key")
+	DefaultUsername = "test"
+	DefaultEmail    = "test@example.com"
+	DefaultPassword = "test-password"
+)
+
+// Resource kinds stored by the server.
+const (
+	kindWorkspace = "workspace"
+	kindAsset     = "asset"
+	kindMedia     = "media"
+	kindTag       = "tag"
+	kindStash     = "stash"
+	kindLink      = "link"
+	kindJob       = "job"
+	kindEvent     = "event"
+)
+
+// idFields maps resource kinds to the JSON field holding their id.
+var idFields = map[string]string{
+	kindWorkspace: "workspaceId",
+	kindAsset:     "assetId",
+	kindMedia:     "mediaId",
+	kindTag:       "tagId",
+	kindStash:     "stashId",
+	kindLink:      "linkId",
+	kindJob:       "jobId",
+	kindEvent:     "eventId",
+}
+
+// record is a stored resource. Documents are kept as generic JSON objects so
+// the server accepts every field the SDK sends and returns it unchanged.
+type record struct {
+	kind      string
+	id        string
+	parent    string // id of the owning resource
+	workspace string // id of the workspace the resource belongs to
+	doc       map[string]interface{}
+}
+
+// Server is a fake Trimmer service. API and CDN run as separate HTTP servers
+// like the real endpoints.
+type Server struct {
+	API *httptest.Server
+	CDN *httptest.Server
+
+	// Credentials accepted by the server.
+	Key      trimmer.ApiKey
+	Username string
+	Email    string
+	Password string
+
+	// TokenTTL is the lifetime of access tokens issued by the server.
+	TokenTTL time.Duration
+
+	// UploadTTL is the time after which the CDN discards unfinished
+	// multipart uploads.
+	UploadTTL time.Duration
+
+	// Manifest describes the server's single CDN volume. Change limits before
+	// the first upload, the SDK caches manifests per volume.
+	Manifest trimmer.VolumeManifest
+
+	mu       sync.Mutex
+	seq      int64
+	user     map[string]interface{}
+	records  map[string]map[string]*record
+	tokens   map[string]grant  // access token -> expiry and scopes
+	refresh  map[string]string // valid refresh token -> scopes
+	uploads  map[string]*upload
+	files    map[string]*file
+	replies  map[string]*reply  // idempotency key -> response
+	devices  map[string]*device // device code -> authorization
+	faults   []*Fault
+	requests []string
+	parts    int // part uploads in flight
+	maxParts int // most part uploads in flight at once
+}
+
+// NewServer starts a fake API and CDN server.
+func NewServer() *Server {
+	s := &Server{
+		Key:       DefaultKey,
+		Username:  DefaultUsername,
+		Email:     DefaultEmail,
+		Password:  DefaultPassword,
+		TokenTTL:  time.Hour,
+		UploadTTL: 24 * time.Hour,
+		records:   make(map[string]map[string]*record),
+		tokens:    make(map[string]grant),
+		refresh:   make(map[string]string),
+		uploads:   make(map[string]*upload),
+		files:     make(map[string]*file),
+		replies:   make(map[string]*reply),
+		devices:   make(map[string]*device),
+	}
+	now := time.Now().UTC()
+	s.user = map[string]interface{}{
+		"userId":      s.nextId(),
+		"name":        s.Username,
+		"displayName": "Test User",
+		"state":       "active",
+		"createdAt":   now,
+		"updatedAt":   now,
+	}
+	s.API = httptest.NewServer(s.handler(s.serveAPI))
+	s.CDN = httptest.NewServer(s.handler(s.serveCDN))
+	s.Manifest = trimmer.VolumeManifest{
+		UUID:      newUUID(),
+		Name:      "test",
+		UrlBase:   s.CDN.URL,
+		UrlPrefix: "v" + randomHex(4),
+		Limits: &trimmer.VolumeLimits{
+			PartSizeMin:   64 << 10,
+			PartSizeMax:   5 << 30,
+			PartsMax:      10000,
+			FileSizeMax:   5 << 40,
+			SinglePartMax: 16 << 20,
+		},
+		HashTypes: hash.HashTypeList{hash.HashTypeMd5, hash.HashTypeSha1, hash.HashTypeSha256},
+	}
+	return s
+}
+
+// Close shuts down both servers.
+func (s *Server) Close() {
+	s.API.Close()
+	s.CDN.Close()
+}
+
+// Options returns the options that point a trimmer.Config at this server.
+func (s *Server) Options() []trimmer.Option {
+	return []trimmer.Option{
+		trimmer.WithKey(s.Key),
+		trimmer.WithAPIURL(s.API.URL),
+		trimmer.WithCDNURL(s.CDN.URL),
+	}
+}
+
+// LoginParams returns credentials that log in the server's user.
+func (s *Server) LoginParams() *trimmer.LoginParams {
+	return &trimmer.LoginParams{
+		Username: s.Username,
+		Password: s.Password,
+	}
+}
+
+// NewSession issues a valid login session without a login call.
+func (s *Server) NewSession() *trimmer.Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.newSession("")
+}
+
+// ExpireTokens invalidates all access tokens issued so far. Refresh tokens
+// stay valid.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]grant)
+}
+
+// RevokeTokens invalidates all access and refresh tokens issued so far.
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]grant)
+	s.refresh = make(map[string]string)
+}
+
+// UserId returns the id of the server's user.
+func (s *Server) UserId() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.user["userId"].(string)
+}
+
+// Requests returns the number of requests received so far whose method and
+// path match. An empty method matches all methods, path is matched as
+// substring like Fault.Path.
+func (s *Server) Requests(method, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int
+	for _, r := range s.requests {
+		m := strings.SplitN(r, " ", 2)
+		if (method == "" || m[0] == method) && strings.Contains(m[1], path) {
+			n++
+		}
+	}
+	return n
+}
+
+// MaxParallelParts returns the largest number of multipart upload parts the
+// CDN has received at the same time.
+func (s *Server) MaxParallelParts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxParts
+}
+
+// ---------------------------------------------------------------------------
+// Routing
+//
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request, args []string)
+
+type route struct {
+	method  string
+	pattern []string
+	handle  handlerFunc
+	public  bool                   // no login session required
+	scope   trimmer.ApiAccessScope // scope required unless the token is unscoped
+}
+
+func (s *Server) routes() []route {
+	r := func(method, pattern string, h handlerFunc) route {
+		return route{method: method, pattern: strings.Split(strings.Trim(pattern, "/"), "/"), handle: h}
+	}
+	public := func(rt route) route {
+		rt.public = true
+		return rt
+	}
+	scoped := func(scope trimmer.ApiAccessScope, rt route) route {
+		rt.scope = scope
+		return rt
+	}
+	return []route{
+		public(r(http.MethodPost, "/auth/login", s.login)),
+		public(r(http.MethodPost, "/auth/refresh", s.refreshToken)),
+		public(r(http.MethodPost, "/auth/device", s.authorizeDevice)),
+		public(r(http.MethodPost, "/auth/token", s.deviceToken)),
+		r(http.MethodPost, "/auth/logout", s.logout),
+
+		r(http.MethodGet, "/users/me", s.getUser),
+		scoped(trimmer.API_SCOPE_PRIVATE, r(http.MethodPatch, "/users/me", s.updateUser)),
+		r(http.MethodGet, "/users/me/workspaces", s.listAll(kindWorkspace, "workspaces")),
+		r(http.MethodPost, "/users/me/workspaces", s.createWorkspace),
+		r(http.MethodGet, "/users/me/media", s.listAll(kindMedia, "media")),
+		r(http.MethodGet, "/users/me/events", s.listAll(kindEvent, "events")),
+
+		r(http.MethodGet, "/workspaces/*", s.get(kindWorkspace)),
+		r(http.MethodPatch, "/workspaces/*", s.update(kindWorkspace)),
+		r(http.MethodDelete, "/workspaces/*", s.delete(kindWorkspace)),
+		r(http.MethodGet, "/workspaces/*/assets", s.listByWorkspace(kindAsset, "assets")),
+		r(http.MethodPost, "/workspaces/*/assets", s.createChild(kindWorkspace, kindAsset)),
+		r(http.MethodGet, "/workspaces/*/media", s.listByWorkspace(kindMedia, "media")),
+		r(http.MethodGet, "/workspaces/*/stashes", s.listByWorkspace(kindStash, "stashes")),
+		r(http.MethodPost, "/workspaces/*/stashes", s.createChild(kindWorkspace, kindStash)),
+		r(http.MethodGet, "/workspaces/*/jobs", s.listByWorkspace(kindJob, "jobs")),
+		r(http.MethodGet, "/workspaces/*/events", s.listByWorkspace(kindEvent, "events")),
+
+		r(http.MethodGet, "/assets/*", s.get(kindAsset)),
+		r(http.MethodPatch, "/assets/*", s.update(kindAsset)),
+		r(http.MethodDelete, "/assets/*", s.delete(kindAsset)),
+		r(http.MethodGet, "/assets/*/tags", s.listByParent(kindTag, "tags")),
+		r(http.MethodPost, "/assets/*/tags", s.createChild(kindAsset, kindTag)),
+		r(http.MethodGet, "/assets/*/media", s.listByParent(kindMedia, "media")),
+		r(http.MethodPost, "/assets/*/media", s.createChild(kindAsset, kindMedia)),
+		scoped(trimmer.API_SCOPE_UPLOAD, r(http.MethodPost, "/assets/*/upload", s.createUpload)),
+		r(http.MethodDelete, "/assets/*/media/*", s.deleteChild(kindMedia)),
+
+		r(http.MethodGet, "/media/*", s.get(kindMedia)),
+		r(http.MethodPatch, "/media/*", s.update(kindMedia)),
+		r(http.MethodDelete, "/media/*", s.delete(kindMedia)),
+		r(http.MethodPost, "/media/*/complete", s.completeUpload),
+
+		r(http.MethodGet, "/tags/*", s.get(kindTag)),
+		r(http.MethodPatch, "/tags/*", s.update(kindTag)),
+		r(http.MethodDelete, "/tags/*", s.delete(kindTag)),
+
+		r(http.MethodGet, "/stashes/*", s.get(kindStash)),
+		r(http.MethodPatch, "/stashes/*", s.update(kindStash)),
+		r(http.MethodDelete, "/stashes/*", s.delete(kindStash)),
+		r(http.MethodGet, "/stashes/*/links", s.listByParent(kindLink, "links")),
+		r(http.MethodPost, "/stashes/*/links", s.createChild(kindStash, kindLink)),
+		r(http.MethodDelete, "/stashes/*/links", s.deleteChildren(kindLink)),
+		r(http.MethodGet, "/stashes/*/links/*", s.getChild(kindLink)),
+		r(http.MethodPatch, "/stashes/*/links/*", s.updateChild(kindLink)),
+		r(http.MethodDelete, "/stashes/*/links/*", s.deleteChild(kindLink)),
+
+		r(http.MethodGet, "/jobs/*", s.get(kindJob)),
+		r(http.MethodPatch, "/jobs/*", s.update(kindJob)),
+	}
+}
+
+// match returns the path arguments when path matches the route's pattern.
+func (rt route) match(method string, path []string) ([]string, bool) {
+	if method != rt.method || len(path) != len(rt.pattern) {
+		return nil, false
+	}
+	var args []string
+	for i, p := range rt.pattern {
+		switch {
+		case p == "*":
+			args = append(args, path[i])
+		case p != path[i]:
+			return nil, false
+		}
+	}
+	return args, true
+}
+
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request) {
+	if trimmer.ApiKey(r.Header.Get("X-API-Key")) != s.Key {
+		writeError(w, http.StatusUnauthorized, "invalid API key")
+		return
+	}
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	for _, rt := range s.routes() {
+		args, ok := rt.match(r.Method, path)
+		if !ok {
+			continue
+		}
+		if !rt.public {
+			g, ok := s.authorized(r)
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "invalid or expired access token")
+				return
+			}
+			w.Header().Set("X-OAuth-Scopes", g.scopes)
+			if !g.allows(rt.scope) {
+				writeError(w, http.StatusForbidden, "scope "+string(rt.scope)+" required")
+				return
+			}
+		}
+		if r.Method == http.MethodGet {
+			s.conditional(w, r, func(w http.ResponseWriter) { rt.handle(w, r, args) })
+			return
+		}
+		if key := r.Header.Get(trimmer.IdempotencyKeyHeader); key != "" {
+			s.idempotent(w, r, key, func(w http.ResponseWriter) { rt.handle(w, r, args) })
+			return
+		}
+		rt.handle(w, r, args)
+		return
+	}
+	writeError(w, http.StatusNotFound, "route not found")
+}
+
+// conditional adds an ETag to successful responses and answers requests
+// with a matching If-None-Match header with 304 Not Modified.
+func (s *Server) conditional(w http.ResponseWriter, r *http.Request, h func(http.ResponseWriter)) {
+	rec := httptest.NewRecorder()
+	h(rec)
+	for k, v := range rec.Header() {
+		w.Header()[k] = v
+	}
+	if rec.Code != http.StatusOK {
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+		return
+	}
+	sum := sha256.Sum256(rec.Body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(rec.Code)
+	w.Write(rec.Body.Bytes())
+}
+
+// reply is a response stored for an idempotency key.
+type reply struct {
+	request string
+	code    int
+	header  http.Header
+	body    []byte
+}
+
+// idempotent runs h once per idempotency key and sends the stored response
+// for repeated requests. Reusing a key for a different request fails.
+func (s *Server) idempotent(w http.ResponseWriter, r *http.Request, key string, h func(http.ResponseWriter)) {
+	request := r.Method + " " + r.URL.Path
+	s.mu.Lock()
+	rp, ok := s.replies[key]
+	s.mu.Unlock()
+	if !ok {
+		rec := httptest.NewRecorder()
+		h(rec)
+		rp = &reply{request, rec.Code, rec.Header(), rec.Body.Bytes()}
+		if rp.code < 500 {
+			s.mu.Lock()
+			s.replies[key] = rp
+			s.mu.Unlock()
+		}
+	} else if rp.request != request {
+		writeError(w, http.StatusUnprocessableEntity, "idempotency key reused for a different request")
+		return
+	}
+	for k, v := range rp.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(rp.code)
+	w.Write(rp.body)
+}
+
+// ---------------------------------------------------------------------------
+// Authentication
+//
+
+// grant is an issued access token.
+type grant struct {
+	expires time.Time
+	scopes  string // comma separated, empty grants all scopes
+}
+
+// allows returns true when the token may be used for calls that need scope.
+func (g grant) allows(scope trimmer.ApiAccessScope) bool {
+	if scope == "" || g.scopes == "" {
+		return true
+	}
+	for _, v := range trimmer.ParseApiScopes(g.scopes) {
+		if v == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) authorized(r *http.Request) (grant, bool) {
+	f := strings.Fields(r.Header.Get("Authorization"))
+	if len(f) != 2 || f[0] != "Bearer" {
+		return grant{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.tokens[f[1]]
+	return g, ok && time.Now().Before(g.expires)
+}
+
+// newSession issues fresh access and refresh tokens. Callers must hold s.mu.
+func (s *Server) newSession(scopes string) *trimmer.Session {
+	access, refresh := randomHex(16), randomHex(16)
+	exp := time.Now().UTC().Add(s.TokenTTL)
+	s.tokens[access] = grant{exp, scopes}
+	s.refresh[refresh] = scopes
+	u := &trimmer.User{}
+	remarshal(s.user, u)
+	return &trimmer.Session{
+		TokenId:      s.nextId(),
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		Scopes:       scopes,
+		RefreshToken: refresh,
+		ExpiresAt:    exp,
+		User:         u,
+	}
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request, _ []string) {
+	var p trimmer.LoginParams
+	if err := readJSON(r, &p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if (p.Username != s.Username && p.Email != s.Email) || p.Password != s.Password {
+		writeError(w, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+	s.mu.Lock()
+	sess := s.newSession(p.Scopes)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, sess)
+}
+
+func (s *Server) refreshToken(w http.ResponseWriter, r *http.Request, _ []string) {
+	var p struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := readJSON(r, &p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scopes, ok := s.refresh[p.RefreshToken]
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "invalid refresh token")
+		return
+	}
+	delete(s.refresh, p.RefreshToken)
+	writeJSON(w, http.StatusOK, s.newSession(scopes))
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request, _ []string) {
+	f := strings.Fields(r.Header.Get("Authorization"))
+	s.mu.Lock()
+	delete(s.tokens, f[1])
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request, _ []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.user)
+}
+
+func (s *Server) updateUser(w http.ResponseWriter, r *http.Request, _ []string) {
+	body, err := readDoc(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	merge(s.user, body, "userId")
+	writeJSON(w, http.StatusOK, s.user)
+}
+
+// ---------------------------------------------------------------------------
+// Resources
+//
+
+func (s *Server) createWorkspace(w http.ResponseWriter, r *http.Request, _ []string) {
+	body, err := readDoc(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if name, _ := body["name"].(string); name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body["state"] = "active"
+	rec := s.create(kindWorkspace, "", "", body)
+	writeJSON(w, http.StatusCreated, rec.doc)
+}
+
+// createChild creates a resource owned by the resource in the first argument.
+func (s *Server) createChild(parentKind, kind string) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, args []string) {
+		body, err := readDoc(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		p := s.find(parentKind, args[0])
+		if p == nil {
+			writeError(w, http.StatusNotFound, parentKind+" not found")
+			return
+		}
+		rec := s.create(kind, p.id, p.workspace, body)
+		writeJSON(w, http.StatusCreated, rec.doc)
+	}
+}
+
+func (s *Server) get(kind string) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, args []string) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		rec := s.find(kind, args[0])
+		if rec == nil {
+			writeError(w, http.StatusNotFound, kind+" not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, rec.doc)
+	}
+}
+
+func (s *Server) update(kind string) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, args []string) {
+		body, err := readDoc(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		rec := s.find(kind, args[0])
+		if rec == nil {
+			writeError(w, http.StatusNotFound, kind+" not found")
+			return
+		}
+		s.modify(rec, body)
+		writeJSON(w, http.StatusOK, rec.doc)
+	}
+}
+
+func (s *Server) delete(kind string) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, args []string) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		rec := s.find(kind, args[0])
+		if rec == nil {
+			writeError(w, http.StatusNotFound, kind+" not found")
+			return
+		}
+		s.remove(rec)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getChild, updateChild and deleteChild handle resources addressed through
+// their parent, the child id is the second argument.
+func (s *Server) getChild(kind string) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, args []string) {
+		s.get(kind)(w, r, s.childArgs(kind, args))
+	}
+}
+
+func (s *Server) updateChild(kind string) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, args []string) {
+		s.update(kind)(w, r, s.childArgs(kind, args))
+	}
+}
+
+func (s *Server) deleteChild(kind string) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, args []string) {
+		s.delete(kind)(w, r, s.childArgs(kind, args))
+	}
+}
+
+// childArgs returns the child id when it belongs to the parent, and an
+// unknown id otherwise.
+func (s *Server) childArgs(kind string, args []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec := s.find(kind, args[1]); rec != nil && rec.parent == args[0] {
+		return args[1:]
+	}
+	return []string{""}
+}
+
+func (s *Server) deleteChildren(kind string) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, args []string) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, rec := range s.records[kind] {
+			if rec.parent == args[0] {
+				s.remove(rec)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Media uploads
+//
+
+func (s *Server) createUpload(w http.ResponseWriter, r *http.Request, args []string) {
+	body, err := readDoc(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.find(kindAsset, args[0])
+	if a == nil {
+		writeError(w, http.StatusNotFound, "asset not found")
+		return
+	}
+	body["state"] = "uploading"
+	m := s.create(kindMedia, a.id, a.workspace, body)
+	j := s.create(kindJob, m.id, a.workspace, map[string]interface{}{
+		"type":    "upload",
+		"state":   "created",
+		"assetId": a.id,
+		"mediaId": m.id,
+	})
+	m.doc["jobId"] = j.id
+	m.doc["url"] = fmt.Sprintf("%s/%s/uploads?key=%s", s.CDN.URL, s.Manifest.UrlPrefix, m.id)
+	writeJSON(w, http.StatusCreated, m.doc)
+}
+
+func (s *Server) completeUpload(w http.ResponseWriter, r *http.Request, args []string) {
+	var p trimmer.MediaUploadCompletionParams
+	if err := readJSON(r, &p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.find(kindMedia, args[0])
+	if m == nil {
+		writeError(w, http.StatusNotFound, "media not found")
+		return
+	}
+	f, ok := s.files[m.id]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "upload is incomplete")
+		return
+	}
+	for _, fi := range p.Files {
+		if err := fi.Hashes.Check(f.hashes, true); err != nil {
+			writeError(w, http.StatusBadRequest, "checksum mismatch")
+			return
+		}
+	}
+	now := time.Now().UTC()
+	s.modify(m, map[string]interface{}{
+		"state":      "ready",
+		"size":       int64(len(f.data)),
+		"hashes":     f.hashes,
+		"uploadedAt": now,
+		"url":        s.fileURL(m.id),
+	})
+	jobId, _ := m.doc["jobId"].(string)
+	if j := s.find(kindJob, jobId); j != nil {
+		s.modify(j, map[string]interface{}{"state": "complete", "progress": 100})
+	}
+	writeJSON(w, http.StatusOK, m.doc)
+}
+
+// ---------------------------------------------------------------------------
+// Lists
+//
+
+func (s *Server) listAll(kind, key string) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ []string) {
+		s.list(w, r, kind, key, func(*record) bool { return true })
+	}
+}
+
+func (s *Server) listByWorkspace(kind, key string) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, args []string) {
+		s.list(w, r, kind, key, func(rec *record) bool { return rec.workspace == args[0] })
+	}
+}
+
+func (s *Server) listByParent(kind, key string) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, args []string) {
+		s.list(w, r, kind, key, func(rec *record) bool { return rec.parent == args[0] })
+	}
+}
+
+// list writes one page of matching resources, newest first, or oldest first
+// with order=asc. Like the real API maxId is inclusive, minId exclusive and
+// the before and after time window is exclusive.
+func (s *Server) list(w http.ResponseWriter, r *http.Request, kind, key string, match func(*record) bool) {
+	q := r.URL.Query()
+	count, _ := strconv.Atoi(q.Get("count"))
+	if count <= 0 || count > trimmer.LIST_MAX_LIMIT {
+		count = trimmer.LIST_MAX_LIMIT
+	}
+	maxId, minId := q.Get("maxId"), q.Get("minId")
+	var window [2]time.Time
+	for i, k := range []string{"after", "before"} {
+		if v := q.Get(k); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			window[i] = t
+		}
+	}
+	inWindow := func(rec *record) bool {
+		t, _ := rec.doc["createdAt"].(time.Time)
+		return (window[0].IsZero() || t.After(window[0])) && (window[1].IsZero() || t.Before(window[1]))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.records[kind]))
+	for id, rec := range s.records[kind] {
+		if !match(rec) || !inWindow(rec) || (maxId != "" && id > maxId) || (minId != "" && id <= minId) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if q.Get("order") == "asc" {
+		sort.Strings(ids)
+	} else {
+		sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	}
+	if len(ids) > count {
+		ids = ids[:count]
+	}
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = s.records[kind][id].doc
+	}
+	res := map[string]interface{}{
+		"count": len(values),
+		key:     values,
+	}
+	if len(ids) > 0 {
+		lo, hi := ids[len(ids)-1], ids[0]
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		res["maxId"] = hi
+		res["minId"] = lo
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// ---------------------------------------------------------------------------
+// Store, callers must hold s.mu
+//
+
+func (s *Server) nextId() string {
+	s.seq++
+	return fmt.Sprintf("%016x", s.seq)
+}
+
+func (s *Server) find(kind, id string) *record {
+	return s.records[kind][id]
+}
+
+func (s *Server) create(kind, parent, workspace string, doc map[string]interface{}) *record {
+	id := s.nextId()
+	if kind == kindWorkspace {
+		workspace = id
+	}
+	now := time.Now().UTC()
+	delete(doc, "embed")
+	doc[idFields[kind]] = id
+	doc["accountId"] = s.user["userId"]
+	doc["authorId"] = s.user["userId"]
+	doc["workspaceId"] = workspace
+	doc["createdAt"] = now
+	doc["updatedAt"] = now
+	switch kind {
+	case kindTag:
+		doc[idFields[kindAsset]] = parent
+	case kindMedia:
+		if _, ok := doc["state"]; !ok {
+			doc["state"] = "created"
+		}
+	}
+	rec := &record{kind: kind, id: id, parent: parent, workspace: workspace, doc: doc}
+	if s.records[kind] == nil {
+		s.records[kind] = make(map[string]*record)
+	}
+	s.records[kind][id] = rec
+	s.emit(rec, "created")
+	return rec
+}
+
+func (s *Server) modify(rec *record, body map[string]interface{}) {
+	delete(body, "embed")
+	merge(rec.doc, body, idFields[rec.kind], "accountId", "authorId", "workspaceId", "createdAt")
+	rec.doc["updatedAt"] = time.Now().UTC()
+	s.emit(rec, "updated")
+}
+
+func (s *Server) remove(rec *record) {
+	delete(s.records[rec.kind], rec.id)
+	s.emit(rec, "deleted")
+}
+
+// emit records an event for a change to a resource.
+func (s *Server) emit(rec *record, action string) {
+	if rec.kind == kindEvent {
+		return
+	}
+	typ := rec.kind
+	if typ == kindLink {
+		typ = kindStash
+	}
+	s.create(kindEvent, rec.id, rec.workspace, map[string]interface{}{
+		"eventKey":  rec.kind + "." + action,
+		"eventType": typ,
+		"data":      map[string]string{idFields[rec.kind]: rec.id},
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Helpers
+//
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	e := trimmer.NewApiError(status)
+	e.Message = msg
+	b := e.Marshal()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+func readJSON(r *http.Request, v interface{}) error {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil || len(b) == 0 {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func readDoc(r *http.Request) (map[string]interface{}, error) {
+	doc := make(map[string]interface{})
+	if err := readJSON(r, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// merge copies all fields from src into dst except for protected keys.
+func merge(dst, src map[string]interface{}, protected ...string) {
+	keep := make(map[string]bool, len(protected))
+	for _, k := range protected {
+		keep[k] = true
+	}
+	for k, v := range src {
+		if !keep[k] {
+			dst[k] = v
+		}
+	}
+}
+
+// remarshal converts between JSON compatible representations.
+func remarshal(src, dst interface{}) {
+	b, _ := json.Marshal(src)
+	json.Unmarshal(b, dst)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newUUID() string {
+	s := randomHex(16)
+	return strings.Join([]string{s[0:8], s[8:12], s[12:16], s[16:20], s[20:32]}, "-")
+}
@@ -0,0 +1,1064 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmertest_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	trimmer "trimmer.io/go-trimmer"
+	"trimmer.io/go-trimmer/client"
+	"trimmer.io/go-trimmer/media"
+	"trimmer.io/go-trimmer/trimmertest"
+)
+
+func newTestClient(t *testing.T, srv *trimmertest.Server) *client.API {
+	opts := append(srv.Options(),
+		trimmer.WithUploadPartSize(64<<10),
+		trimmer.WithRetryPolicy(trimmer.RetryPolicy{MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}),
+	)
+	api := client.New(opts...)
+	if err := api.Session.Login(context.Background(), srv.LoginParams()); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	return api
+}
+
+func newTestAsset(t *testing.T, api *client.API) *trimmer.Asset {
+	ctx := context.Background()
+	w, err := api.Users.NewWorkspace(ctx, &trimmer.WorkspaceParams{Name: "test"})
+	if err != nil {
+		t.Fatalf("creating workspace failed: %v", err)
+	}
+	a, err := api.Workspaces.NewAsset(ctx, w.ID, &trimmer.AssetParams{})
+	if err != nil {
+		t.Fatalf("creating asset failed: %v", err)
+	}
+	return a
+}
+
+func randomData(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+func upload(api *client.API, assetId string, data []byte) (*trimmer.Media, error) {
+	params := &trimmer.MediaParams{
+		Filename: "test.bin",
+		Size:     int64(len(data)),
+		Mimetype: "application/octet-stream",
+	}
+	return api.Assets.UploadMedia(context.Background(), assetId, params, bytes.NewReader(data))
+}
+
+func TestLogin(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+
+	api := client.New(srv.Options()...)
+	if _, err := api.Users.Me(context.Background(), nil); err == nil {
+		t.Fatal("expected error without login")
+	}
+	api = newTestClient(t, srv)
+	u, err := api.Users.Me(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != srv.UserId() {
+		t.Errorf("got user %q, want %q", u.ID, srv.UserId())
+	}
+}
+
+func TestListPaging(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	api := newTestClient(t, srv)
+	ctx := context.Background()
+
+	a := newTestAsset(t, api)
+	want := []string{a.ID}
+	for i := 0; i < 4; i++ {
+		a, err := api.Workspaces.NewAsset(ctx, a.WorkspaceId, &trimmer.AssetParams{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append([]string{a.ID}, want...)
+	}
+
+	params := &trimmer.AssetListParams{ListParams: trimmer.ListParams{Count: 2}}
+	it := api.Workspaces.ListAssets(ctx, a.WorkspaceId, params)
+	var got []string
+	for it.Next() {
+		got = append(got, it.Asset().ID)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d assets, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("asset %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	// prefetching pages yields the same assets
+	it = api.Workspaces.ListAssets(ctx, a.WorkspaceId, params)
+	ids := trimmer.Map(it.Prefetch(ctx, 2).All(), func(a *trimmer.Asset) string { return a.ID })
+	if got, err := trimmer.Collect(ids); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("prefetch: got %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestListCursor(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	api := newTestClient(t, srv)
+	ctx := context.Background()
+
+	a := newTestAsset(t, api)
+	newAssets := func(n int) []string {
+		var ids []string
+		for i := 0; i < n; i++ {
+			a, err := api.Workspaces.NewAsset(ctx, a.WorkspaceId, &trimmer.AssetParams{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, a.ID)
+		}
+		return ids
+	}
+
+	// sync walks the workspace oldest first from the last run's cursor
+	sync := func(cursor string) ([]string, string) {
+		params := &trimmer.AssetListParams{ListParams: trimmer.ListParams{
+			Count:  2,
+			Order:  trimmer.LIST_OLDEST_FIRST,
+			Cursor: cursor,
+		}}
+		it := api.Workspaces.ListAssets(ctx, a.WorkspaceId, params)
+		var ids []string
+		for it.Next() {
+			ids = append(ids, it.Asset().ID)
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return ids, it.Cursor()
+	}
+
+	want := append([]string{a.ID}, newAssets(4)...)
+	got, cursor := sync("")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("first run: got %v, want %v", got, want)
+	}
+	if got, cursor = sync(cursor); len(got) != 0 {
+		t.Errorf("expected no new assets, got %v", got)
+	}
+	want = newAssets(3)
+	if got, _ = sync(cursor); !reflect.DeepEqual(got, want) {
+		t.Errorf("next run: got %v, want %v", got, want)
+	}
+
+	// the time window limits the list in both orders
+	m, err := api.Assets.Get(ctx, want[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, order := range []trimmer.ListOrder{trimmer.LIST_NEWEST_FIRST, trimmer.LIST_OLDEST_FIRST} {
+		params := &trimmer.AssetListParams{ListParams: trimmer.ListParams{
+			Count: 2,
+			After: m.CreatedAt.Add(-time.Nanosecond),
+			Order: order,
+		}}
+		ids, err := trimmer.Collect(trimmer.Map(api.Workspaces.ListAssets(ctx, a.WorkspaceId, params).All(),
+			func(a *trimmer.Asset) string { return a.ID }))
+		if err != nil || len(ids) != len(want) {
+			t.Errorf("%q: got %v, %v, want %v", order, ids, err, want)
+		}
+	}
+}
+
+func TestUploadSingle(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	api := newTestClient(t, srv)
+	a := newTestAsset(t, api)
+
+	// the backend retries server errors
+	srv.Inject(trimmertest.Fault{Method: http.MethodPut, Path: "/uploads", Times: 1, Status: http.StatusServiceUnavailable})
+
+	data := randomData(100 << 10)
+	m, err := upload(api, a.ID, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.State != "ready" {
+		t.Errorf("got media state %q, want ready", m.State)
+	}
+	if b, _ := srv.File(m.ID); !bytes.Equal(b, data) {
+		t.Error("stored file does not match upload")
+	}
+	var buf bytes.Buffer
+	if _, err := api.Media.Download(context.Background(), m, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("downloaded file does not match upload")
+	}
+	if n := srv.Requests(http.MethodPut, "/uploads"); n != 2 {
+		t.Errorf("got %d uploads, want 2", n)
+	}
+}
+
+func TestUploadMultipartCorruptPart(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	srv.Manifest.Limits.SinglePartMax = 64 << 10
+	api := newTestClient(t, srv)
+	a := newTestAsset(t, api)
+
+	// a corrupted part checksum makes the uploader send the part again
+	srv.Inject(trimmertest.Fault{Method: http.MethodPut, Path: "/uploads/", Times: 1, CorruptHash: true})
+
+	data := randomData(200 << 10)
+	m, err := upload(api, a.ID, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := srv.File(m.ID); !bytes.Equal(b, data) {
+		t.Error("stored file does not match upload")
+	}
+	if n := srv.Requests(http.MethodPut, "/uploads/"); n != 5 {
+		t.Errorf("got %d part uploads, want 5", n)
+	}
+}
+
+func TestUploadMultipartParallel(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	srv.Manifest.Limits.SinglePartMax = 64 << 10
+	srv.Manifest.Limits.PartsParallelMax = 3
+	api := newTestClient(t, srv)
+	a := newTestAsset(t, api)
+
+	// slow parts overlap, the volume limit caps the requested concurrency;
+	// a corrupted part is sent again while the others continue
+	srv.Inject(trimmertest.Fault{Method: http.MethodPut, Path: "/uploads/", Times: 1, CorruptHash: true})
+	srv.Inject(trimmertest.Fault{Method: http.MethodPut, Path: "/uploads/", Latency: 20 * time.Millisecond})
+
+	data := randomData(64<<10*7 + 100)
+	ctx := trimmer.ContextWithPartConcurrency(context.Background(), 8)
+	params := &trimmer.MediaParams{
+		Filename: "test.bin",
+		Size:     int64(len(data)),
+		Mimetype: "application/octet-stream",
+	}
+	m, err := api.Assets.UploadMedia(ctx, a.ID, params, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := srv.File(m.ID); !bytes.Equal(b, data) {
+		t.Error("stored file does not match upload")
+	}
+	if n := srv.MaxParallelParts(); n != 3 {
+		t.Errorf("got %d parallel parts, want 3", n)
+	}
+	if n := srv.Requests(http.MethodPut, "/uploads/"); n != 9 {
+		t.Errorf("got %d part uploads, want 9", n)
+	}
+}
+
+func TestUploadJournalResume(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	srv.Manifest.Limits.SinglePartMax = 64 << 10
+	api := newTestClient(t, srv)
+	journal := trimmer.NewFileUploadJournal(t.TempDir())
+	api.Media.Journal = journal
+	a := newTestAsset(t, api)
+
+	data := randomData(64<<10*6 + 100)
+	newRequest := func() *media.UploadRequest {
+		m, err := api.Assets.NewUpload(context.Background(), a.ID, &trimmer.MediaParams{
+			Filename: "test.bin",
+			Size:     int64(len(data)),
+			Mimetype: "application/octet-stream",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi := &trimmer.FileInfo{Size: m.Size, Filename: m.Filename, UUID: m.UUID, Mimetype: m.Mimetype, Url: m.Url}
+		return api.Media.NewUploadRequest(fi, m, bytes.NewReader(data))
+	}
+	// crash stops the upload after three parts without aborting it, like a
+	// process that dies
+	crash := func(r *media.UploadRequest) *trimmer.UploadRecord {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var n int
+		r.Concurrency = 1
+		r.Progress = func(context.Context, *media.UploadRequest, int64) {
+			if n++; n == 3 {
+				cancel()
+			}
+		}
+		if _, err := r.Do(ctx); err == nil {
+			t.Fatal("expected cancelled upload")
+		}
+		rec, err := journal.Load(r.JournalKey())
+		if err != nil || rec == nil || len(rec.Parts) != 3 {
+			t.Fatalf("expected journal with 3 parts, got %+v, %v", rec, err)
+		}
+		return rec
+	}
+
+	// parts missing on the volume or in the journal and corrupt parts are
+	// checked and sent again, verified parts are skipped
+	r := newRequest()
+	rec := crash(r)
+	rec.Parts[1].Hashes.Sha256 = strings.Repeat("0", 64)
+	rec.Parts = rec.Parts[:2]
+	if err := journal.Save(rec); err != nil {
+		t.Fatal(err)
+	}
+	before := srv.Requests(http.MethodPut, "/uploads/")
+	r.Progress = nil
+	fi, err := r.Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Requests(http.MethodPut, "/uploads/") - before; n != 5 {
+		t.Errorf("got %d part uploads, want 5", n)
+	}
+	if fi.Size != int64(len(data)) {
+		t.Errorf("got size %d, want %d", fi.Size, len(data))
+	}
+	if rec, _ := journal.Load(r.JournalKey()); rec != nil {
+		t.Error("expected journal to be removed after commit")
+	}
+
+	// expired uploads start over
+	srv.UploadTTL = 100 * time.Millisecond
+	r = newRequest()
+	rec = crash(r)
+	time.Sleep(time.Until(rec.Expires) + 10*time.Millisecond)
+	before = srv.Requests(http.MethodPut, "/uploads/")
+	r.Progress = nil
+	if _, err := r.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Requests(http.MethodPut, "/uploads/") - before; n != 7 {
+		t.Errorf("got %d part uploads, want 7", n)
+	}
+
+	// records of expired uploads are removed when another upload starts
+	stale := newRequest()
+	rec = crash(stale)
+	time.Sleep(time.Until(rec.Expires) + 10*time.Millisecond)
+	if _, err := newRequest().Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ := journal.Load(stale.JournalKey()); rec != nil {
+		t.Error("expected expired journal record to be removed")
+	}
+}
+
+func TestUploadMultipartTransientPart(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	srv.Manifest.Limits.SinglePartMax = 64 << 10
+	api := newTestClient(t, srv)
+	a := newTestAsset(t, api)
+
+	// a part stored before its response got lost and a server error are
+	// retried, the retry overwrites the stored part
+	srv.Inject(trimmertest.Fault{Method: http.MethodPut, Path: "/uploads/", Times: 1, Status: http.StatusServiceUnavailable, Lost: true})
+	srv.Inject(trimmertest.Fault{Method: http.MethodPut, Path: "/uploads/", Times: 1, Status: http.StatusBadGateway})
+
+	data := randomData(64<<10*3 + 100)
+	m, err := upload(api, a.ID, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := srv.File(m.ID); !bytes.Equal(b, data) {
+		t.Error("stored file does not match upload")
+	}
+	if n := srv.Requests(http.MethodPut, "/uploads/"); n != 6 {
+		t.Errorf("got %d part uploads, want 6", n)
+	}
+}
+
+func TestUploadFailurePolicy(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	srv.Manifest.Limits.SinglePartMax = 64 << 10
+	api := newTestClient(t, srv)
+	a := newTestAsset(t, api)
+
+	data := randomData(64<<10*4 + 100)
+	newRequest := func() *media.UploadRequest {
+		m, err := api.Assets.NewUpload(context.Background(), a.ID, &trimmer.MediaParams{
+			Filename: "test.bin",
+			Size:     int64(len(data)),
+			Mimetype: "application/octet-stream",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi := &trimmer.FileInfo{Size: m.Size, Filename: m.Filename, UUID: m.UUID, Mimetype: m.Mimetype, Url: m.Url}
+		r := api.Media.NewUploadRequest(fi, m, bytes.NewReader(data))
+		r.Concurrency = 1
+		return r
+	}
+	// fail sends the first part, then makes the volume fail all parts
+	fail := func(r *media.UploadRequest) {
+		r.Progress = func(context.Context, *media.UploadRequest, int64) {
+			srv.Inject(trimmertest.Fault{Method: http.MethodPut, Path: "/uploads/", Status: http.StatusInternalServerError})
+		}
+		_, err := r.Do(context.Background())
+		if !errors.Is(err, trimmer.ETransient) {
+			t.Fatalf("got error %v, want transient failure", err)
+		}
+		srv.ClearFaults()
+	}
+
+	// failed uploads are aborted by default
+	fail(newRequest())
+	if n := srv.Requests(http.MethodDelete, "/uploads/"); n != 1 {
+		t.Errorf("got %d aborts, want 1", n)
+	}
+
+	// kept uploads continue with the missing parts
+	r := newRequest()
+	r.FailurePolicy = trimmer.KeepFailedUploads
+	fail(r)
+	if n := srv.Requests(http.MethodDelete, "/uploads/"); n != 1 {
+		t.Errorf("got %d aborts, want 1", n)
+	}
+	before := srv.Requests(http.MethodPut, "/uploads/")
+	r.Progress = nil
+	fi, err := r.Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size != int64(len(data)) {
+		t.Errorf("got size %d, want %d", fi.Size, len(data))
+	}
+	if n := srv.Requests(http.MethodPut, "/uploads/") - before; n != 4 {
+		t.Errorf("got %d part uploads, want 4", n)
+	}
+
+	// failed asset uploads keep their media, which resumes the upload; the
+	// volume stores the first part, but the client sees it fail
+	api.Assets.FailurePolicy = trimmer.KeepFailedUploads
+	srv.Inject(trimmertest.Fault{Method: http.MethodPut, Path: "/uploads/", Status: http.StatusInternalServerError, Lost: true})
+	ctx := trimmer.ContextWithPartConcurrency(context.Background(), 1)
+	deletes := srv.Requests(http.MethodDelete, "")
+	m, err := api.Assets.UploadMedia(ctx, a.ID, &trimmer.MediaParams{
+		Filename: "test.bin",
+		Size:     int64(len(data)),
+		Mimetype: "application/octet-stream",
+	}, bytes.NewReader(data))
+	if !errors.Is(err, trimmer.ETransient) || m == nil {
+		t.Fatalf("got media %v, error %v, want media and transient failure", m, err)
+	}
+	srv.ClearFaults()
+	if n := srv.Requests(http.MethodDelete, "") - deletes; n != 0 {
+		t.Errorf("got %d deletes, want none", n)
+	}
+	before = srv.Requests(http.MethodPut, "/uploads/")
+	if _, err := api.Media.Upload(ctx, m, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Requests(http.MethodPut, "/uploads/") - before; n != 4 {
+		t.Errorf("got %d part uploads, want 4", n)
+	}
+	if m.State != media.MediaStateReady {
+		t.Errorf("got media state %q, want ready", m.State)
+	}
+}
+
+func TestUploadStream(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	srv.Manifest.Limits.SinglePartMax = 1 << 20
+	api := newTestClient(t, srv)
+	a := newTestAsset(t, api)
+
+	// streams of unknown size use the multipart protocol even below the
+	// single upload limit, buffered parts with corrupted checksums are sent
+	// again
+	srv.Inject(trimmertest.Fault{Method: http.MethodPut, Path: "/uploads/", Times: 1, CorruptHash: true})
+
+	data := randomData(64<<10*3 + 100)
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write(data)
+		pw.Close()
+	}()
+	params := &trimmer.MediaParams{
+		Filename: "test.bin",
+		Mimetype: "application/octet-stream",
+	}
+	m, err := api.Assets.UploadMediaStream(context.Background(), a.ID, params, pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := srv.File(m.ID); !bytes.Equal(b, data) {
+		t.Error("stored file does not match upload")
+	}
+	if m.Size != int64(len(data)) {
+		t.Errorf("got size %d, want %d", m.Size, len(data))
+	}
+	if m.Hashes.Md5 == "" || m.Hashes.Sha1 == "" || m.Hashes.Sha256 == "" {
+		t.Errorf("got hashes %s, want all volume hash types", m.Hashes.String())
+	}
+	if n := srv.Requests(http.MethodPut, "/uploads/"); n != 5 {
+		t.Errorf("got %d part uploads, want 5", n)
+	}
+
+	// read errors abort the upload
+	pr, pw = io.Pipe()
+	go func() {
+		pw.Write(data[:64<<10+10])
+		pw.CloseWithError(io.ErrClosedPipe)
+	}()
+	if _, err := api.Assets.UploadMediaStream(context.Background(), a.ID, params, pr); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("got error %v, want %v", err, io.ErrClosedPipe)
+	}
+	if n := srv.Requests(http.MethodDelete, "/uploads/"); n != 1 {
+		t.Errorf("got %d aborts, want 1", n)
+	}
+}
+
+func TestUploadStreamGrowsParts(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	srv.Manifest.Limits.PartsMax = 16
+	srv.Manifest.Limits.PartSizeMax = 256 << 10
+	api := newTestClient(t, srv)
+	a := newTestAsset(t, api)
+
+	// parts of a stream of unknown size grow from 64k to 256k, so the
+	// stream fits into the part limit that 64k parts would exceed
+	data := randomData(2 << 20)
+	params := &trimmer.MediaParams{
+		Filename: "test.bin",
+		Mimetype: "application/octet-stream",
+	}
+	m, err := api.Assets.UploadMediaStream(context.Background(), a.ID, params, io.MultiReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := srv.File(m.ID); !bytes.Equal(b, data) {
+		t.Error("stored file does not match upload")
+	}
+	if n := srv.Requests(http.MethodPut, "/uploads/"); n != 10 {
+		t.Errorf("got %d part uploads, want 10", n)
+	}
+}
+
+func TestTruncatedDownload(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	api := newTestClient(t, srv)
+
+	data := randomData(10 << 10)
+	m := &trimmer.Media{Url: srv.PutFile("file.bin", "", data)}
+	srv.Inject(trimmertest.Fault{Path: "/files/", Truncate: true})
+
+	var buf bytes.Buffer
+	if _, err := api.Media.Download(context.Background(), m, &buf); err == nil {
+		t.Fatal("expected error on truncated download")
+	}
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	api := newTestClient(t, srv)
+	ctx := context.Background()
+	w, err := api.Users.NewWorkspace(ctx, &trimmer.WorkspaceParams{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the asset is created, but the client only sees a gateway error and
+	// retries with the same idempotency key
+	srv.Inject(trimmertest.Fault{Method: http.MethodPost, Path: "/assets", Times: 1, Status: http.StatusBadGateway, Lost: true})
+	a, err := api.Workspaces.NewAsset(ctx, w.ID, &trimmer.AssetParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Requests(http.MethodPost, "/assets"); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+	it := api.Workspaces.ListAssets(ctx, w.ID, nil)
+	var ids []string
+	for it.Next() {
+		ids = append(ids, it.Asset().ID)
+	}
+	if len(ids) != 1 || ids[0] != a.ID {
+		t.Errorf("got assets %v, want [%s]", ids, a.ID)
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+
+	// record how the cache answered, it runs inside all other middlewares
+	var status []string
+	record := func(next trimmer.RoundTrip) trimmer.RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			resp, err := next(req)
+			if err == nil && req.Method == http.MethodGet {
+				status = append(status, resp.Header.Get(trimmer.CacheStatusHeader))
+			}
+			return resp, err
+		}
+	}
+	cache := trimmer.NewResponseCache(0)
+	api := client.New(append(srv.Options(), trimmer.WithMiddleware(record), trimmer.WithResponseCache(cache))...)
+	ctx := context.Background()
+	if err := api.Session.Login(ctx, srv.LoginParams()); err != nil {
+		t.Fatal(err)
+	}
+	a := newTestAsset(t, api)
+
+	get := func() *trimmer.Asset {
+		a, err := api.Assets.Get(ctx, a.ID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	get()
+	if b := get(); b.ID != a.ID {
+		t.Errorf("got asset %s, want %s", b.ID, a.ID)
+	}
+	if _, err := api.Assets.Update(ctx, a.ID, &trimmer.AssetUpdateParams{State: "published"}); err != nil {
+		t.Fatal(err)
+	}
+	if b := get(); b.State != "published" {
+		t.Errorf("got stale asset state %s", b.State)
+	}
+	cache.TTL = time.Hour
+	get()
+
+	want := []string{"", "revalidated", "", "hit"}
+	if len(status) != len(want) {
+		t.Fatalf("got cache status %q, want %q", status, want)
+	}
+	for i := range want {
+		if status[i] != want[i] {
+			t.Errorf("request %d: got cache status %q, want %q", i, status[i], want[i])
+		}
+	}
+	if n := srv.Requests(http.MethodGet, "/assets/"); n != 3 {
+		t.Errorf("got %d requests, want 3", n)
+	}
+}
+
+func TestSessionRefreshOn401(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	api := newTestClient(t, srv)
+	ctx := context.Background()
+
+	var failures []error
+	var mu sync.Mutex
+	stop := api.Session.AutoRefresh(trimmer.RefreshPolicy{OnError: func(err error) {
+		mu.Lock()
+		failures = append(failures, err)
+		mu.Unlock()
+	}})
+	defer stop()
+
+	srv.ExpireTokens()
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := api.Users.Me(ctx, nil)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("expected replay after refresh, got %v", err)
+		}
+	}
+	if n := srv.Requests(http.MethodPost, "/auth/refresh"); n != 1 {
+		t.Errorf("expected a single refresh, got %d", n)
+	}
+
+	srv.RevokeTokens()
+	if _, err := api.Users.Me(ctx, nil); !errors.Is(err, trimmer.EUnauthorized) {
+		t.Fatalf("expected unauthorized error, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failures) != 1 || !errors.Is(failures[0], trimmer.EUnauthorized) {
+		t.Errorf("expected refresh failure callback, got %v", failures)
+	}
+	if api.Session.Sess.GetRefreshToken() != "" {
+		t.Error("expected session reset after failed refresh")
+	}
+}
+
+func TestSessionRefreshBeforeExpiry(t *testing.T) {
+	srv := trimmertest.NewServer()
+	srv.TokenTTL = 300 * time.Millisecond
+	defer srv.Close()
+	api := newTestClient(t, srv)
+
+	old := api.Session.Sess.GetAuthorization()
+	stop := api.Session.AutoRefresh(trimmer.RefreshPolicy{Margin: 250 * time.Millisecond})
+	defer stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for api.Session.Sess.GetAuthorization() == old && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if api.Session.Sess.GetAuthorization() == old {
+		t.Fatal("session was not refreshed")
+	}
+	if !api.Session.Sess.IsValid() || srv.Requests(http.MethodPost, "/auth/refresh") == 0 {
+		t.Error("expected new valid access token from refresh")
+	}
+}
+
+// slowUnlockStore waits after releasing its lock, so other processes take
+// the lock as soon as it is free.
+type slowUnlockStore struct {
+	trimmer.SessionStore
+}
+
+func (s slowUnlockStore) Lock(ctx context.Context) (func(), error) {
+	unlock, err := s.SessionStore.Lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		unlock()
+		time.Sleep(20 * time.Millisecond)
+	}, nil
+}
+
+func TestSessionStoreResume(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session")
+
+	// parallel processes share a single login
+	var wg sync.WaitGroup
+	apis := make([]*client.API, 3)
+	for i := range apis {
+		apis[i] = client.New(srv.Options()...)
+		apis[i].Session.Sess.UseStore(slowUnlockStore{trimmer.NewFileSessionStore(path)})
+		wg.Add(1)
+		go func(api *client.API) {
+			defer wg.Done()
+			if err := api.Session.Resume(ctx, srv.LoginParams()); err != nil {
+				t.Error(err)
+			}
+		}(apis[i])
+	}
+	wg.Wait()
+	if n := srv.Requests(http.MethodPost, "/auth/login"); n != 1 {
+		t.Fatalf("expected one login, got %d", n)
+	}
+	if _, err := apis[2].Users.Me(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// a refresh by one process is picked up by the others
+	stop := apis[1].Session.AutoRefresh(trimmer.RefreshPolicy{})
+	defer stop()
+	srv.ExpireTokens()
+	if _, err := apis[1].Users.Me(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	api := client.New(srv.Options()...)
+	api.Session.Sess.UseStore(trimmer.NewFileSessionStore(path))
+	if err := api.Session.Resume(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.Users.Me(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Requests(http.MethodPost, "/auth/login"); n != 1 {
+		t.Errorf("expected stored session to be reused, got %d logins", n)
+	}
+
+	if err := api.Session.Logout(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := trimmer.NewFileSessionStore(path).Load(); s != nil || err != nil {
+		t.Errorf("expected store cleared on logout, got %v, %v", s, err)
+	}
+}
+
+func TestSessionScopes(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	ctx := context.Background()
+
+	api := client.New(srv.Options()...)
+	params := srv.LoginParams()
+	params.Scopes = trimmer.ApiScopes(trimmer.API_SCOPE_PUBLIC, trimmer.API_SCOPE_PRIVATE)
+	if err := api.Session.Login(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	a := newTestAsset(t, api)
+
+	// calls that need a missing scope fail before they are sent
+	_, err := upload(api, a.ID, randomData(1024))
+	var e trimmer.TrimmerError
+	if !errors.As(err, &e) || !e.IsUsage() || !errors.Is(err, trimmer.EForbidden) || e.Scope != trimmer.API_SCOPE_UPLOAD {
+		t.Fatalf("expected scope error, got %v", err)
+	}
+	if n := srv.Requests(http.MethodPost, "/upload"); n != 0 {
+		t.Errorf("expected no upload request, got %d", n)
+	}
+
+	// scopes are tracked from response headers
+	sess := api.Session.Sess
+	sess.Update(&trimmer.Session{
+		AccessToken:  sess.AccessToken,
+		TokenType:    sess.TokenType,
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+		Scopes:       trimmer.API_SCOPE_PUBLIC,
+	})
+	if _, err := api.Users.Me(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !sess.HasScope(trimmer.API_SCOPE_PRIVATE) {
+		t.Errorf("expected scopes from header, got %v", sess.GrantedScopes())
+	}
+
+	// step up logs in again with the missing scope
+	sess.OnStepUp(api.Session.StepUp(params))
+	if _, err := upload(api, a.ID, randomData(1024)); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []trimmer.ApiAccessScope{trimmer.API_SCOPE_PUBLIC, trimmer.API_SCOPE_PRIVATE, trimmer.API_SCOPE_UPLOAD} {
+		if !sess.HasScope(v) {
+			t.Errorf("expected scope %s after step up, got %v", v, sess.GrantedScopes())
+		}
+	}
+	if n := srv.Requests(http.MethodPost, "/auth/login"); n != 2 {
+		t.Errorf("expected a second login, got %d", n)
+	}
+}
+
+func TestClientScopes(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	ctx := context.Background()
+
+	api := client.New(srv.Options()...)
+	params := srv.LoginParams()
+	params.Scopes = trimmer.API_SCOPE_PUBLIC
+	if err := api.Session.Login(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+
+	const id = "x"
+	tests := []struct {
+		name  string
+		scope trimmer.ApiAccessScope // "" for calls that need only public
+		call  func() error
+	}{
+		{"Users.Me", "", func() error { _, err := api.Users.Me(ctx, nil); return err }},
+		{"Users.UpdateMe", trimmer.API_SCOPE_PRIVATE, func() error { _, err := api.Users.UpdateMe(ctx, &trimmer.UserParams{}); return err }},
+		{"Users.NewWorkspace", trimmer.API_SCOPE_PRIVATE, func() error { _, err := api.Users.NewWorkspace(ctx, &trimmer.WorkspaceParams{}); return err }},
+		{"Users.NewVolume", trimmer.API_SCOPE_ADMIN, func() error { _, err := api.Users.NewVolume(ctx, &trimmer.VolumeParams{}); return err }},
+		{"Users.ListWorkspaces", trimmer.API_SCOPE_PRIVATE, func() error { it := api.Users.ListWorkspaces(ctx, nil); it.Next(); return it.Err() }},
+		{"Workspaces.Get", "", func() error { _, err := api.Workspaces.Get(ctx, id, nil); return err }},
+		{"Workspaces.Update", trimmer.API_SCOPE_PRIVATE, func() error { _, err := api.Workspaces.Update(ctx, id, &trimmer.WorkspaceParams{}); return err }},
+		{"Workspaces.Delete", trimmer.API_SCOPE_PRIVATE, func() error { return api.Workspaces.Delete(ctx, id) }},
+		{"Workspaces.NewAsset", trimmer.API_SCOPE_PRIVATE, func() error { _, err := api.Workspaces.NewAsset(ctx, id, &trimmer.AssetParams{}); return err }},
+		{"Workspaces.NewStash", trimmer.API_SCOPE_PRIVATE, func() error { _, err := api.Workspaces.NewStash(ctx, id, &trimmer.StashParams{}); return err }},
+		{"Workspaces.MountVolume", trimmer.API_SCOPE_ADMIN, func() error { return api.Workspaces.MountVolume(ctx, id, id, nil) }},
+		{"Workspaces.NewMember", trimmer.API_SCOPE_ADMIN, func() error { _, err := api.Workspaces.NewMember(ctx, id, id, &trimmer.MemberParams{}); return err }},
+		{"Assets.Get", "", func() error { _, err := api.Assets.Get(ctx, id, nil); return err }},
+		{"Assets.Update", trimmer.API_SCOPE_PRIVATE, func() error { _, err := api.Assets.Update(ctx, id, &trimmer.AssetUpdateParams{}); return err }},
+		{"Assets.Delete", trimmer.API_SCOPE_PRIVATE, func() error { return api.Assets.Delete(ctx, id) }},
+		{"Assets.NewTag", trimmer.API_SCOPE_PRIVATE, func() error { _, err := api.Assets.NewTag(ctx, id, &trimmer.TagParams{}); return err }},
+		{"Assets.NewUpload", trimmer.API_SCOPE_UPLOAD, func() error { _, err := api.Assets.NewUpload(ctx, id, &trimmer.MediaParams{}); return err }},
+		{"Assets.Transcode", trimmer.API_SCOPE_PRIVATE, func() error { _, err := api.Assets.Transcode(ctx, id, &trimmer.AssetTranscodeParams{}); return err }},
+		{"Media.Get", "", func() error { _, err := api.Media.Get(ctx, id, nil); return err }},
+		{"Media.Update", trimmer.API_SCOPE_PRIVATE, func() error { _, err := api.Media.Update(ctx, id, &trimmer.MediaParams{}); return err }},
+		{"Media.Delete", trimmer.API_SCOPE_PRIVATE, func() error { return api.Media.Delete(ctx, id) }},
+		{"Media.CompleteUpload", trimmer.API_SCOPE_UPLOAD, func() error { _, err := api.Media.CompleteUpload(ctx, id, nil); return err }},
+		{"Media.NewReplica", trimmer.API_SCOPE_ADMIN, func() error { _, err := api.Media.NewReplica(ctx, id, id, nil); return err }},
+		{"Stashes.Update", trimmer.API_SCOPE_PRIVATE, func() error { _, err := api.Stashes.Update(ctx, id, &trimmer.StashParams{}); return err }},
+		{"Stashes.Watch", trimmer.API_SCOPE_PRIVATE, func() error { return api.Stashes.Watch(ctx, id) }},
+		{"Stashes.NewLink", trimmer.API_SCOPE_PUBLISH, func() error { _, err := api.Stashes.NewLink(ctx, id, &trimmer.LinkParams{}); return err }},
+		{"Stashes.DeleteLink", trimmer.API_SCOPE_PUBLISH, func() error { return api.Stashes.DeleteLink(ctx, id, id) }},
+		{"Tags.Update", trimmer.API_SCOPE_PRIVATE, func() error { _, err := api.Tags.Update(ctx, id, &trimmer.TagParams{}); return err }},
+		{"Tags.Reply", trimmer.API_SCOPE_PRIVATE, func() error { _, err := api.Tags.Reply(ctx, id, &trimmer.TagParams{}); return err }},
+		{"Jobs.Cancel", trimmer.API_SCOPE_PRIVATE, func() error { _, err := api.Jobs.Cancel(ctx, id); return err }},
+		{"Orgs.Update", trimmer.API_SCOPE_ADMIN, func() error { _, err := api.Orgs.Update(ctx, id, &trimmer.OrgParams{}); return err }},
+		{"Orgs.NewWorkspace", trimmer.API_SCOPE_PRIVATE, func() error { _, err := api.Orgs.NewWorkspace(ctx, id, &trimmer.WorkspaceParams{}); return err }},
+		{"Volumes.Delete", trimmer.API_SCOPE_ADMIN, func() error { return api.Volumes.Delete(ctx, id) }},
+		{"Volumes.GetManifest", trimmer.API_SCOPE_ADMIN, func() error { _, err := api.Volumes.GetManifest(ctx, id); return err }},
+	}
+	for _, tt := range tests {
+		var e trimmer.TrimmerError
+		err := tt.call()
+		errors.As(err, &e)
+		if e.Scope != string(tt.scope) {
+			t.Errorf("%s: got scope %q, want %q (%v)", tt.name, e.Scope, tt.scope, err)
+		}
+	}
+}
+
+func TestDeviceLogin(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+	ctx := context.Background()
+
+	api := client.New(srv.Options()...)
+	var polls int
+	params := &trimmer.DeviceLoginParams{
+		Scopes:   trimmer.ApiScopes(trimmer.API_SCOPE_PUBLIC, trimmer.API_SCOPE_PRIVATE),
+		Interval: time.Millisecond,
+		Prompt: func(ctx context.Context, a *trimmer.DeviceAuthorization) error {
+			if a.UserCode == "" || a.VerificationURI == "" {
+				t.Errorf("incomplete device authorization %+v", a)
+			}
+			// approve while the client is polling
+			go func() {
+				for srv.Requests(http.MethodPost, "/auth/token") < 2 {
+					time.Sleep(time.Millisecond)
+				}
+				polls = srv.Requests(http.MethodPost, "/auth/token")
+				srv.ApproveDevice(a.UserCode)
+			}()
+			return nil
+		},
+	}
+	if err := api.Session.DeviceLogin(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	sess := api.Session.Sess
+	if !sess.IsValid() || sess.User == nil || sess.User.ID != srv.UserId() || !sess.HasScope(trimmer.API_SCOPE_PRIVATE) {
+		t.Fatalf("unexpected session %+v", sess)
+	}
+	if polls < 2 {
+		t.Errorf("expected polling while pending, got %d polls", polls)
+	}
+
+	// device sessions can be refreshed
+	srv.ExpireTokens()
+	if err := api.Session.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.Users.Me(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// denied logins fail
+	params.Prompt = func(ctx context.Context, a *trimmer.DeviceAuthorization) error {
+		srv.DenyDevice(a.UserCode)
+		return nil
+	}
+	var e trimmer.TrimmerError
+	if err := client.New(srv.Options()...).Session.DeviceLogin(ctx, params); !errors.As(err, &e) || e.Message != "access_denied" {
+		t.Errorf("expected access denied, got %v", err)
+	}
+}
+
+func TestDeviceLoginExternal(t *testing.T) {
+	srv := trimmertest.NewServer()
+	defer srv.Close()
+
+	// an authorization server on another host must not see the API key; it
+	// forwards to the stand-in, which needs the key
+	var mu sync.Mutex
+	var leaked []string
+	target, _ := url.Parse(srv.API.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		for _, h := range []string{"X-API-Key", trimmer.IdempotencyKeyHeader, "Authorization"} {
+			if r.Header.Get(h) != "" {
+				leaked = append(leaked, h)
+			}
+		}
+		mu.Unlock()
+		r.Header.Set("X-API-Key", string(srv.Key))
+		proxy.ServeHTTP(w, r)
+	}))
+	defer auth.Close()
+
+	// pending polls are not logged as errors
+	var logs bytes.Buffer
+	ctx := trimmer.ContextWithLogger(context.Background(), trimmer.NewSlogLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	params := &trimmer.DeviceLoginParams{
+		AuthorizationURL: auth.URL + "/auth/device",
+		TokenURL:         auth.URL + "/auth/token",
+		Interval:         time.Millisecond,
+		Prompt: func(ctx context.Context, a *trimmer.DeviceAuthorization) error {
+			go func() {
+				for srv.Requests(http.MethodPost, "/auth/token") < 2 {
+					time.Sleep(time.Millisecond)
+				}
+				srv.ApproveDevice(a.UserCode)
+			}()
+			return nil
+		},
+	}
+	api := client.New(srv.Options()...)
+	if err := api.Session.DeviceLogin(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(leaked) > 0 {
+		t.Errorf("authorization server received %v", leaked)
+	}
+	if strings.Contains(logs.String(), "level=ERROR") {
+		t.Errorf("pending polls logged as errors:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,171 @@
+// Trimmer SDK
+//
+// Copyright (c) 2017-2018 Alexander Eichhorn
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package trimmer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"trimmer.io/go-trimmer/hash"
+)
+
+// UploadJournal persists the state of multipart uploads, so an upload
+// interrupted by a crash continues with the parts that are missing on the
+// volume instead of starting over. Records are keyed by the upload's
+// source and destination (see media.UploadRequest.JournalKey). The media
+// package saves a record after each part and deletes it when the upload is
+// committed or aborted. Load returns nil without error for unknown keys.
+// Prune removes records of uploads the volume has discarded and is called
+// before a multipart upload starts.
+type UploadJournal interface {
+	Load(key string) (*UploadRecord, error)
+	Save(rec *UploadRecord) error
+	Delete(key string) error
+	Prune() error
+}
+
+// UploadRecord is the journal entry of an unfinished multipart upload.
+type UploadRecord struct {
+	Key      string             `json:"key"`
+	UploadId string             `json:"uploadId"`
+	Url      string             `json:"url"`
+	Size     int64              `json:"size"`
+	PartSize int64              `json:"partSize"`
+	Expires  time.Time          `json:"expires"`
+	Parts    []UploadPartRecord `json:"parts"`
+}
+
+// UploadPartRecord is a part the volume has confirmed with matching hashes.
+type UploadPartRecord struct {
+	PartId int64          `json:"partId"`
+	Size   int64          `json:"size"`
+	Hashes hash.HashBlock `json:"hashes"`
+}
+
+// Expired returns true when the volume has discarded the upload.
+func (r *UploadRecord) Expired() bool {
+	return !r.Expires.IsZero() && time.Now().After(r.Expires)
+}
+
+// UploadFailurePolicy decides what happens to an unfinished multipart upload
+// on the volume when sending it fails.
+type UploadFailurePolicy int
+
+const (
+	// AbortFailedUploads deletes the upload and all parts sent so far.
+	AbortFailedUploads UploadFailurePolicy = iota
+
+	// KeepFailedUploads leaves the upload open until it expires, so uploading
+	// the same file again sends only the missing parts.
+	KeepFailedUploads
+)
+
+var uploadJournal UploadJournal
+
+// SetUploadJournal sets the journal used by package-level upload clients.
+// Use nil to disable journaling.
+func SetUploadJournal(j UploadJournal) {
+	uploadJournal = j
+}
+
+// GetUploadJournal returns the journal set with SetUploadJournal.
+func GetUploadJournal() UploadJournal {
+	return uploadJournal
+}
+
+// DefaultUploadJournalDir returns the journal directory in the user's cache
+// directory.
+func DefaultUploadJournalDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "trimmer", "uploads")
+}
+
+// FileUploadJournal keeps one JSON file per upload in Dir. Files are replaced
+// atomically, so a crash while saving leaves the previous record intact.
+type FileUploadJournal struct {
+	Dir string
+}
+
+// NewFileUploadJournal creates a journal in dir.
+func NewFileUploadJournal(dir string) *FileUploadJournal {
+	return &FileUploadJournal{Dir: dir}
+}
+
+func (f *FileUploadJournal) path(key string) string {
+	return filepath.Join(f.Dir, filepath.Base(key)+".json")
+}
+
+func (f *FileUploadJournal) Load(key string) (*UploadRecord, error) {
+	b, err := ioutil.ReadFile(f.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec := &UploadRecord{}
+	if err := json.Unmarshal(b, rec); err != nil {
+		return nil, NewUsageError("parsing upload journal "+f.path(key)+" failed", err)
+	}
+	return rec, nil
+}
+
+func (f *FileUploadJournal) Save(rec *UploadRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.path(rec.Key), b)
+}
+
+func (f *FileUploadJournal) Delete(key string) error {
+	if err := os.Remove(f.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Prune removes records of expired uploads and records that cannot be read.
+func (f *FileUploadJournal) Prune() error {
+	files, err := ioutil.ReadDir(f.Dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		if fi.IsDir() || filepath.Ext(fi.Name()) != ".json" {
+			continue
+		}
+		key := strings.TrimSuffix(fi.Name(), ".json")
+		if rec, err := f.Load(key); err == nil && rec != nil && !rec.Expired() {
+			continue
+		}
+		if err := f.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
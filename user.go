@@ -109,15 +109,13 @@ type UserStatistics struct {
 // separated list
 func (p *UserLookupParams) MarshalJSON() ([]byte, error) {
 
-	if LogLevel > 1 {
-		// warn when there are more than max
-		if len(p.Names) > LIST_MAX_LIMIT {
-			Logger.Println("WARN: too many names in user lookup, will be capped.")
-		}
-		// warn when there are more than max
-		if len(p.IDs) > LIST_MAX_LIMIT {
-			Logger.Println("WARN: too many ids in user lookup, will be capped.")
-		}
+	// warn when there are more than max
+	if len(p.Names) > LIST_MAX_LIMIT {
+		Log.Warn("too many names in user lookup, will be capped", F("count", len(p.Names)))
+	}
+	// warn when there are more than max
+	if len(p.IDs) > LIST_MAX_LIMIT {
+		Log.Warn("too many ids in user lookup, will be capped", F("count", len(p.IDs)))
 	}
 
 	return json.Marshal(struct {
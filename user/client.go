@@ -49,12 +49,12 @@ func getC() Client {
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.User]
 }
 
 // User returns the most recent User visited by a call to Next.
 func (i *Iter) User() *trimmer.User {
-	return i.Current().(*trimmer.User)
+	return i.Item()
 }
 
 func Me(ctx context.Context, params *trimmer.UserParams) (*trimmer.User, error) {
@@ -74,7 +74,7 @@ func Search(ctx context.Context, params *trimmer.UserSearchParams) *Iter {
 }
 
 func UploadImage(ctx context.Context, params *trimmer.FileInfo, src io.Reader) (*trimmer.Media, error) {
-	return media.UploadImage(ctx, "/users/me/media", params, src)
+	return getC().UploadImage(ctx, params, src)
 }
 
 func ListMedia(ctx context.Context, params *trimmer.MediaListParams) *media.Iter {
@@ -122,7 +122,7 @@ func (c Client) UpdateMe(ctx context.Context, params *trimmer.UserParams) (*trim
 		return nil, trimmer.ENilPointer
 	}
 	v := &trimmer.User{}
-	err := c.B.Call(ctx, http.MethodPatch, "/users/me", c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPatch, "/users/me", c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -185,20 +185,18 @@ func (c Client) Search(ctx context.Context, params *trimmer.UserSearchParams) *I
 		lp = &params.ListParams
 	}
 
-	return &Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.User, trimmer.ListMeta, error) {
 		res := &searchResult{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/search?%v", b.Encode()), c.Key, c.Sess, nil, nil, res)
-		ret := make([]interface{}, len(res.Users.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range res.Users.Values {
-			ret[i] = v
-		}
-
-		return ret, res.Users.ListMeta, err
+		return res.Users.Values, res.Users.ListMeta, err
 	})}
 }
 
+func (c Client) UploadImage(ctx context.Context, params *trimmer.FileInfo, src io.Reader) (*trimmer.Media, error) {
+	mc := media.Client{B: c.B, Key: c.Key, Sess: c.Sess}
+	return mc.UploadImage(ctx, "/users/me/media", params, src)
+}
+
 func (c Client) ListMedia(ctx context.Context, params *trimmer.MediaListParams) *media.Iter {
 
 	type mediaList struct {
@@ -240,17 +238,10 @@ func (c Client) ListMedia(ctx context.Context, params *trimmer.MediaListParams)
 		lp = &params.ListParams
 	}
 
-	return &media.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &media.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Media, trimmer.ListMeta, error) {
 		list := &mediaList{}
-		err := c.B.Call(ctx, http.MethodGet, "/users/me/media?"+b.Encode(), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		err := c.B.Call(ctx, http.MethodGet, "/users/me/media?"+b.Encode(), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, list)
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -272,17 +263,10 @@ func (c Client) ListOrgs(ctx context.Context, params *trimmer.OrgListParams) *or
 		lp = &params.ListParams
 	}
 
-	return &org.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &org.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Org, trimmer.ListMeta, error) {
 		list := &orgList{}
-		err := c.B.Call(ctx, http.MethodGet, "/users/me/orgs?"+b.Encode(), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		err := c.B.Call(ctx, http.MethodGet, "/users/me/orgs?"+b.Encode(), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, list)
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -297,7 +281,7 @@ func (c Client) NewWorkspace(ctx context.Context, params *trimmer.WorkspaceParam
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
 	v := &trimmer.Workspace{}
-	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -330,17 +314,10 @@ func (c Client) ListWorkspaces(ctx context.Context, params *trimmer.WorkspaceLis
 		lp = &params.ListParams
 	}
 
-	return &workspace.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &workspace.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Workspace, trimmer.ListMeta, error) {
 		list := &workspaceList{}
-		err := c.B.Call(ctx, http.MethodGet, "/users/me/workspaces?"+b.Encode(), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		err := c.B.Call(ctx, http.MethodGet, "/users/me/workspaces?"+b.Encode(), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, list)
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -355,7 +332,7 @@ func (c Client) NewVolume(ctx context.Context, params *trimmer.VolumeParams) (*t
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
 	v := &trimmer.Volume{}
-	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, params, v)
 	return v, err
 }
 
@@ -409,17 +386,10 @@ func (c Client) ListVolumes(ctx context.Context, params *trimmer.VolumeListParam
 		lp = &params.ListParams
 	}
 
-	return &volume.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &volume.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Volume, trimmer.ListMeta, error) {
 		list := &volumeList{}
-		err := c.B.Call(ctx, http.MethodGet, "/users/me/volumes?"+b.Encode(), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		err := c.B.Call(ctx, http.MethodGet, "/users/me/volumes?"+b.Encode(), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, list)
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -449,16 +419,9 @@ func (c Client) ListEvents(ctx context.Context, params *trimmer.EventListParams)
 		lp = &params.ListParams
 	}
 
-	return &event.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &event.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Event, trimmer.ListMeta, error) {
 		list := &eventList{}
-		err := c.B.Call(ctx, http.MethodGet, "/users/me/events?"+b.Encode(), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		err := c.B.Call(ctx, http.MethodGet, "/users/me/events?"+b.Encode(), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, list)
+		return list.Values, list.ListMeta, err
 	})}
 }
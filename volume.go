@@ -165,7 +165,6 @@ type VolumeListParams struct {
 
 // VolumeParams is the set of parameters that can be used to create and
 // update a volume.
-//
 type VolumeParams struct {
 	Name            string               `json:"name"`            // required
 	Type            VolumeType           `json:"type"`            // required
@@ -195,13 +194,11 @@ type VolumeParams struct {
 
 // VolumeScanParams is the set of parameters that can be used when scanning
 // a volume.
-//
 type VolumeScanParams struct {
 }
 
 // VolumeClearParams is the set of parameters that can be used when clearing
 // a volume.
-//
 type VolumeClearParams struct {
 	Wipe bool `json:"wipe,omitempty"`
 }
@@ -283,11 +280,12 @@ type VolumeAuth struct {
 }
 
 type VolumeLimits struct {
-	PartSizeMin   int64 `json:"minPartSize"`
-	PartSizeMax   int64 `json:"maxPartSize"`
-	PartsMax      int64 `json:"maxParts"`
-	FileSizeMax   int64 `json:"maxFileSize"`
-	SinglePartMax int64 `json:"maxSinglePart"`
+	PartSizeMin      int64 `json:"minPartSize"`
+	PartSizeMax      int64 `json:"maxPartSize"`
+	PartsMax         int64 `json:"maxParts"`
+	FileSizeMax      int64 `json:"maxFileSize"`
+	SinglePartMax    int64 `json:"maxSinglePart"`
+	PartsParallelMax int64 `json:"maxParallelParts,omitempty"` // 0 is unlimited
 }
 
 type VolumeStatistics struct {
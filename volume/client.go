@@ -43,12 +43,12 @@ func getC() Client {
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.Volume]
 }
 
 // Volume returns the most recent Volume visited by a call to Next.
 func (i *Iter) Volume() *trimmer.Volume {
-	return i.Current().(*trimmer.Volume)
+	return i.Item()
 }
 
 func Get(ctx context.Context, volId string, params *trimmer.VolumeParams) (*trimmer.Volume, error) {
@@ -102,7 +102,7 @@ func (c Client) Update(ctx context.Context, volId string, params *trimmer.Volume
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
 	v := &trimmer.Volume{}
-	err := c.B.Call(ctx, http.MethodPatch, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPatch, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, params, v)
 	return v, err
 }
 
@@ -110,13 +110,13 @@ func (c Client) Delete(ctx context.Context, volId string) error {
 	if volId == "" {
 		return trimmer.EIDMissing
 	}
-	err := c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/volumes/%v", volId), c.Key, c.Sess, nil, nil, nil)
+	err := c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/volumes/%v", volId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, nil, nil)
 	return err
 }
 
 func (c Client) ListReplicas(ctx context.Context, volId string, params *trimmer.MediaListParams) *replica.Iter {
 	if volId == "" {
-		return &replica.Iter{Iter: trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &replica.Iter{Iter: trimmer.GetIterErr[*trimmer.Replica](trimmer.EIDMissing)}
 	}
 
 	type replicaList struct {
@@ -164,23 +164,16 @@ func (c Client) ListReplicas(ctx context.Context, volId string, params *trimmer.
 		lp = &params.ListParams
 	}
 
-	return &replica.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &replica.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Replica, trimmer.ListMeta, error) {
 		list := &replicaList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/volumes/%v/media?%v", volId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
 func (c Client) ListMounts(ctx context.Context, volId string, params *trimmer.WorkspaceListParams) *mount.Iter {
 	if volId == "" {
-		return &mount.Iter{Iter: trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &mount.Iter{Iter: trimmer.GetIterErr[*trimmer.Mount](trimmer.EIDMissing)}
 	}
 
 	type eventList struct {
@@ -204,17 +197,10 @@ func (c Client) ListMounts(ctx context.Context, volId string, params *trimmer.Wo
 		lp = &params.ListParams
 	}
 
-	return &mount.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &mount.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Mount, trimmer.ListMeta, error) {
 		list := &eventList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/volumes/%v/mounts?%v", volId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -224,6 +210,6 @@ func (c Client) GetManifest(ctx context.Context, volId string) (*trimmer.VolumeM
 	}
 	u := fmt.Sprintf("/volumes/%v/manifest", volId)
 	v := &trimmer.VolumeManifest{}
-	err := c.B.Call(ctx, http.MethodGet, u, c.Key, c.Sess, nil, nil, v)
+	err := c.B.Call(ctx, http.MethodGet, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, nil, v)
 	return v, err
 }
@@ -53,12 +53,12 @@ func getC() Client {
 // The embedded Iter carries methods with it;
 // see its documentation for details.
 type Iter struct {
-	*trimmer.Iter
+	*trimmer.Iter[*trimmer.Workspace]
 }
 
 // Workspace returns the most recent Workspace visited by a call to Next.
 func (i *Iter) Workspace() *trimmer.Workspace {
-	return i.Current().(*trimmer.Workspace)
+	return i.Item()
 }
 
 func Get(ctx context.Context, workId string, params *trimmer.WorkspaceParams) (*trimmer.Workspace, error) {
@@ -90,8 +90,7 @@ func NewStash(ctx context.Context, workId string, params *trimmer.StashParams) (
 }
 
 func UploadImage(ctx context.Context, workId string, params *trimmer.FileInfo, src io.Reader) (*trimmer.Media, error) {
-	uri := fmt.Sprintf("/workspaces/%v/media", workId)
-	return media.UploadImage(ctx, uri, params, src)
+	return getC().UploadImage(ctx, workId, params, src)
 }
 
 func ListMedia(ctx context.Context, workId string, params *trimmer.MediaListParams) *media.Iter {
@@ -175,7 +174,7 @@ func (c Client) Update(ctx context.Context, workId string, params *trimmer.Works
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
 	v := &trimmer.Workspace{}
-	err := c.B.Call(ctx, http.MethodPatch, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPatch, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
@@ -183,12 +182,12 @@ func (c Client) Delete(ctx context.Context, workId string) error {
 	if workId == "" {
 		return trimmer.EIDMissing
 	}
-	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/workspaces/%v", workId), c.Key, c.Sess, nil, nil, nil)
+	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/workspaces/%v", workId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, nil, nil)
 }
 
 func (c Client) ListAssets(ctx context.Context, workId string, params *trimmer.AssetListParams) *asset.Iter {
 	if workId == "" {
-		return &asset.Iter{trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &asset.Iter{trimmer.GetIterErr[*trimmer.Asset](trimmer.EIDMissing)}
 	}
 
 	type assetList struct {
@@ -233,17 +232,10 @@ func (c Client) ListAssets(ctx context.Context, workId string, params *trimmer.A
 		lp = &params.ListParams
 	}
 
-	return &asset.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &asset.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Asset, trimmer.ListMeta, error) {
 		list := &assetList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/workspaces/%v/assets?%v", workId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -261,14 +253,14 @@ func (c Client) NewAsset(ctx context.Context, workId string, params *trimmer.Ass
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
 	v := &trimmer.Asset{}
-	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
 func (c Client) ListStashes(ctx context.Context, workId string, params *trimmer.StashListParams) *stash.Iter {
 
 	if workId == "" {
-		return &stash.Iter{trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &stash.Iter{trimmer.GetIterErr[*trimmer.Stash](trimmer.EIDMissing)}
 	}
 
 	type stashList struct {
@@ -298,17 +290,10 @@ func (c Client) ListStashes(ctx context.Context, workId string, params *trimmer.
 		lp = &params.ListParams
 	}
 
-	return &stash.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &stash.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Stash, trimmer.ListMeta, error) {
 		list := &stashList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/workspaces/%v/stashes?%v", workId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 
 }
@@ -327,13 +312,21 @@ func (c Client) NewStash(ctx context.Context, workId string, params *trimmer.Sta
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
 	v := &trimmer.Stash{}
-	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_PRIVATE}, params, v)
 	return v, err
 }
 
+func (c Client) UploadImage(ctx context.Context, workId string, params *trimmer.FileInfo, src io.Reader) (*trimmer.Media, error) {
+	if workId == "" {
+		return nil, trimmer.EIDMissing
+	}
+	mc := media.Client{B: c.B, Key: c.Key, Sess: c.Sess}
+	return mc.UploadImage(ctx, fmt.Sprintf("/workspaces/%v/media", workId), params, src)
+}
+
 func (c Client) ListMedia(ctx context.Context, workId string, params *trimmer.MediaListParams) *media.Iter {
 	if workId == "" {
-		return &media.Iter{Iter: trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &media.Iter{Iter: trimmer.GetIterErr[*trimmer.Media](trimmer.EIDMissing)}
 	}
 
 	type mediaList struct {
@@ -375,23 +368,16 @@ func (c Client) ListMedia(ctx context.Context, workId string, params *trimmer.Me
 		lp = &params.ListParams
 	}
 
-	return &media.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &media.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Media, trimmer.ListMeta, error) {
 		list := &mediaList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/workspaces/%v/media?%v", workId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
 func (c Client) ListEvents(ctx context.Context, workId string, params *trimmer.EventListParams) *event.Iter {
 	if workId == "" {
-		return &event.Iter{trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &event.Iter{trimmer.GetIterErr[*trimmer.Event](trimmer.EIDMissing)}
 	}
 
 	type eventList struct {
@@ -418,23 +404,16 @@ func (c Client) ListEvents(ctx context.Context, workId string, params *trimmer.E
 		lp = &params.ListParams
 	}
 
-	return &event.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &event.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Event, trimmer.ListMeta, error) {
 		list := &eventList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/workspaces/%v/events?%v", workId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
 func (c Client) ListProfiles(ctx context.Context, workId string, params *trimmer.ProfileListParams) *profile.Iter {
 	if workId == "" {
-		return &profile.Iter{Iter: trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &profile.Iter{Iter: trimmer.GetIterErr[*trimmer.Profile](trimmer.EIDMissing)}
 	}
 
 	type profileList struct {
@@ -464,23 +443,16 @@ func (c Client) ListProfiles(ctx context.Context, workId string, params *trimmer
 		lp = &params.ListParams
 	}
 
-	return &profile.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &profile.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Profile, trimmer.ListMeta, error) {
 		list := &profileList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/workspaces/%v/profiles?%s", workId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
 func (c Client) ListJobs(ctx context.Context, workId string, params *trimmer.JobListParams) *job.Iter {
 	if workId == "" {
-		return &job.Iter{trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &job.Iter{trimmer.GetIterErr[*trimmer.Job](trimmer.EIDMissing)}
 	}
 
 	type jobList struct {
@@ -519,23 +491,16 @@ func (c Client) ListJobs(ctx context.Context, workId string, params *trimmer.Job
 		lp = &params.ListParams
 	}
 
-	return &job.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &job.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Job, trimmer.ListMeta, error) {
 		list := &jobList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/workspaces/%v/jobs?%v", workId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
 func (c Client) ListMounts(ctx context.Context, workId string, params *trimmer.MountListParams) *mount.Iter {
 	if workId == "" {
-		return &mount.Iter{Iter: trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &mount.Iter{Iter: trimmer.GetIterErr[*trimmer.Mount](trimmer.EIDMissing)}
 	}
 
 	type mountList struct {
@@ -574,17 +539,10 @@ func (c Client) ListMounts(ctx context.Context, workId string, params *trimmer.M
 		lp = &params.ListParams
 	}
 
-	return &mount.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &mount.Iter{Iter: trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Mount, trimmer.ListMeta, error) {
 		list := &mountList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/workspaces/%v/mounts?%v", workId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }
 
@@ -598,7 +556,7 @@ func (c Client) MountVolume(ctx context.Context, workId, volId string, params *t
 		q.Add("embed", params.Embed.String())
 		u += fmt.Sprintf("?%v", q.Encode())
 	}
-	return c.B.Call(ctx, http.MethodPut, u, c.Key, c.Sess, nil, params, nil)
+	return c.B.Call(ctx, http.MethodPut, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, params, nil)
 }
 
 func (c Client) UnmountVolume(ctx context.Context, workId, volId string) error {
@@ -606,7 +564,7 @@ func (c Client) UnmountVolume(ctx context.Context, workId, volId string) error {
 		return trimmer.EIDMissing
 	}
 	u := fmt.Sprintf("/workspaces/%v/volumes/%v", workId, volId)
-	return c.B.Call(ctx, http.MethodDelete, u, c.Key, c.Sess, nil, nil, nil)
+	return c.B.Call(ctx, http.MethodDelete, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, nil, nil)
 }
 
 func (c Client) ScanVolume(ctx context.Context, workId, volId string, params *trimmer.VolumeScanParams) (*trimmer.Job, error) {
@@ -615,7 +573,7 @@ func (c Client) ScanVolume(ctx context.Context, workId, volId string, params *tr
 	}
 	v := &trimmer.Job{}
 	u := fmt.Sprintf("/workspaces/%v/volumes/%v/scan", workId, volId)
-	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, params, v)
 	return v, err
 }
 
@@ -625,7 +583,7 @@ func (c Client) ClearVolume(ctx context.Context, workId, volId string, params *t
 	}
 	v := &trimmer.Job{}
 	u := fmt.Sprintf("/workspaces/%v/volumes/%v/clear", workId, volId)
-	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPost, u, c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, params, v)
 	return v, err
 }
 
@@ -643,7 +601,7 @@ func (c Client) NewMember(ctx context.Context, workId, userId string, params *tr
 		return nil, trimmer.EIDMissing
 	}
 	v := &trimmer.Member{}
-	err := c.B.Call(ctx, http.MethodPut, fmt.Sprintf("/workspaces/%v/members/%v", workId, userId), c.Key, c.Sess, nil, params, v)
+	err := c.B.Call(ctx, http.MethodPut, fmt.Sprintf("/workspaces/%v/members/%v", workId, userId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, params, v)
 	return v, err
 }
 
@@ -651,17 +609,17 @@ func (c Client) DeleteMember(ctx context.Context, workId, userId string) error {
 	if workId == "" || userId == "" {
 		return trimmer.EIDMissing
 	}
-	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/workspaces/%v/members/%v", workId, userId), c.Key, c.Sess, nil, nil, nil)
+	return c.B.Call(ctx, http.MethodDelete, fmt.Sprintf("/workspaces/%v/members/%v", workId, userId), c.Key, c.Sess, &trimmer.CallHeaders{Scope: trimmer.API_SCOPE_ADMIN}, nil, nil)
 }
 
 func (c Client) ListMembers(ctx context.Context, workId string, params *trimmer.MemberListParams) *member.Iter {
 	if workId == "" {
-		return &member.Iter{trimmer.GetIterErr(trimmer.EIDMissing)}
+		return &member.Iter{trimmer.GetIterErr[*trimmer.Member](trimmer.EIDMissing)}
 	}
 
 	type memberList struct {
 		trimmer.ListMeta
-		Values trimmer.VolumeList `json:"members"`
+		Values trimmer.MemberList `json:"members"`
 	}
 
 	var q *url.Values
@@ -683,16 +641,9 @@ func (c Client) ListMembers(ctx context.Context, workId string, params *trimmer.
 		lp = &params.ListParams
 	}
 
-	return &member.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]interface{}, trimmer.ListMeta, error) {
+	return &member.Iter{trimmer.GetIter(lp, q, func(b url.Values) ([]*trimmer.Member, trimmer.ListMeta, error) {
 		list := &memberList{}
 		err := c.B.Call(ctx, http.MethodGet, fmt.Sprintf("/workspaces/%v/members?%v", workId, b.Encode()), c.Key, c.Sess, nil, nil, list)
-		ret := make([]interface{}, len(list.Values))
-
-		// pass concrete values as abstract interface into iterator
-		for i, v := range list.Values {
-			ret[i] = v
-		}
-
-		return ret, list.ListMeta, err
+		return list.Values, list.ListMeta, err
 	})}
 }